@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/db"
+)
+
+// runAPIKey — подкоманда apikey: выдача, список и отзыв API ключей.
+//
+//	app apikey create -name partner-a -roles reader,ingester
+//	app apikey list
+//	app apikey revoke -id 3
+func runAPIKey(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: app apikey create|list|revoke [flags]")
+	}
+	switch args[0] {
+	case "create":
+		return runAPIKeyCreate(args[1:])
+	case "list":
+		return runAPIKeyList(args[1:])
+	case "revoke":
+		return runAPIKeyRevoke(args[1:])
+	}
+	return fmt.Errorf("unknown apikey command %q (want create, list or revoke)", args[0])
+}
+
+// runAPIKeyCreate печатает ключ в stdout; в БД остаётся только его хеш
+func runAPIKeyCreate(args []string) error {
+	var name, roles string
+	cfg, err := config.LoadCommand("apikey create", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&name, "name", "", "имя ключа (попадает в журнал аудита)")
+		fs.StringVar(&roles, "roles", auth.RoleReader, "роли через запятую: reader, ingester, admin")
+	})
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(name) == "" {
+		return errors.New("-name is required")
+	}
+	var list []string
+	for _, r := range strings.Split(roles, ",") {
+		r = strings.TrimSpace(r)
+		if !auth.ValidRole(r) {
+			return fmt.Errorf("unknown role %q", r)
+		}
+		list = append(list, r)
+	}
+	store, err := db.NewSQLStore(cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	key, err := auth.NewKey()
+	if err != nil {
+		return err
+	}
+	k, err := store.CreateAPIKey(context.Background(), name, auth.HashKey(key), list)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "created key id=%d name=%s roles=%s; store it now, it is not shown again\n",
+		k.ID, k.Name, strings.Join(k.Roles, ","))
+	fmt.Println(key)
+	return nil
+}
+
+func runAPIKeyList(args []string) error {
+	cfg, err := config.LoadCommand("apikey list", args, nil)
+	if err != nil {
+		return err
+	}
+	store, err := db.NewSQLStore(cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	keys, err := store.ListAPIKeys(context.Background())
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "id\tname\troles\tcreated\tlast used\trevoked\n")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, strings.Join(k.Roles, ","),
+			k.CreatedAt.Format(time.RFC3339), fmtTime(k.LastUsedAt), fmtTime(k.RevokedAt))
+	}
+	return tw.Flush()
+}
+
+func runAPIKeyRevoke(args []string) error {
+	var id int64
+	cfg, err := config.LoadCommand("apikey revoke", args, func(fs *flag.FlagSet) {
+		fs.Int64Var(&id, "id", 0, "id ключа из apikey list")
+	})
+	if err != nil {
+		return err
+	}
+	if id <= 0 {
+		return errors.New("-id is required")
+	}
+	store, err := db.NewSQLStore(cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	if err := store.RevokeAPIKey(context.Background(), id); errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("key %d not found or already revoked", id)
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "revoked key %d\n", id)
+	return nil
+}
+
+func fmtTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/export"
+	"go-orders-demo/internal/pii"
+)
+
+// runExport — подкоманда export: выгрузка заказов из БД в файл или stdout.
+// Принимает те же флаги конфигурации, что и сервер (-config, -postgres-dsn, …).
+func runExport(args []string) error {
+	var format, out, customerID, deliveryService, phone, email, from, to string
+	var reveal bool
+	cfg, err := config.LoadCommand("export", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", export.FormatNDJSON, "формат: ndjson, csv (zip) или parquet")
+		fs.StringVar(&out, "out", "", "файл результата (по умолчанию orders.<ext>); - — stdout")
+		fs.StringVar(&customerID, "customer-id", "", "только заказы покупателя")
+		fs.StringVar(&deliveryService, "delivery-service", "", "только заказы службы доставки")
+		fs.StringVar(&phone, "phone", "", "только заказы с этим телефоном получателя")
+		fs.StringVar(&email, "email", "", "только заказы с этим e-mail получателя")
+		fs.StringVar(&from, "from", "", "date_created не раньше (RFC3339 или YYYY-MM-DD)")
+		fs.StringVar(&to, "to", "", "date_created раньше (RFC3339 или YYYY-MM-DD)")
+		fs.BoolVar(&reveal, "reveal-pii", false, "не маскировать персональные данные при pii.enabled")
+	})
+	if err != nil {
+		return err
+	}
+	f, err := export.ParseFilter(customerID, deliveryService, from, to)
+	if err != nil {
+		return err
+	}
+	f.Phone, f.Email = phone, email
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg, cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	tx, err := store.BeginExport(ctx)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	defer tx.Close()
+
+	w := os.Stdout
+	if out == "" {
+		out = export.FileName(format)
+	}
+	if out != "-" {
+		if w, err = os.Create(out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	var src export.Source = tx
+	if cfg.PII.Enabled && !reveal {
+		rules, _ := pii.ParseRules(cfg.PII.Rules)
+		src = export.Masked(tx, pii.New(rules, nil).Mask)
+	}
+	n, err := export.Write(ctx, w, format, src, f)
+	if err != nil {
+		return err
+	}
+	if out != "-" {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	log.Printf("export: %d orders -> %s", n, out)
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/importer"
+	kaf "go-orders-demo/internal/kafka"
+)
+
+// runImport — подкоманда import: загрузка заказов из NDJSON/CSV файлов
+// в Kafka (по умолчанию) или напрямую в БД.
+// Принимает те же флаги конфигурации, что и сервер (-config, -kafka-brokers, …).
+func runImport(args []string) error {
+	var format, target, checkpoint string
+	var rate float64
+	var batch int
+	var dryRun, restart bool
+	var fset *flag.FlagSet
+	cfg, err := config.LoadCommand("import", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", "", "формат: ndjson или csv (плоский CSV или zip из export; по умолчанию — по расширению файла)")
+		fs.StringVar(&target, "to", "kafka", "куда писать: kafka или db (SaveOrder, без кеша и событий)")
+		fs.Float64Var(&rate, "rate", 0, "не больше заказов в секунду; 0 — без ограничения")
+		fs.IntVar(&batch, "batch", 0, "заказов в одной записи (по умолчанию ingest.bulk_batch_size)")
+		fs.StringVar(&checkpoint, "checkpoint", "", "файл чекпойнта (по умолчанию <файл>.checkpoint); только для одного файла")
+		fs.BoolVar(&restart, "restart", false, "игнорировать чекпойнт и начать с первой строки")
+		fs.BoolVar(&dryRun, "dry-run", false, "только проверить файлы, ничего не записывая")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: app import [flags] file...\n")
+			fs.PrintDefaults()
+		}
+		fset = fs
+	})
+	if err != nil {
+		return err
+	}
+	files := fset.Args()
+	if len(files) == 0 {
+		return errors.New("no input files")
+	}
+	if checkpoint != "" && len(files) > 1 {
+		return errors.New("-checkpoint needs exactly one input file")
+	}
+	if batch <= 0 {
+		batch = cfg.Ingest.BulkBatchSize
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var sink importer.Sink
+	switch {
+	case dryRun:
+	case target == "kafka":
+		sec, err := kaf.NewSecurity(cfg.Kafka)
+		if err != nil {
+			return fmt.Errorf("kafka: %w", err)
+		}
+		var schemas *kaf.FileRegistry
+		if cfg.Kafka.Format == config.FormatAvro {
+			if schemas, err = kaf.NewFileRegistry(cfg.Kafka.SchemaDir); err != nil {
+				return fmt.Errorf("kafka: %w", err)
+			}
+		}
+		codec, err := kaf.NewCodec(cfg.Kafka.Format, schemas, cfg.Kafka.SchemaID)
+		if err != nil {
+			return fmt.Errorf("kafka: %w", err)
+		}
+		producer := kaf.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic, sec).WithCodec(codec)
+		defer producer.Close()
+		sink = importer.NewKafkaSink(producer)
+	case target == "db":
+		store, err := openStore(cfg, cfg.Postgres.DSN)
+		if err != nil {
+			return fmt.Errorf("db: %w", err)
+		}
+		sink = importer.NewStoreSink(store)
+	default:
+		return fmt.Errorf("unknown -to %q (want kafka or db)", target)
+	}
+
+	for _, name := range files {
+		opts := importer.Options{
+			Format:       format,
+			BatchSize:    batch,
+			Rate:         rate,
+			MaxLineBytes: cfg.Ingest.BulkMaxLineBytes,
+			Checkpoint:   checkpoint,
+			DryRun:       dryRun,
+		}
+		if opts.Format == "" {
+			opts.Format = importer.FormatByName(name)
+		}
+		if opts.Checkpoint == "" {
+			opts.Checkpoint = name + ".checkpoint"
+		}
+		if restart {
+			if dryRun {
+				opts.Checkpoint = ""
+			} else if err := os.Remove(opts.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := importFile(ctx, name, sink, opts); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func importFile(ctx context.Context, name string, sink importer.Sink, opts importer.Options) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := importer.Run(ctx, f, sink, opts)
+	mode := "imported"
+	if opts.DryRun {
+		mode = "valid"
+	}
+	log.Printf("import %s: %s=%d invalid=%d skipped=%d, checkpoint line %d",
+		name, mode, st.Imported, st.Invalid, st.Skipped, st.Line)
+	return err
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"go-orders-demo/internal/config"
+	kaf "go-orders-demo/internal/kafka"
+)
+
+// runKafka — подкоманда kafka: offsets группы, перемотка и replay топика заказов.
+//
+//	app kafka offsets
+//	app kafka rewind -time 2024-01-02T10:00:00Z | -offsets 0=100,1=250 | -earliest [-dry-run]
+//	app kafka replay -from 2024-01-02 -to 2024-01-03 [-target-dsn DSN]
+func runKafka(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: app kafka offsets|rewind|replay [flags]")
+	}
+	switch args[0] {
+	case "offsets":
+		return runKafkaOffsets(args[1:])
+	case "rewind":
+		return runKafkaRewind(args[1:])
+	case "replay":
+		return runKafkaReplay(args[1:])
+	}
+	return fmt.Errorf("unknown kafka command %q (want offsets, rewind or replay)", args[0])
+}
+
+func newKafkaAdmin(cfg *config.Config) (*kaf.Admin, error) {
+	sec, err := kaf.NewSecurity(cfg.Kafka)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	return kaf.NewAdmin(cfg.Kafka.Brokers, cfg.Kafka.Topic, sec), nil
+}
+
+func runKafkaOffsets(args []string) error {
+	cfg, err := config.LoadCommand("kafka offsets", args, nil)
+	if err != nil {
+		return err
+	}
+	admin, err := newKafkaAdmin(cfg)
+	if err != nil {
+		return err
+	}
+	states, err := admin.GroupOffsets(context.Background(), cfg.Kafka.Group)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "partition\tfirst\tlast\tcommitted\tlag\t\n")
+	for _, st := range states {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t\n", st.Partition, st.First, st.Last, st.Committed, st.Lag)
+	}
+	return tw.Flush()
+}
+
+// runKafkaRewind коммитит новые offsets группы kafka.group.
+// Все экземпляры сервера должны быть остановлены: брокер не примет коммит для активной группы.
+func runKafkaRewind(args []string) error {
+	var at, list string
+	var earliest, dryRun bool
+	cfg, err := config.LoadCommand("kafka rewind", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&at, "time", "", "перемотать на первое сообщение не раньше (RFC3339 или YYYY-MM-DD)")
+		fs.StringVar(&list, "offsets", "", "явные offsets: partition=offset через запятую")
+		fs.BoolVar(&earliest, "earliest", false, "перемотать на начало топика")
+		fs.BoolVar(&dryRun, "dry-run", false, "только показать новые offsets")
+	})
+	if err != nil {
+		return err
+	}
+	set := 0
+	for _, ok := range []bool{at != "", list != "", earliest} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of -time, -offsets or -earliest is required")
+	}
+	admin, err := newKafkaAdmin(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var offsets []kaf.PartitionOffset
+	switch {
+	case list != "":
+		if offsets, err = parseOffsets(list); err != nil {
+			return err
+		}
+	case earliest:
+		offsets, err = admin.OffsetsAt(ctx, time.Unix(0, 0))
+	default:
+		t, perr := parseTimeFlag(at)
+		if perr != nil {
+			return fmt.Errorf("-time: %w", perr)
+		}
+		offsets, err = admin.OffsetsAt(ctx, t)
+	}
+	if err != nil {
+		return err
+	}
+	for _, o := range offsets {
+		fmt.Printf("partition %d -> offset %d\n", o.Partition, o.Offset)
+	}
+	if dryRun {
+		return nil
+	}
+	if err := admin.CommitOffsets(ctx, cfg.Kafka.Group, offsets); err != nil {
+		return err
+	}
+	log.Printf("kafka rewind: group %s moved on %d partitions", cfg.Kafka.Group, len(offsets))
+	return nil
+}
+
+// parseOffsets разбирает "0=100,1=250"
+func parseOffsets(s string) ([]kaf.PartitionOffset, error) {
+	var res []kaf.PartitionOffset
+	for _, part := range strings.Split(s, ",") {
+		p, o, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("-offsets: %q is not partition=offset", part)
+		}
+		pn, err1 := strconv.Atoi(p)
+		off, err2 := strconv.ParseInt(o, 10, 64)
+		if err1 != nil || err2 != nil || pn < 0 || off < 0 {
+			return nil, fmt.Errorf("-offsets: bad value %q", part)
+		}
+		res = append(res, kaf.PartitionOffset{Partition: pn, Offset: off})
+	}
+	return res, nil
+}
+
+// runKafkaReplay читает окно топика без группы и сохраняет заказы в БД (по умолчанию postgres.dsn).
+// Кеш работающих серверов не обновляется: они увидят заказы после перезапуска.
+func runKafkaReplay(args []string) error {
+	var from, to, targetDSN string
+	var every time.Duration
+	cfg, err := config.LoadCommand("kafka replay", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&from, "from", "", "время сообщений не раньше (RFC3339 или YYYY-MM-DD); пусто — с начала")
+		fs.StringVar(&to, "to", "", "время сообщений раньше (RFC3339 или YYYY-MM-DD); пусто — до текущего конца")
+		fs.StringVar(&targetDSN, "target-dsn", "", "БД для записи (по умолчанию postgres.dsn)")
+		fs.DurationVar(&every, "progress", 5*time.Second, "период вывода прогресса")
+	})
+	if err != nil {
+		return err
+	}
+	var opts kaf.ReplayOptions
+	if opts.From, err = parseTimeFlag(from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if opts.To, err = parseTimeFlag(to); err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	if targetDSN == "" {
+		targetDSN = cfg.Postgres.DSN
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg, targetDSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	sec, err := kaf.NewSecurity(cfg.Kafka)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	var schemas *kaf.FileRegistry
+	if cfg.Kafka.SchemaDir != "" {
+		if schemas, err = kaf.NewFileRegistry(cfg.Kafka.SchemaDir); err != nil {
+			log.Printf("kafka: avro disabled: %v", err)
+		}
+	}
+	opts.Codecs = kaf.NewCodecs(schemas)
+
+	admin := kaf.NewAdmin(cfg.Kafka.Brokers, cfg.Kafka.Topic, sec)
+	replay, err := admin.NewReplay(ctx, store, opts)
+	if err != nil {
+		return err
+	}
+	return replay.Watch(ctx, every, logReplayProgress)
+}
+
+func logReplayProgress(parts []kaf.ReplayProgress) {
+	var saved, skipped, done int
+	var left int64
+	for _, p := range parts {
+		saved += p.Saved
+		skipped += p.Skipped
+		if p.Done {
+			done++
+		} else {
+			left += p.End - p.Offset
+		}
+	}
+	log.Printf("kafka replay: saved=%d skipped=%d partitions done %d/%d, ~%d messages left",
+		saved, skipped, done, len(parts), left)
+}
+
+func parseTimeFlag(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
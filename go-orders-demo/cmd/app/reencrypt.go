@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-orders-demo/internal/config"
+)
+
+// runReencrypt — подкоманда reencrypt: после смены основного ключа кольца перешифровывает
+// персональные данные (и незашифрованные, записанные до включения шифрования) основным ключом
+// и пересчитывает blind index. Идёт пачками по order_uid, каждая пачка — своя транзакция,
+// поэтому прерванный запуск можно продолжить с -after. Затем так же, пачками по id,
+// перешифровываются outbox и webhook_deliveries; там берутся только строки не под основным ключом.
+func runReencrypt(args []string) error {
+	var after string
+	var batch int
+	var dryRun bool
+	cfg, err := config.LoadCommand("reencrypt", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&after, "after", "", "начать после этого order_uid")
+		fs.IntVar(&batch, "batch", 500, "заказов в одной транзакции")
+		fs.BoolVar(&dryRun, "dry-run", false, "только посчитать, что будет перешифровано")
+	})
+	if err != nil {
+		return err
+	}
+	if !cfg.Encryption.Enabled {
+		return errors.New("encryption is not enabled (encryption.enabled, encryption.keyring_file)")
+	}
+	if batch <= 0 {
+		return errors.New("-batch must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg, cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	var scanned, orders, deliveries, outbox, webhooks int
+	for {
+		last, st, err := store.ReencryptBatch(ctx, after, batch, dryRun)
+		if err != nil {
+			return fmt.Errorf("after %q: %w", after, err)
+		}
+		scanned += st.Scanned
+		orders += st.Orders
+		deliveries += st.Deliveries
+		if last == "" {
+			break
+		}
+		after = last
+		log.Printf("reencrypt: scanned=%d orders=%d deliveries=%d last=%s", scanned, orders, deliveries, last)
+	}
+	for _, t := range []struct {
+		name  string
+		n     *int
+		batch func(ctx context.Context, after int64, limit int, dryRun bool) (int64, int, error)
+	}{
+		{"outbox", &outbox, store.ReencryptOutboxBatch},
+		{"webhook_deliveries", &webhooks, store.ReencryptWebhookBatch},
+	} {
+		var after int64
+		for {
+			last, n, err := t.batch(ctx, after, batch, dryRun)
+			if err != nil {
+				return fmt.Errorf("%s after id %d: %w", t.name, after, err)
+			}
+			*t.n += n
+			if last == 0 {
+				break
+			}
+			after = last
+			log.Printf("reencrypt: %s=%d last id=%d", t.name, *t.n, last)
+		}
+	}
+	verb := "rewritten"
+	if dryRun {
+		verb = "to rewrite"
+	}
+	log.Printf("reencrypt: done, scanned=%d; %s: orders=%d deliveries=%d outbox=%d webhook_deliveries=%d",
+		scanned, verb, orders, deliveries, outbox, webhooks)
+	return nil
+}
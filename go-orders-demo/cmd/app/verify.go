@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/verify"
+)
+
+// runVerify — подкоманда verify: сверка orders.payload с нормализованными таблицами.
+// Расхождения печатаются в stdout как NDJSON, итог — в лог.
+func runVerify(args []string) error {
+	var repair, orderUID string
+	var batch int
+	var missing bool
+	cfg, err := config.LoadCommand("verify", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&repair, "repair", "", "восстановить: tables (таблицы по payload) или payload (payload по таблицам)")
+		fs.StringVar(&orderUID, "order-uid", "", "проверить только этот заказ")
+		fs.IntVar(&batch, "batch", 500, "заказов в одной выборке")
+		fs.BoolVar(&missing, "missing", false, "сообщать о заказах без нормализованных строк (с -repair tables — заполнить их)")
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg, cfg.Postgres.DSN)
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	st, err := verify.Run(ctx, store, verify.Options{
+		Repair:    repair,
+		BatchSize: batch,
+		OrderUID:  orderUID,
+		Missing:   missing,
+	}, func(r verify.Report) { enc.Encode(r) })
+	log.Printf("verify: checked=%d mismatched=%d tables_missing=%d repaired=%d failed=%d",
+		st.Checked, st.Mismatched, st.TablesMissing, st.Repaired, st.Failed)
+	if err != nil {
+		return err
+	}
+	if st.Failed > 0 {
+		return fmt.Errorf("%d orders were not repaired", st.Failed)
+	}
+	return nil
+}
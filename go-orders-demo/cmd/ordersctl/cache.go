@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// runCache — cache stats|evict order_uid...|warm через /admin/cache (роль admin)
+func runCache(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ordersctl cache stats|evict|warm [flags]")
+	}
+	sub := args[0]
+	posArgs := ""
+	switch sub {
+	case "stats", "warm":
+	case "evict":
+		posArgs = "order_uid..."
+	default:
+		return fmt.Errorf("unknown cache command %q (want stats, evict or warm)", sub)
+	}
+	var api apiFlags
+	var out string
+	fs := newFlagSet("cache "+sub, posArgs, &api, &out)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := checkOutput(out); err != nil {
+		return err
+	}
+	c, err := api.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch sub {
+	case "stats":
+		st, err := c.CacheStats(ctx)
+		if err != nil {
+			return err
+		}
+		if out == outputJSON {
+			return printJSON(st)
+		}
+		limit := strconv.Itoa(st.Limit)
+		if st.Limit == 0 {
+			limit = "unlimited"
+		}
+		hitRate := ""
+		if total := st.Hits + st.Misses; total > 0 {
+			hitRate = fmt.Sprintf("%.1f%%", float64(st.Hits)*100/float64(total))
+		}
+		return printTable([]string{"ENTRIES", "LIMIT", "HITS", "MISSES", "HIT_RATE"}, [][]string{{
+			strconv.Itoa(st.Entries), limit, strconv.FormatInt(st.Hits, 10), strconv.FormatInt(st.Misses, 10), hitRate,
+		}})
+	case "evict":
+		ids := fs.Args()
+		if len(ids) == 0 {
+			return errors.New("no order_uid given")
+		}
+		for _, id := range ids {
+			if err := c.EvictCache(ctx, id); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		if out == outputJSON {
+			return printJSON(map[string][]string{"evicted": ids})
+		}
+		rows := make([][]string, len(ids))
+		for i, id := range ids {
+			rows[i] = []string{id}
+		}
+		return printTable([]string{"EVICTED"}, rows)
+	case "warm":
+		res, err := c.WarmCache(ctx)
+		if err != nil {
+			return err
+		}
+		if out == outputJSON {
+			return printJSON(res)
+		}
+		return printTable([]string{"LOADED", "ENTRIES"}, [][]string{{strconv.Itoa(res.Loaded), strconv.Itoa(res.Entries)}})
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// runDLQ — dlq list|redrive: dead letters сервиса — webhook доставки в статусе failed,
+// исчерпавшие webhooks.max_attempts. Отдельной DLQ у Kafka consumer нет: сообщения,
+// которые не удалось разобрать, пропускаются с записью в лог.
+func runDLQ(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ordersctl dlq list|redrive [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return runDLQList(args[1:])
+	case "redrive":
+		return runDLQRedrive(args[1:])
+	}
+	return fmt.Errorf("unknown dlq command %q (want list or redrive)", args[0])
+}
+
+func runDLQList(args []string) error {
+	var out string
+	var webhookID int64
+	var limit int
+	cfg, _, err := loadCommand("dlq list", "", args, &out, func(fs *flag.FlagSet) {
+		fs.Int64Var(&webhookID, "webhook", 0, "только доставки этой подписки")
+		fs.IntVar(&limit, "limit", 100, "не больше доставок, новые первыми")
+	})
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	ds, err := store.FailedWebhookDeliveries(context.Background(), webhookID, limit)
+	if err != nil {
+		return err
+	}
+	if out == outputJSON {
+		if ds == nil {
+			return printJSON([]any{})
+		}
+		return printJSON(ds)
+	}
+	rows := make([][]string, len(ds))
+	for i, d := range ds {
+		code := ""
+		if d.LastStatusCode != 0 {
+			code = strconv.Itoa(d.LastStatusCode)
+		}
+		rows[i] = []string{
+			strconv.FormatInt(d.ID, 10), strconv.FormatInt(d.WebhookID, 10), d.EventType, d.EventID,
+			strconv.Itoa(d.Attempts), code, d.LastError, d.CreatedAt.UTC().Format(time.RFC3339),
+		}
+	}
+	return printTable([]string{"ID", "WEBHOOK", "EVENT_TYPE", "EVENT_ID", "ATTEMPTS", "LAST_STATUS", "LAST_ERROR", "CREATED"}, rows)
+}
+
+// runDLQRedrive возвращает доставки в очередь: воркер webhooks повторит их с обнулённым счётчиком попыток
+func runDLQRedrive(args []string) error {
+	var out string
+	var webhookID int64
+	var all bool
+	cfg, fs, err := loadCommand("dlq redrive", "[delivery_id...]", args, &out, func(fs *flag.FlagSet) {
+		fs.Int64Var(&webhookID, "webhook", 0, "только доставки этой подписки")
+		fs.BoolVar(&all, "all", false, "все failed доставки (с -webhook — этой подписки)")
+	})
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for _, a := range fs.Args() {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid delivery id %q", a)
+		}
+		ids = append(ids, id)
+	}
+	if all == (len(ids) > 0) {
+		return errors.New("give delivery ids or -all")
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	n, err := store.RedriveWebhookDeliveries(context.Background(), webhookID, ids)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 && n < int64(len(ids)) {
+		log.Printf("dlq redrive: %d of %d deliveries are not failed, skipped", int64(len(ids))-n, len(ids))
+	}
+	if out == outputJSON {
+		return printJSON(map[string]int64{"requeued": n})
+	}
+	return printTable([]string{"REQUEUED"}, [][]string{{strconv.FormatInt(n, 10)}})
+}
@@ -0,0 +1,188 @@
+// Command ordersctl — утилита оператора вместо ручных curl и psql.
+//
+// get, ingest и cache работают через HTTP API сервера (pkg/client);
+// list, dlq, migrate и verify — напрямую с Postgres и принимают те же флаги
+// конфигурации, что и сервер (-config, -postgres-dsn, …).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/keyring"
+	"go-orders-demo/pkg/client"
+)
+
+const usage = `Usage: ordersctl <command> [flags] [args]
+
+Через HTTP API (-api, -api-key или -token):
+  get order_uid...            показать заказы
+  ingest file...              отправить заказы из NDJSON/CSV в /ingest/bulk
+  cache stats|evict|warm      кеш заказов сервера
+
+Напрямую с Postgres (флаги конфигурации сервера):
+  list                        заказы по фильтрам, новые первыми
+  dlq list|redrive            webhook доставки, исчерпавшие попытки
+  migrate [status]            применить sql/migrations
+  verify                      сверить payload с нормализованными таблицами
+
+У каждой команды есть -o table|json и -h.
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ordersctl: ")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands := map[string]func([]string) error{
+		"get":     runGet,
+		"list":    runList,
+		"ingest":  runIngest,
+		"cache":   runCache,
+		"dlq":     runDLQ,
+		"migrate": runMigrate,
+		"verify":  runVerify,
+	}
+	name := os.Args[1]
+	run, ok := commands[name]
+	if !ok {
+		if name != "-h" && name != "-help" && name != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+// apiFlags — подключение к HTTP API; по умолчанию из окружения
+type apiFlags struct {
+	url, key, token string
+}
+
+func (a *apiFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.url, "api", envOr("ORDERSCTL_API", "http://localhost:8081"), "адрес HTTP API; env ORDERSCTL_API")
+	fs.StringVar(&a.key, "api-key", os.Getenv("ORDERSCTL_API_KEY"), "API ключ; env ORDERSCTL_API_KEY")
+	fs.StringVar(&a.token, "token", os.Getenv("ORDERSCTL_TOKEN"), "JWT вместо API ключа; env ORDERSCTL_TOKEN")
+}
+
+func (a *apiFlags) client() (*client.Client, error) {
+	var opts []client.Option
+	if a.key != "" {
+		opts = append(opts, client.WithAPIKey(a.key))
+	}
+	if a.token != "" {
+		opts = append(opts, client.WithBearerToken(a.token))
+	}
+	return client.New(a.url, opts...)
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// newFlagSet — флаги команды, работающей через HTTP API
+func newFlagSet(name, args string, api *apiFlags, out *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	api.register(fs)
+	outputFlag(fs, out)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ordersctl %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadCommand — config.LoadCommand с флагом -o; возвращает и набор флагов ради позиционных аргументов
+func loadCommand(name, args string, argv []string, out *string, setup func(fs *flag.FlagSet)) (*config.Config, *flag.FlagSet, error) {
+	var fset *flag.FlagSet
+	cfg, err := config.LoadCommand(name, argv, func(fs *flag.FlagSet) {
+		outputFlag(fs, out)
+		if setup != nil {
+			setup(fs)
+		}
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: ordersctl %s [flags] %s\n", name, args)
+			fs.PrintDefaults()
+		}
+		fset = fs
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, fset, checkOutput(*out)
+}
+
+// openStore подключается к dsn; с encryption.enabled персональные данные расшифровываются кольцом ключей
+func openStore(cfg *config.Config) (*db.SQLStore, error) {
+	store, err := db.NewSQLStore(cfg.Postgres.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("db: %w", err)
+	}
+	if cfg.Encryption.Enabled {
+		kr, err := keyring.Load(cfg.Encryption.KeyringFile)
+		if err != nil {
+			return nil, err
+		}
+		store.WithKeyRing(kr)
+	}
+	return store, nil
+}
+
+// Форматы вывода
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func outputFlag(fs *flag.FlagSet, out *string) {
+	fs.StringVar(out, "o", outputTable, "формат вывода: table или json")
+}
+
+func checkOutput(out string) error {
+	if out != outputTable && out != outputJSON {
+		return fmt.Errorf("unknown output %q (want table or json)", out)
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printJSONLine — одна строка NDJSON для длинных потоковых отчётов
+func printJSONLine(v any) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// printTable печатает колонки, выровненные пробелами; пустые значения — "-"
+func printTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		for i, v := range row {
+			if v == "" {
+				row[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/sql/migrations"
+)
+
+// runMigrate — migrate [status]: применяет sql/migrations (встроены в бинарник) и отмечает их
+// в schema_migrations. Сервер применяет их и сам при старте, если не выключен postgres.migrate.
+func runMigrate(args []string) error {
+	var out, dir string
+	cfg, fset, err := loadCommand("migrate", "[status]", args, &out, func(fs *flag.FlagSet) {
+		fs.StringVar(&dir, "dir", "", "каталог с миграциями вместо встроенных")
+	})
+	if err != nil {
+		return err
+	}
+	statusOnly := false
+	switch rest := fset.Args(); {
+	case len(rest) == 0:
+	case len(rest) == 1 && rest[0] == "status":
+		statusOnly = true
+	default:
+		return fmt.Errorf("unexpected arguments %v (want nothing or status)", rest)
+	}
+	var src fs.FS = migrations.FS
+	if dir != "" {
+		src = os.DirFS(dir)
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var list []db.Migration
+	if statusOnly {
+		list, err = store.Migrations(ctx, src)
+	} else {
+		list, err = store.Migrate(ctx, src)
+		log.Printf("migrate: applied %d migrations", len(list))
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == outputJSON {
+		if list == nil {
+			list = []db.Migration{}
+		}
+		return printJSON(list)
+	}
+	rows := make([][]string, len(list))
+	for i, m := range list {
+		state, at := "pending", ""
+		if m.AppliedAt != nil {
+			state, at = "applied", m.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		rows[i] = []string{m.Version, state, at}
+	}
+	return printTable([]string{"VERSION", "STATE", "APPLIED_AT"}, rows)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/export"
+	"go-orders-demo/internal/importer"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pii"
+	"go-orders-demo/pkg/client"
+)
+
+// runGet — get order_uid...: заказы через GET /order/{id} (с маской PII по роли ключа)
+func runGet(args []string) error {
+	var api apiFlags
+	var out string
+	fs := newFlagSet("get", "order_uid...", &api, &out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(out); err != nil {
+		return err
+	}
+	ids := fs.Args()
+	if len(ids) == 0 {
+		return errors.New("no order_uid given")
+	}
+	c, err := api.client()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var orders []*client.Order
+	missing := 0
+	for _, id := range ids {
+		o, err := c.GetOrder(ctx, id)
+		if client.IsNotFound(err) {
+			log.Printf("get: %s: not found", id)
+			missing++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		orders = append(orders, o)
+	}
+	switch {
+	case out == outputJSON && len(ids) == 1 && len(orders) == 1:
+		err = printJSON(orders[0])
+	case out == outputJSON:
+		err = printJSON(orders)
+	default:
+		rows := make([][]string, len(orders))
+		for i, o := range orders {
+			rows[i] = orderRow(o)
+		}
+		err = printTable(orderHeader, rows)
+	}
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d of %d orders not found", missing, len(ids))
+	}
+	return nil
+}
+
+var orderHeader = []string{"ORDER_UID", "TRACK_NUMBER", "STATUS", "CUSTOMER", "DELIVERY_SERVICE", "AMOUNT", "ITEMS", "CREATED"}
+
+func orderRow(o *client.Order) []string {
+	amount := ""
+	if o.Payment.Amount != 0 || o.Payment.Currency != "" {
+		amount = strconv.Itoa(o.Payment.Amount) + " " + o.Payment.Currency
+	}
+	created := ""
+	if !o.DateCreated.IsZero() {
+		created = o.DateCreated.UTC().Format(time.RFC3339)
+	}
+	return []string{o.OrderUID, o.TrackNumber, o.Status, o.CustomerID, o.DeliveryService, amount, strconv.Itoa(len(o.Items)), created}
+}
+
+// runList — list: страница заказов из БД по фильтрам, как gRPC ListOrders
+func runList(args []string) error {
+	var out, customerID, deliveryService, phone, email, from, to, pageToken string
+	var limit int
+	var reveal bool
+	cfg, _, err := loadCommand("list", "", args, &out, func(fs *flag.FlagSet) {
+		fs.StringVar(&customerID, "customer-id", "", "только заказы покупателя")
+		fs.StringVar(&deliveryService, "delivery-service", "", "только заказы службы доставки")
+		fs.StringVar(&phone, "phone", "", "только заказы с этим телефоном получателя")
+		fs.StringVar(&email, "email", "", "только заказы с этим e-mail получателя")
+		fs.StringVar(&from, "from", "", "date_created не раньше (RFC3339 или YYYY-MM-DD)")
+		fs.StringVar(&to, "to", "", "date_created раньше (RFC3339 или YYYY-MM-DD)")
+		fs.IntVar(&limit, "limit", 50, "заказов на странице")
+		fs.StringVar(&pageToken, "page-token", "", "продолжить с токена предыдущей страницы")
+		fs.BoolVar(&reveal, "reveal-pii", false, "не маскировать персональные данные при pii.enabled")
+	})
+	if err != nil {
+		return err
+	}
+	f, err := export.ParseFilter(customerID, deliveryService, from, to)
+	if err != nil {
+		return err
+	}
+	f.Phone, f.Email = phone, email
+	if limit <= 0 {
+		return errors.New("-limit must be positive")
+	}
+	f.Limit = limit
+	if pageToken != "" {
+		cur, err := db.DecodeCursor(pageToken)
+		if err != nil {
+			return err
+		}
+		f.After = &cur
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	page, next, err := store.ListOrders(context.Background(), f)
+	if err != nil {
+		return err
+	}
+	if cfg.PII.Enabled && !reveal {
+		rules, _ := pii.ParseRules(cfg.PII.Rules)
+		m := pii.New(rules, nil)
+		for i, raw := range page {
+			if page[i], err = m.Mask(raw); err != nil {
+				return err
+			}
+		}
+	}
+	nextToken := ""
+	if next != nil {
+		nextToken = next.Encode()
+	}
+
+	if out == outputJSON {
+		if page == nil {
+			page = []json.RawMessage{}
+		}
+		return printJSON(struct {
+			Orders        []json.RawMessage `json:"orders"`
+			NextPageToken string            `json:"next_page_token,omitempty"`
+		}{page, nextToken})
+	}
+	rows := make([][]string, 0, len(page))
+	for _, raw := range page {
+		var o client.Order
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return err
+		}
+		rows = append(rows, orderRow(&o))
+	}
+	if err := printTable(orderHeader, rows); err != nil {
+		return err
+	}
+	if nextToken != "" {
+		fmt.Fprintf(os.Stderr, "next page: -page-token %s\n", nextToken)
+	}
+	return nil
+}
+
+// runIngest — ingest file...: заказы из NDJSON/CSV пакетами в POST /ingest/bulk.
+// Файл читается как в app import (проверка, чекпойнт, -rate), но пишется через API:
+// кеш, события и webhooks узнают о заказах как при обычном приёме.
+func runIngest(args []string) error {
+	var api apiFlags
+	var out, format, checkpoint string
+	var rate float64
+	var batch int
+	var dryRun bool
+	fs := newFlagSet("ingest", "file...", &api, &out)
+	fs.StringVar(&format, "format", "", "формат: ndjson или csv (плоский CSV или zip из export; по умолчанию — по расширению файла)")
+	fs.IntVar(&batch, "batch", 500, "заказов в одном запросе")
+	fs.Float64Var(&rate, "rate", 0, "не больше заказов в секунду; 0 — без ограничения")
+	fs.StringVar(&checkpoint, "checkpoint", "", "файл чекпойнта для продолжения после ошибки; только для одного файла")
+	fs.BoolVar(&dryRun, "dry-run", false, "только проверить файлы, ничего не отправляя")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(out); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		return errors.New("no input files")
+	}
+	if checkpoint != "" && len(files) > 1 {
+		return errors.New("-checkpoint needs exactly one input file")
+	}
+	c, err := api.client()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	type fileStats struct {
+		File     string `json:"file"`
+		Imported int    `json:"imported"`
+		Invalid  int    `json:"invalid"`
+		Skipped  int    `json:"skipped"`
+		Line     int    `json:"line"`
+		Error    string `json:"error,omitempty"`
+	}
+	var report []fileStats
+	var runErr error
+	for _, name := range files {
+		opts := importer.Options{Format: format, BatchSize: batch, Rate: rate, Checkpoint: checkpoint, DryRun: dryRun}
+		if opts.Format == "" {
+			opts.Format = importer.FormatByName(name)
+		}
+		st, err := ingestFile(ctx, name, apiSink{c}, opts)
+		fst := fileStats{File: name, Imported: st.Imported, Invalid: st.Invalid, Skipped: st.Skipped, Line: st.Line}
+		if err != nil {
+			fst.Error = err.Error()
+			runErr = fmt.Errorf("%s: %w", name, err)
+		}
+		report = append(report, fst)
+		if err != nil {
+			break
+		}
+	}
+
+	if out == outputJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		rows := make([][]string, len(report))
+		for i, r := range report {
+			rows[i] = []string{r.File, strconv.Itoa(r.Imported), strconv.Itoa(r.Invalid), strconv.Itoa(r.Skipped), strconv.Itoa(r.Line)}
+		}
+		if err := printTable([]string{"FILE", "IMPORTED", "INVALID", "SKIPPED", "LAST_LINE"}, rows); err != nil {
+			return err
+		}
+	}
+	return runErr
+}
+
+func ingestFile(ctx context.Context, name string, sink importer.Sink, opts importer.Options) (importer.Stats, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return importer.Stats{}, err
+	}
+	defer f.Close()
+	return importer.Run(ctx, f, sink, opts)
+}
+
+// apiSink — importer.Sink поверх POST /ingest/bulk
+type apiSink struct {
+	c *client.Client
+}
+
+func (s apiSink) Write(ctx context.Context, orders []models.Order) []error {
+	errs := make([]error, len(orders))
+	fail := func(err error) []error {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+	batch := make([]*client.Order, len(orders))
+	for i, o := range orders {
+		raw, _ := json.Marshal(o)
+		batch[i] = &client.Order{}
+		if err := json.Unmarshal(raw, batch[i]); err != nil {
+			errs[i] = err
+			return fail(errors.New("not sent"))
+		}
+	}
+	rep, err := s.c.IngestBulk(ctx, batch)
+	if err != nil {
+		return fail(err)
+	}
+	seen := make([]bool, len(orders))
+	for _, r := range rep.Results {
+		i := r.Line - 1
+		if i < 0 || i >= len(orders) {
+			continue
+		}
+		seen[i] = true
+		if r.Status != client.BulkAccepted {
+			errs[i] = fmt.Errorf("%s: %s", r.Status, r.Error)
+		}
+	}
+	for i := range orders {
+		if !seen[i] && errs[i] == nil {
+			msg := rep.Error
+			if msg == "" {
+				msg = "no result in bulk report"
+			}
+			errs[i] = errors.New(msg)
+		}
+	}
+	return errs
+}
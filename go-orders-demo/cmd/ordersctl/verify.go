@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go-orders-demo/internal/verify"
+)
+
+// runVerify — verify: сверка orders.payload с нормализованными таблицами, как app verify.
+// В json расхождения печатаются NDJSON по мере проверки, в table — таблицей в конце.
+func runVerify(args []string) error {
+	var out, repair, orderUID string
+	var batch int
+	var missing bool
+	cfg, _, err := loadCommand("verify", "", args, &out, func(fs *flag.FlagSet) {
+		fs.StringVar(&repair, "repair", "", "восстановить: tables (таблицы по payload) или payload (payload по таблицам)")
+		fs.StringVar(&orderUID, "order-uid", "", "проверить только этот заказ")
+		fs.IntVar(&batch, "batch", 500, "заказов в одной выборке")
+		fs.BoolVar(&missing, "missing", false, "сообщать о заказах без нормализованных строк (с -repair tables — заполнить их)")
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	report := func(r verify.Report) {
+		if out == outputJSON {
+			printJSONLine(r)
+			return
+		}
+		fields := make([]string, len(r.Diffs))
+		for i, d := range r.Diffs {
+			fields[i] = d.Field
+		}
+		rows = append(rows, []string{r.OrderUID, r.Problem, strings.Join(fields, ","), r.Repaired, r.Error})
+	}
+	st, err := verify.Run(ctx, store, verify.Options{
+		Repair:    repair,
+		BatchSize: batch,
+		OrderUID:  orderUID,
+		Missing:   missing,
+	}, report)
+	if out == outputTable {
+		if perr := printTable([]string{"ORDER_UID", "PROBLEM", "FIELDS", "REPAIRED", "ERROR"}, rows); perr != nil {
+			return perr
+		}
+	}
+	log.Printf("verify: checked=%d mismatched=%d tables_missing=%d repaired=%d failed=%d",
+		st.Checked, st.Mismatched, st.TablesMissing, st.Repaired, st.Failed)
+	if err != nil {
+		return err
+	}
+	if st.Failed > 0 {
+		return fmt.Errorf("%d orders were not repaired", st.Failed)
+	}
+	return nil
+}
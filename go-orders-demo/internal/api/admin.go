@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	kaf "go-orders-demo/internal/kafka"
+)
+
+// KafkaAdmin — перемотка и replay топика заказов для группы этого сервера (реализуется kafka.GroupAdmin)
+type KafkaAdmin interface {
+	Offsets(ctx context.Context) ([]kaf.PartitionState, error)
+	OffsetsAt(ctx context.Context, t time.Time) ([]kaf.PartitionOffset, error)
+	Rewind(ctx context.Context, offsets []kaf.PartitionOffset) error
+	Replay(ctx context.Context, from, to time.Time, progress func([]kaf.ReplayProgress)) error
+}
+
+// WithKafkaAdmin включает /admin/kafka/offsets, /admin/kafka/rewind и /admin/kafka/replay
+func WithKafkaAdmin(a KafkaAdmin) Option {
+	return func(s *Server) { s.kafkaAdmin = a }
+}
+
+// Состояния replay
+const (
+	replayIdle     = "idle"
+	replayRunning  = "running"
+	replayDone     = "done"
+	replayFailed   = "failed"
+	replayCanceled = "canceled"
+)
+
+// replayJob — текущий (или последний) replay; одновременно идёт не больше одного
+type replayJob struct {
+	mu     sync.Mutex
+	state  replayState
+	cancel context.CancelFunc
+}
+
+type replayState struct {
+	Status     string               `json:"status"`
+	From       *time.Time           `json:"from,omitempty"`
+	To         *time.Time           `json:"to,omitempty"`
+	StartedAt  *time.Time           `json:"started_at,omitempty"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	Saved      int                  `json:"saved"`
+	Skipped    int                  `json:"skipped"`
+	Error      string               `json:"error,omitempty"`
+	Partitions []kaf.ReplayProgress `json:"partitions,omitempty"`
+}
+
+func (j *replayJob) snapshot() replayState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := j.state
+	if st.Status == "" {
+		st.Status = replayIdle
+	}
+	return st
+}
+
+func (j *replayJob) progress(parts []kaf.ReplayProgress) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state.Partitions = parts
+	j.state.Saved, j.state.Skipped = 0, 0
+	for _, p := range parts {
+		j.state.Saved += p.Saved
+		j.state.Skipped += p.Skipped
+	}
+}
+
+// stop отменяет идущий replay (при остановке сервера или DELETE)
+func (j *replayJob) stop() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state.Status != replayRunning {
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// handleKafkaOffsets: GET /admin/kafka/offsets — offsets и lag группы по партициям
+func (s *Server) handleKafkaOffsets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts, err := s.kafkaAdmin.Offsets(r.Context())
+	if err != nil {
+		log.Printf("kafka offsets: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"partitions": parts})
+}
+
+type rewindRequest struct {
+	Timestamp *time.Time            `json:"timestamp"`
+	Offsets   []kaf.PartitionOffset `json:"offsets"`
+}
+
+// handleKafkaRewind: POST /admin/kafka/rewind {"timestamp": RFC3339} или {"offsets": [{"partition","offset"}]}.
+// Consumer этого сервера выходит из группы на время коммита; остальные экземпляры должны быть остановлены.
+func (s *Server) handleKafkaRewind(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req rewindRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if (req.Timestamp == nil) == (len(req.Offsets) == 0) {
+		http.Error(w, "either timestamp or offsets is required", http.StatusBadRequest)
+		return
+	}
+	offsets := req.Offsets
+	if req.Timestamp != nil {
+		var err error
+		if offsets, err = s.kafkaAdmin.OffsetsAt(r.Context(), *req.Timestamp); err != nil {
+			log.Printf("kafka rewind: %v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	for _, o := range offsets {
+		if o.Offset < 0 || o.Partition < 0 {
+			http.Error(w, "partition and offset must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.kafkaAdmin.Rewind(r.Context(), offsets); err != nil {
+		log.Printf("kafka rewind: %v", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("kafka rewind: %v", offsets)
+	writeJSON(w, http.StatusOK, map[string]any{"offsets": offsets})
+}
+
+type replayRequest struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+// handleKafkaReplay: POST — запустить replay окна {"from","to"} в фоне (202),
+// GET — ход текущего или последнего replay, DELETE — отменить
+func (s *Server) handleKafkaReplay(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.replay.snapshot())
+	case http.MethodDelete:
+		if !s.replay.stop() {
+			http.Error(w, "replay is not running", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		var req replayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+		st, ok := s.startReplay(req)
+		if !ok {
+			http.Error(w, "replay is already running", http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, st)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startReplay запускает replay в фоне; его контекст не зависит от запроса
+func (s *Server) startReplay(req replayRequest) (replayState, bool) {
+	j := s.replay
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state.Status == replayRunning {
+		return replayState{}, false
+	}
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	j.state = replayState{Status: replayRunning, From: req.From, To: req.To, StartedAt: &now}
+	j.cancel = cancel
+
+	var from, to time.Time
+	if req.From != nil {
+		from = *req.From
+	}
+	if req.To != nil {
+		to = *req.To
+	}
+	go func() {
+		defer cancel()
+		err := s.kafkaAdmin.Replay(ctx, from, to, j.progress)
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		end := time.Now()
+		j.state.FinishedAt = &end
+		switch {
+		case err == nil:
+			j.state.Status = replayDone
+		case errors.Is(err, context.Canceled):
+			j.state.Status = replayCanceled
+		default:
+			j.state.Status = replayFailed
+			j.state.Error = err.Error()
+		}
+		log.Printf("kafka replay %s: saved=%d skipped=%d %s", j.state.Status, j.state.Saved, j.state.Skipped, j.state.Error)
+	}()
+	return j.state, true
+}
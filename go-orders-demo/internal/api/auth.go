@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/db"
+)
+
+// WithAuth включает аутентификацию (API ключ или JWT) и проверку ролей на маршрутах.
+// audit может быть nil — тогда журнал аудита не ведётся.
+func WithAuth(a *auth.Authenticator, audit auth.Auditor) Option {
+	return func(s *Server) { s.auth, s.audit = a, audit }
+}
+
+// handle регистрирует маршрут, доступный только клиентам с ролью role,
+// с лимитом запросов маршрута. Без WithAuth и WithRateLimit маршрут открыт, как раньше.
+// Порядок: лимит по IP, аутентификация, лимит клиента на маршруте.
+func (s *Server) handle(mux *http.ServeMux, path, role string, h http.HandlerFunc) {
+	var next http.Handler = h
+	if s.limits != nil || s.quotas != nil {
+		next = s.rateLimit(path, next)
+	}
+	if s.auth != nil {
+		next = s.requireRole(role, next)
+	}
+	if s.limits != nil {
+		next = s.ipLimit(next)
+	}
+	mux.Handle(path, next)
+}
+
+func (s *Server) requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, apiKey := credentials(r)
+		p, err := s.auth.Authenticate(r.Context(), authorization, apiKey)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials), errors.Is(err, auth.ErrInvalidCredentials):
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				s.recordAudit(r, auth.Principal{}, http.StatusUnauthorized)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		case err != nil:
+			log.Printf("auth: %v", err)
+			http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !p.Has(role) {
+			s.recordAudit(r, p, http.StatusForbidden)
+			http.Error(w, "forbidden: role "+role+" required", http.StatusForbidden)
+			return
+		}
+		r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		if !audited(r, role) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		s.recordAudit(r, p, rec.status())
+	})
+}
+
+// queryTokenPaths — маршруты, куда браузер не может передать заголовок
+// (EventSource, WebSocket); там ключ или JWT принимаются и в ?access_token=
+var queryTokenPaths = map[string]bool{"/orders/stream": true, "/orders/ws": true}
+
+// credentials достаёт учётные данные из заголовков, а на маршрутах
+// queryTokenPaths при их отсутствии — из параметра access_token
+func credentials(r *http.Request) (authorization, apiKey string) {
+	authorization, apiKey = r.Header.Get("Authorization"), r.Header.Get("X-API-Key")
+	if authorization == "" && apiKey == "" && queryTokenPaths[r.URL.Path] {
+		if tok := r.URL.Query().Get("access_token"); tok != "" {
+			authorization = "Bearer " + tok
+		}
+	}
+	return authorization, apiKey
+}
+
+// audited — изменения, админские действия и выгрузки; обычное чтение не журналируется
+func audited(r *http.Request, role string) bool {
+	return r.Method != http.MethodGet || role == auth.RoleAdmin || r.URL.Path == "/export"
+}
+
+func (s *Server) recordAudit(r *http.Request, p auth.Principal, status int) {
+	if s.audit == nil {
+		return
+	}
+	e := db.AuditEntry{
+		At:         time.Now(),
+		Principal:  p.Subject,
+		AuthMethod: p.Method,
+		Roles:      p.Roles,
+		Action:     r.Method + " " + r.URL.Path,
+		Status:     status,
+		RemoteAddr: remoteHost(r.RemoteAddr),
+	}
+	// запрос мог быть уже отменён клиентом, а запись нужна всё равно
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+	if err := s.audit.RecordAudit(ctx, e); err != nil {
+		log.Printf("audit %s %s: %v", e.Principal, e.Action, err)
+	}
+}
+
+func remoteHost(addr string) string {
+	if i := strings.LastIndexByte(addr, ':'); i > 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+// statusRecorder запоминает код ответа для журнала аудита
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush нужен потоковым ответам (/export, /ingest/bulk)
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func (r *statusRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	kaf "go-orders-demo/internal/kafka"
+)
+
+// WithBulkIngest задаёт размер пакета публикации и лимит размера одного заказа для /ingest/bulk
+func WithBulkIngest(batchSize, maxLine int) Option {
+	return func(s *Server) {
+		s.bulkBatch = batchSize
+		s.bulkMaxLine = maxLine
+	}
+}
+
+// Статусы строк в отчёте /ingest/bulk
+const (
+	bulkAccepted = "accepted"
+	bulkRejected = "rejected" // заказ не прошёл разбор или валидацию
+	bulkFailed   = "failed"   // не удалось записать в Kafka/outbox, можно повторить
+)
+
+type bulkResult struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+type bulkSummary struct {
+	Total    int `json:"total"`
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	Failed   int `json:"failed"`
+}
+
+// bulkIngest копит пакет заказов и пишет отчёт по мере публикации пакетов
+type bulkIngest struct {
+	s       *Server
+	r       *http.Request
+	enc     *json.Encoder
+	flusher http.Flusher
+
+	results []bulkResult // результаты текущего пакета в порядке строк
+	orders  []int        // индексы results с валидными заказами
+	batch   []kaf.Payload
+	summary bulkSummary
+}
+
+// handleBulkIngest принимает JSON массив или NDJSON (заказ на строку), в том числе
+// сжатые gzip или zstd. Тело читается потоком; в ответ — NDJSON с результатом каждой строки
+// и итоговой строкой {"summary":{…}}.
+func (s *Server) handleBulkIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	// распакованное тело не ограничено: оно читается потоком, предел — на одну строку
+	body, err := decodeBody(r, 0)
+	if err != nil {
+		bodyError(w, err)
+		return
+	}
+	defer body.Close()
+	br := bufio.NewReaderSize(body, 64<<10)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	// отчёт идёт, пока читается тело — снимаем дедлайны чтения и записи
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	b := &bulkIngest{s: s, r: r}
+	// HTTP/1.1 сервер на первой записи ответа дочитывает до ~256KB тела и закрывает его.
+	// Без full duplex (HTTP/2 он не нужен) отчёт копится, пока не прочитано всё тело.
+	var report *bytes.Buffer
+	if err := rc.EnableFullDuplex(); err != nil && r.ProtoMajor < 2 {
+		log.Printf("bulk ingest: full duplex: %v; report is buffered until end of body", err)
+		report = &bytes.Buffer{}
+		b.enc = json.NewEncoder(report)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		b.enc = json.NewEncoder(w)
+		b.flusher, _ = w.(http.Flusher)
+	}
+
+	if first == '[' {
+		err = b.readArray(br)
+	} else {
+		err = b.readLines(br)
+	}
+	b.flush()
+	if err != nil {
+		// тело дальше не разобрать: сообщаем причину последней строкой перед итогом
+		b.enc.Encode(map[string]string{"error": err.Error()})
+	}
+	b.enc.Encode(map[string]bulkSummary{"summary": b.summary})
+	if report != nil {
+		w.WriteHeader(http.StatusOK)
+		w.Write(report.Bytes())
+	}
+	log.Printf("bulk ingest: total=%d accepted=%d rejected=%d failed=%d",
+		b.summary.Total, b.summary.Accepted, b.summary.Rejected, b.summary.Failed)
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, br.UnreadByte()
+	}
+}
+
+// readLines разбирает NDJSON; пустые строки пропускаются
+func (b *bulkIngest) readLines(br *bufio.Reader) error {
+	sc := bufio.NewScanner(br)
+	sc.Buffer(make([]byte, 64<<10), b.s.bulkMaxLine)
+	line := 0
+	for sc.Scan() {
+		line++
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		b.add(line, data)
+		if err := b.r.Context().Err(); err != nil {
+			return err
+		}
+	}
+	if errors.Is(sc.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d: longer than %d bytes", line+1, b.s.bulkMaxLine)
+	}
+	return sc.Err()
+}
+
+// readArray разбирает JSON массив поэлементно, не читая его целиком
+func (b *bulkIngest) readArray(br *bufio.Reader) error {
+	dec := json.NewDecoder(br)
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for line := 1; dec.More(); line++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// синтаксическая ошибка — дальше массив не разобрать
+			return fmt.Errorf("element %d: %w", line, err)
+		}
+		if len(raw) > b.s.bulkMaxLine {
+			b.reject(line, "", fmt.Sprintf("larger than %d bytes", b.s.bulkMaxLine))
+			continue
+		}
+		b.add(line, raw)
+		if err := b.r.Context().Err(); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// add проверяет заказ и ставит его в пакет; полный пакет сразу публикуется
+func (b *bulkIngest) add(line int, data []byte) {
+	order, err := decodeOrder(bytes.NewReader(data), b.s.strictJSON)
+	if err != nil {
+		b.reject(line, "", err.Error())
+		return
+	}
+	if err := order.Validate(); err != nil {
+		b.reject(line, order.OrderUID, err.Error())
+		return
+	}
+	payload, err := json.Marshal(order)
+	if err != nil {
+		b.reject(line, order.OrderUID, err.Error())
+		return
+	}
+	b.orders = append(b.orders, len(b.results))
+	b.results = append(b.results, bulkResult{Line: line, OrderUID: order.OrderUID})
+	b.batch = append(b.batch, kaf.Payload{Key: order.OrderUID, Value: payload})
+	b.flushIfFull()
+}
+
+func (b *bulkIngest) reject(line int, id, msg string) {
+	b.results = append(b.results, bulkResult{Line: line, OrderUID: id, Status: bulkRejected, Error: msg})
+	b.flushIfFull()
+}
+
+// flushIfFull ограничивает и пакет, и буфер отчёта (в том числе из одних отказов)
+func (b *bulkIngest) flushIfFull() {
+	if len(b.results) >= b.s.bulkBatch {
+		b.flush()
+	}
+}
+
+// flush публикует накопленный пакет и пишет результаты его строк
+func (b *bulkIngest) flush() {
+	if len(b.batch) > 0 {
+		var errs []error
+		if quota, err := b.s.reserveQuota(b.r.Context(), len(b.batch)); err != nil {
+			// квота списывается пакетом целиком: при нехватке не публикуется весь пакет
+			errs = make([]error, len(b.batch))
+			for i := range errs {
+				errs[i] = err
+			}
+		} else {
+			errs = b.publish()
+			failed := 0
+			for _, err := range errs {
+				if err != nil {
+					failed++
+				}
+			}
+			b.s.releaseQuota(b.r.Context(), quota, failed)
+		}
+		for i, ri := range b.orders {
+			if errs[i] != nil {
+				log.Printf("bulk ingest %s: %v", b.batch[i].Key, errs[i])
+				b.results[ri].Status = bulkFailed
+				b.results[ri].Error = errs[i].Error()
+			} else {
+				b.results[ri].Status = bulkAccepted
+			}
+		}
+	}
+	for _, res := range b.results {
+		b.summary.Total++
+		switch res.Status {
+		case bulkAccepted:
+			b.summary.Accepted++
+		case bulkRejected:
+			b.summary.Rejected++
+		case bulkFailed:
+			b.summary.Failed++
+		}
+		b.enc.Encode(res)
+	}
+	if b.flusher != nil && len(b.results) > 0 {
+		b.flusher.Flush()
+	}
+	b.results, b.orders, b.batch = b.results[:0], b.orders[:0], b.batch[:0]
+}
+
+// publish: в режиме outbox каждый заказ пишется в outbox отдельно,
+// иначе пакет уходит в Kafka одним WriteMessages
+func (b *bulkIngest) publish() []error {
+	ctx := b.r.Context()
+	if b.s.outbox == nil {
+		return b.s.prod.ProduceBatch(ctx, b.batch)
+	}
+	errs := make([]error, len(b.batch))
+	for i, p := range b.batch {
+		errs[i] = b.s.outbox.EnqueueOutbox(ctx, p.Key, p.Value)
+	}
+	return errs
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"go-orders-demo/internal/cache"
+	"go-orders-demo/internal/db"
+)
+
+// handleCacheStats: GET /admin/cache — заполненность кеша и попадания
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.cache.Stats())
+}
+
+// handleCacheEntry: POST /admin/cache/warm — догрузить кеш последними заказами из БД (как при старте);
+// DELETE /admin/cache/{order_uid} — убрать заказ из кеша, следующий GET прочитает его из БД
+func (s *Server) handleCacheEntry(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/admin/cache/")
+	switch {
+	case id == "warm" && r.Method == http.MethodPost:
+		st := s.cache.Stats()
+		all, err := s.db.LoadAllRaw(r.Context(), st.Limit)
+		if err != nil {
+			log.Printf("warm cache: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.cache.BulkLoad(versions(all))
+		log.Printf("warm cache: loaded %d orders", len(all))
+		writeJSON(w, http.StatusOK, map[string]int{"loaded": len(all), "entries": s.cache.Stats().Entries})
+	case id == "" || strings.Contains(id, "/"):
+		http.NotFound(w, r)
+	case r.Method == http.MethodDelete:
+		s.cache.Delete(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// versions — заказы из db.LoadAllRaw для cache.BulkLoad
+func versions(all map[string]db.RawOrder) map[string]cache.Version {
+	res := make(map[string]cache.Version, len(all))
+	for id, o := range all {
+		res[id] = cache.Version(o)
+	}
+	return res
+}
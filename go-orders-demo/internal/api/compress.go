@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"go-orders-demo/internal/cache"
+)
+
+// Content-Encoding ответов в порядке предпочтения при равном q
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// CompressionOptions — сжатие ответов gzip/brotli
+type CompressionOptions struct {
+	// MinBytes — ответы короче отдаются несжатыми: заголовки сжатия съедят выигрыш
+	MinBytes int
+}
+
+// encoder — потоковый компрессор с Flush для NDJSON и CSV выгрузок
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Пулы компрессоров для ответов, которые сжимаются на лету; уровни — компромисс со скоростью
+var encoders = map[string]*sync.Pool{
+	encodingGzip: {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	encodingBrotli: {New: func() any { return brotli.NewWriterLevel(nil, 4) }},
+}
+
+// precompress сжимает JSON заказа для кеша: сжимается один раз на версию, поэтому уровень максимальный
+func precompress(encoding string, raw json.RawMessage) ([]byte, error) {
+	var body bytes.Buffer
+	if err := json.Compact(&body, raw); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == encodingBrotli {
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	} else {
+		w, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptEncoding выбирает br или gzip по Accept-Encoding с учётом q; "" — без сжатия
+func acceptEncoding(header string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if weight, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		q[name] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{encodingBrotli, encodingGzip} {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+	return best
+}
+
+// compress сжимает ответы длиннее MinBytes. Ответы с уже выставленным Content-Encoding
+// (заказы из кеша), SSE и WebSocket проходят как есть.
+func compress(o CompressionOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := acceptEncoding(r.Header.Get("Accept-Encoding"))
+		if enc == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: enc, min: o.MinBytes}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter копит начало ответа до min байт и только тогда решает, сжимать ли его
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	min      int
+
+	code    int
+	buf     []byte
+	decided bool    // заголовки отправлены
+	enc     encoder // nil — ответ идёт без сжатия
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.decided || c.code != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.code = code
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	if !c.decided {
+		if !c.compressible() {
+			c.start(false)
+		} else {
+			c.buf = append(c.buf, p...)
+			if len(c.buf) < c.min {
+				return len(p), nil
+			}
+			return len(p), c.start(true)
+		}
+	}
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush у потокового ответа: ждать min байт нельзя, решаем сразу
+func (c *compressWriter) Flush() {
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	if !c.decided {
+		c.start(c.compressible())
+	}
+	if c.enc != nil {
+		c.enc.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+func (c *compressWriter) compressible() bool {
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" || c.code == http.StatusNoContent || c.code == http.StatusNotModified {
+		return false
+	}
+	return !strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+}
+
+// start отправляет заголовки и накопленное начало ответа
+func (c *compressWriter) start(compressed bool) error {
+	c.decided = true
+	h := c.Header()
+	if c.compressible() {
+		// ответ зависит от Accept-Encoding, даже если этот оказался коротким
+		addVary(h, "Accept-Encoding")
+	}
+	if compressed {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", c.encoding)
+		c.enc = encoders[c.encoding].Get().(encoder)
+		c.enc.Reset(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.code)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	buf := c.buf
+	c.buf = nil
+	if c.enc != nil {
+		_, err := c.enc.Write(buf)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// close дописывает короткий ответ без сжатия или закрывает компрессор
+func (c *compressWriter) close() {
+	if !c.decided {
+		if c.code == 0 {
+			return
+		}
+		c.start(false)
+	}
+	if c.enc != nil {
+		c.enc.Close()
+		c.enc.Reset(io.Discard)
+		encoders[c.encoding].Put(c.enc)
+		c.enc = nil
+	}
+}
+
+// cacheControl — Cache-Control заказа: private, потому что ответ зависит от роли (маска PII);
+// без max-age клиент перепроверяет заказ через If-Modified-Since
+func (s *Server) cacheControl() string {
+	if s.httpOpts.OrderMaxAge <= 0 {
+		return "private, no-cache"
+	}
+	return "private, max-age=" + strconv.Itoa(int(s.httpOpts.OrderMaxAge/time.Second))
+}
+
+// addVary дописывает в Vary заголовки, которых там ещё нет
+func addVary(h http.Header, names ...string) {
+	have := strings.Join(h.Values("Vary"), ",")
+	for _, name := range names {
+		found := false
+		for _, v := range strings.Split(have, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Add("Vary", name)
+			have += "," + name
+		}
+	}
+}
+
+// notModified — заказ не менялся с If-Modified-Since (точность заголовка — секунда)
+func notModified(r *http.Request, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// writePrecompressed отдаёт заказ целиком в JSON сжатой копией из кеша. false — запрос
+// другого вида (формат, ?fields=, pretty, маска), его отдаёт writeOrder.
+func (s *Server) writePrecompressed(w http.ResponseWriter, r *http.Request, e *cache.Entry) bool {
+	o := s.httpOpts.Compression
+	if o == nil || len(e.Raw) < o.MinBytes || r.URL.Query().Has("fields") {
+		return false
+	}
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	if !ok || rep.media != mediaJSON || rep.pretty {
+		return false
+	}
+	enc := acceptEncoding(r.Header.Get("Accept-Encoding"))
+	if enc == "" {
+		return false
+	}
+	body, err := e.Encoded(enc, func(raw json.RawMessage) ([]byte, error) { return precompress(enc, raw) })
+	if err != nil {
+		http.Error(w, "stored order is not valid JSON", http.StatusInternalServerError)
+		return true
+	}
+	h := w.Header()
+	addVary(h, "Accept", "Accept-Encoding")
+	h.Set("Content-Type", mediaJSON)
+	h.Set("Content-Encoding", enc)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+	return true
+}
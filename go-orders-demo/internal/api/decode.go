@@ -0,0 +1,143 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"go-orders-demo/internal/models"
+)
+
+// zstdMaxWindow — окно zstd, больше которого поток не распаковывается (память на запрос)
+const zstdMaxWindow = 8 << 20
+
+// errUnsupportedEncoding — Content-Encoding, который сервер не распаковывает
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// WithIngestDecoding задаёт предел тела POST /ingest (и сжатого, и после распаковки)
+// и строгий разбор заказов: с disallowUnknown заказ с неизвестным полем отклоняется
+func WithIngestDecoding(maxBody int64, disallowUnknown bool) Option {
+	return func(s *Server) {
+		s.ingestMaxBody = maxBody
+		s.strictJSON = disallowUnknown
+	}
+}
+
+// bodyTooLargeError — распакованное тело больше предела
+type bodyTooLargeError struct{ limit int64 }
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds %d bytes", e.limit)
+}
+
+// tooLarge возвращает превышенный предел, если err — превышение размера тела
+// (сжатого — http.MaxBytesReader, распакованного — limitReader)
+func tooLarge(err error) (int64, bool) {
+	var mb *http.MaxBytesError
+	if errors.As(err, &mb) {
+		return mb.Limit, true
+	}
+	var dl *bodyTooLargeError
+	if errors.As(err, &dl) {
+		return dl.limit, true
+	}
+	return 0, false
+}
+
+// limitReader — io.LimitReader, который на превышении возвращает bodyTooLargeError, а не EOF
+type limitReader struct {
+	r     io.Reader
+	n     int64 // сколько байт ещё можно прочитать
+	limit int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, &bodyTooLargeError{l.limit}
+	}
+	// читаем на байт больше остатка, чтобы заметить превышение
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+	n, l.n = int(l.n), -1
+	return n, &bodyTooLargeError{l.limit}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decodeBody распаковывает тело запроса по Content-Encoding (gzip, zstd); limit > 0
+// ограничивает размер после распаковки, чтобы маленькое сжатое тело не раздулось в памяти
+func decodeBody(r *http.Request, limit int64) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		body = r.Body
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		body = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+		body = zr.IOReadCloser()
+	default:
+		return nil, fmt.Errorf("%w %q", errUnsupportedEncoding, enc)
+	}
+	if limit > 0 {
+		body = readCloser{&limitReader{r: body, n: limit, limit: limit}, body}
+	}
+	return body, nil
+}
+
+// decodeOrder разбирает один заказ потоком; после него в теле допустимы только пробелы.
+// strict отклоняет поля, которых нет в models.Order.
+func decodeOrder(r io.Reader, strict bool) (models.Order, error) {
+	var order models.Order
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&order); err != nil {
+		if _, ok := tooLarge(err); ok {
+			return order, err
+		}
+		return order, fmt.Errorf("invalid JSON format: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if _, ok := tooLarge(err); ok {
+			return order, err
+		}
+		return order, errors.New("invalid JSON format: unexpected data after order")
+	}
+	return order, nil
+}
+
+// bodyError отвечает на ошибку чтения тела: 413 — больше предела, 415 — неизвестное сжатие, иначе 400
+func bodyError(w http.ResponseWriter, err error) {
+	if limit, ok := tooLarge(err); ok {
+		http.Error(w, fmt.Sprintf("request body too large: limit is %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errUnsupportedEncoding) {
+		http.Error(w, err.Error()+": use gzip or zstd", http.StatusUnsupportedMediaType)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/export"
+)
+
+// Exporter — согласованный снимок заказов для выгрузки (реализуется db.SQLStore)
+type Exporter interface {
+	BeginExport(ctx context.Context) (*db.ExportTx, error)
+}
+
+// WithExport включает GET /export
+func WithExport(e Exporter) Option {
+	return func(s *Server) { s.exporter = e }
+}
+
+// handleExport: GET /export?format=ndjson|csv|parquet&customer_id=&delivery_service=&phone=&email=&created_from=&created_to=
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = export.FormatNDJSON
+	}
+	switch format {
+	case export.FormatNDJSON, export.FormatCSV, export.FormatParquet:
+	default:
+		http.Error(w, "format must be ndjson, csv or parquet", http.StatusBadRequest)
+		return
+	}
+	f, err := export.ParseFilter(q.Get("customer_id"), q.Get("delivery_service"), q.Get("created_from"), q.Get("created_to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Phone, f.Email = q.Get("phone"), q.Get("email")
+
+	tx, err := s.exporter.BeginExport(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Close()
+
+	// выгрузка может идти дольше таймаутов сервера
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.FileName(format)+`"`)
+	src := export.Masked(tx, s.pii.For(r.Context()))
+	n, err := export.Write(r.Context(), w, format, src, f)
+	if err != nil {
+		// заголовки уже отправлены — клиент увидит обрезанный файл
+		log.Printf("export %s: stopped after %d orders: %v", format, n, err)
+		return
+	}
+	log.Printf("export %s: %d orders", format, n)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/events"
+)
+
+// CustomerData — выгрузка и удаление данных покупателя (реализуется db.SQLStore)
+type CustomerData interface {
+	CustomerBundle(ctx context.Context, customerID string) (db.CustomerBundle, error)
+	EraseCustomer(ctx context.Context, customerID, mode string, beforeCommit func(ids []string) error) ([]string, error)
+}
+
+// Notify — доставка события об удалении (events.Publisher.Publish, webhooks.Service.Enqueue)
+type Notify func(ctx context.Context, e events.Envelope) error
+
+type gdpr struct {
+	data CustomerData
+	// publish вызывается до фиксации удаления: если событие не ушло, данные не удаляются
+	publish Notify
+	// notify — после фиксации, ошибки только в лог
+	notify []Notify
+}
+
+// WithGDPR включает GET /admin/customers/{customer_id}/export и POST /admin/customers/{customer_id}/erase.
+// publish получает OrderErased по каждому заказу до фиксации удаления, notify — после.
+func WithGDPR(data CustomerData, publish Notify, notify ...Notify) Option {
+	return func(s *Server) { s.gdpr = &gdpr{data: data, publish: publish, notify: notify} }
+}
+
+// handleCustomer: /admin/customers/{customer_id}/export|erase
+func (s *Server) handleCustomer(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), "/admin/customers/")
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	customerID, err := url.PathUnescape(rest[:i])
+	if err != nil || customerID == "" {
+		http.Error(w, "invalid customer_id", http.StatusBadRequest)
+		return
+	}
+	switch rest[i+1:] {
+	case "export":
+		s.handleCustomerExport(w, r, customerID)
+	case "erase":
+		s.handleCustomerErase(w, r, customerID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleCustomerExport: GET — все заказы и доставки покупателя одним JSON, без маски
+func (s *Server) handleCustomerExport(w http.ResponseWriter, r *http.Request, customerID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := s.gdpr.data.CustomerBundle(r.Context(), customerID)
+	if err != nil {
+		log.Printf("customer export: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="customer.json"`)
+	writeJSON(w, http.StatusOK, struct {
+		ExportedAt time.Time `json:"exported_at"`
+		db.CustomerBundle
+	}{time.Now().UTC(), b})
+}
+
+type eraseResponse struct {
+	CustomerID string   `json:"customer_id"`
+	Mode       string   `json:"mode"`
+	Orders     []string `json:"orders"`
+}
+
+// handleCustomerErase: POST ?mode=anonymize|delete — обезличить или удалить заказы покупателя,
+// убрать их из кеша и буфера живой ленты и опубликовать OrderErased по каждому
+func (s *Server) handleCustomerErase(w http.ResponseWriter, r *http.Request, customerID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = db.EraseAnonymize
+	}
+	if mode != db.EraseAnonymize && mode != db.EraseDelete {
+		http.Error(w, "mode must be anonymize or delete", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var envs []events.Envelope
+	errPublish := errors.New("publish")
+	ids, err := s.gdpr.data.EraseCustomer(ctx, customerID, mode, func(ids []string) error {
+		now := time.Now()
+		for _, id := range ids {
+			e, err := events.Erased(events.Erasure{OrderUID: id, CustomerID: customerID, Mode: mode}, now)
+			if err != nil {
+				return err
+			}
+			if s.gdpr.publish != nil {
+				if err := s.gdpr.publish(ctx, e); err != nil {
+					log.Printf("customer erase: publish %s: %v", id, err)
+					return errPublish
+				}
+			}
+			envs = append(envs, e)
+		}
+		return nil
+	})
+	if errors.Is(err, errPublish) {
+		http.Error(w, "erasure event was not published, nothing erased; retry later", http.StatusBadGateway)
+		return
+	}
+	if err != nil {
+		log.Printf("customer erase: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, id := range ids {
+		s.cache.Delete(id)
+	}
+	if s.stream != nil {
+		s.stream.Forget(ids...)
+	}
+	for _, e := range envs {
+		for _, n := range s.gdpr.notify {
+			if err := n(ctx, e); err != nil {
+				log.Printf("customer erase: notify %s: %v", e.OrderUID, err)
+			}
+		}
+	}
+	log.Printf("customer erase: %s %d orders", mode, len(ids))
+	if ids == nil {
+		ids = []string{}
+	}
+	writeJSON(w, http.StatusOK, eraseResponse{CustomerID: customerID, Mode: mode, Orders: ids})
+}
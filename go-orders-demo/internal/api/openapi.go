@@ -0,0 +1,48 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec — описание HTTP API; pkg/client и контрактные тесты сверяются с ним
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage — Swagger UI для /openapi.json; сам UI грузится с CDN
+const docsPage = `<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>go-orders-demo API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// serveOpenAPI: GET /openapi.json — без аутентификации, как и страница /docs
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,9 @@
+package api
+
+import "go-orders-demo/internal/pii"
+
+// WithPII включает маскирование персональных данных в ответах (GET /order, SSE,
+// WebSocket, /export) для клиентов без роли, которой разрешено их видеть
+func WithPII(m *pii.Masker) Option {
+	return func(s *Server) { s.pii = m }
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/ratelimit"
+)
+
+// WithRateLimit включает лимиты запросов по маршрутам и дневные квоты приёма заказов.
+// Любой из аргументов может быть nil.
+func WithRateLimit(limits *ratelimit.Limits, quotas *ratelimit.Quotas) Option {
+	return func(s *Server) { s.limits, s.quotas = limits, quotas }
+}
+
+// rateLimit ограничивает запросы клиента к маршруту path. Клиент — аутентифицированный
+// principal (имя API ключа или sub токена), без аутентификации — IP адрес.
+func (s *Server) rateLimit(path string, next http.Handler) http.Handler {
+	var lim *ratelimit.Limiter
+	if s.limits != nil {
+		lim = s.limits.For(path)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := remoteHost(r.RemoteAddr)
+		if p, ok := auth.FromContext(r.Context()); ok {
+			client = p.Subject
+		}
+		if lim != nil {
+			ok, wait := lim.Allow(client)
+			ratelimit.Observe(path, ok)
+			if !ok {
+				tooMany(w, wait, "rate limit exceeded")
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ratelimit.WithClient(r.Context(), client)))
+	})
+}
+
+// ipLimit ограничивает запросы с одного IP по всем маршрутам. Стоит перед
+// аутентификацией: запросы с неверным ключом или вовсе без него тоже считаются
+// и до поиска ключа в БД не доходят.
+func (s *Server) ipLimit(next http.Handler) http.Handler {
+	lim := s.limits.IP()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := lim.Allow(remoteHost(r.RemoteAddr))
+		ratelimit.Observe("ip", ok)
+		if !ok {
+			tooMany(w, wait, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tooMany — 429 с Retry-After в целых секундах (не меньше 1)
+func tooMany(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// reserveQuota списывает n заказов из дневной квоты клиента запроса
+func (s *Server) reserveQuota(ctx context.Context, n int) (ratelimit.Reservation, error) {
+	if s.quotas == nil {
+		return ratelimit.Reservation{}, nil
+	}
+	client, ok := ratelimit.ClientFrom(ctx)
+	if p, authed := auth.FromContext(ctx); authed {
+		client, ok = p.Subject, true
+	}
+	if !ok {
+		return ratelimit.Reservation{}, nil
+	}
+	return s.quotas.Reserve(ctx, client, n)
+}
+
+// releaseQuota возвращает в квоту n заказов, которые не удалось передать дальше.
+// Запрос к этому моменту мог быть отменён клиентом, поэтому его отмена не учитывается.
+func (s *Server) releaseQuota(ctx context.Context, r ratelimit.Reservation, n int) {
+	if s.quotas == nil {
+		return
+	}
+	if err := s.quotas.Release(context.WithoutCancel(ctx), r, n); err != nil {
+		log.Printf("release quota: %v", err)
+	}
+}
+
+// serveVars — метрики expvar (лимиты, квоты, runtime) без cmdline: в аргументах бывают пароли
+func serveVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",")
+		}
+		first = false
+		fmt.Fprintf(w, "\n%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
@@ -0,0 +1,387 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kaf "go-orders-demo/internal/kafka"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pb/ordersv1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Форматы ответа GET /order/{id}
+const (
+	mediaJSON     = "application/json"
+	mediaCSV      = "text/csv"
+	mediaXML      = "application/xml"
+	mediaProtobuf = kaf.ContentTypeProtobuf
+)
+
+// representation — выбранный по Accept формат
+type representation struct {
+	media  string
+	pretty bool
+}
+
+// negotiate выбирает формат по заголовку Accept с учётом q.
+// JSON с параметром pretty=true отдаётся с отступами.
+func negotiate(accept string) (representation, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return representation{media: mediaJSON}, true
+	}
+	var best representation
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var rep representation
+		switch mt {
+		case "application/json", "application/*", "*/*":
+			rep = representation{media: mediaJSON, pretty: params["pretty"] == "true"}
+		case "text/csv", "text/*":
+			rep = representation{media: mediaCSV}
+		case "application/xml", "text/xml":
+			rep = representation{media: mediaXML}
+		case "application/x-protobuf", "application/protobuf":
+			rep = representation{media: mediaProtobuf}
+		default:
+			continue
+		}
+		// при равном q побеждает указанный раньше
+		if q > bestQ {
+			best, bestQ = rep, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// writeOrder отдаёт заказ в формате, согласованном по Accept, с учётом ?fields=
+func writeOrder(w http.ResponseWriter, r *http.Request, raw json.RawMessage) {
+	rep, ok := negotiate(r.Header.Get("Accept"))
+	addVary(w.Header(), "Accept")
+	if !ok {
+		http.Error(w, "not acceptable: use application/json, text/csv, application/xml or application/x-protobuf", http.StatusNotAcceptable)
+		return
+	}
+	fields := parseFields(queryList(r.URL.Query()["fields"]))
+
+	// частый случай — JSON целиком, без разбора документа
+	if rep.media == mediaJSON && fields == nil {
+		var buf bytes.Buffer
+		var err error
+		if rep.pretty {
+			err = json.Indent(&buf, raw, "", "  ")
+		} else {
+			err = json.Compact(&buf, raw)
+		}
+		if err != nil {
+			http.Error(w, "stored order is not valid JSON", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mediaJSON)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		http.Error(w, "stored order is not valid JSON", http.StatusInternalServerError)
+		return
+	}
+	if fields != nil {
+		doc = fields.apply(doc).(map[string]any)
+	}
+
+	var body []byte
+	var err error
+	switch rep.media {
+	case mediaJSON:
+		if rep.pretty {
+			body, err = json.MarshalIndent(doc, "", "  ")
+		} else {
+			body, err = json.Marshal(doc)
+		}
+	case mediaCSV:
+		body, err = orderCSV(doc)
+	case mediaXML:
+		body, err = orderXML(doc)
+	case mediaProtobuf:
+		body, err = orderProto(doc)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ct := rep.media
+	if ct == mediaCSV || ct == mediaXML {
+		ct += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Write(body)
+}
+
+// fieldSet — дерево выбранных полей; nil у ключа означает «поле целиком»
+type fieldSet map[string]fieldSet
+
+// parseFields разбирает order_uid,delivery,items.status в дерево
+func parseFields(paths []string) fieldSet {
+	if len(paths) == 0 {
+		return nil
+	}
+	root := fieldSet{}
+	for _, p := range paths {
+		node := root
+		parts := strings.Split(p, ".")
+		for i, part := range parts {
+			child, seen := node[part]
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if seen && child == nil {
+				// поле уже выбрано целиком
+				break
+			}
+			if child == nil {
+				child = fieldSet{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return root
+}
+
+// apply оставляет в документе только выбранные поля; для массивов — в каждом элементе
+func (fs fieldSet) apply(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(fs))
+		for k, sub := range fs {
+			val, ok := t[k]
+			if !ok {
+				continue
+			}
+			if sub != nil {
+				val = sub.apply(val)
+			}
+			res[k] = val
+		}
+		return res
+	case []any:
+		res := make([]any, len(t))
+		for i, e := range t {
+			res[i] = fs.apply(e)
+		}
+		return res
+	}
+	return v
+}
+
+// keyOrder — порядок полей как в models.Order (по пути вложенного объекта),
+// чтобы CSV и XML не зависели от порядка обхода map
+var keyOrder = jsonKeyOrder(reflect.TypeOf(models.Order{}), "", map[string][]string{})
+
+func jsonKeyOrder(t reflect.Type, path string, dst map[string][]string) map[string][]string {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		dst[path] = append(dst[path], name)
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			jsonKeyOrder(ft, join(path, name), dst)
+		}
+	}
+	return dst
+}
+
+// sortedKeys — сначала известные поля в порядке модели, затем остальные по алфавиту
+func sortedKeys(m map[string]any, path string) []string {
+	keys := make([]string, 0, len(m))
+	known := map[string]bool{}
+	for _, k := range keyOrder[path] {
+		if _, ok := m[k]; ok {
+			keys = append(keys, k)
+			known[k] = true
+		}
+	}
+	var extra []string
+	for k := range m {
+		if !known[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	return append(keys, extra...)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// csvRowsKey — массив, элементы которого становятся строками CSV
+const csvRowsKey = "items"
+
+// orderCSV разворачивает заказ в таблицу: строка на каждую позицию,
+// поля заказа (delivery.name, payment.amount, …) повторяются в каждой строке
+func orderCSV(doc map[string]any) ([]byte, error) {
+	var cols []string
+	head := map[string]string{}
+	flatten(doc, "", head, &cols)
+
+	items, _ := doc[csvRowsKey].([]any)
+	var itemCols []string
+	rows := make([]map[string]string, 0, len(items))
+	seen := map[string]bool{}
+	for _, it := range items {
+		row := map[string]string{}
+		var ic []string
+		flatten(it, csvRowsKey, row, &ic)
+		for _, c := range ic {
+			if !seen[c] {
+				seen[c] = true
+				itemCols = append(itemCols, c)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		rows = append(rows, map[string]string{})
+	}
+	cols = append(cols, itemCols...)
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(cols)
+	for _, row := range rows {
+		rec := make([]string, len(cols))
+		for i, c := range cols {
+			if v, ok := head[c]; ok {
+				rec[i] = v
+			} else {
+				rec[i] = row[c]
+			}
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
+
+// flatten раскладывает вложенные объекты в колонки с точкой в имени
+func flatten(v any, path string, dst map[string]string, cols *[]string) {
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t, path) {
+			if path == "" && k == csvRowsKey {
+				continue
+			}
+			flatten(t[k], join(path, k), dst, cols)
+		}
+	default:
+		*cols = append(*cols, path)
+		dst[path] = csvValue(v)
+	}
+}
+
+func csvValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		// вложенные массивы — одной ячейкой в JSON
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// orderXML пишет <order> с элементами по именам JSON полей; элементы массива — <item>
+func orderXML(doc map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := xmlValue(enc, "order", "", doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func xmlValue(enc *xml.Encoder, name, path string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t, path) {
+			if err := xmlValue(enc, k, join(path, k), t[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range t {
+			if err := xmlValue(enc, "item", path, e); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(csvValue(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// orderProto кодирует orders.v1.Order; невыбранные через fields поля остаются пустыми
+func orderProto(doc map[string]any) ([]byte, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var o models.Order
+	if err := json.Unmarshal(b, &o); err != nil {
+		return nil, fmt.Errorf("stored order does not match schema: %w", err)
+	}
+	return proto.Marshal(ordersv1.FromModel(o))
+}
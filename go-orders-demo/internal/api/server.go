@@ -5,47 +5,175 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/cache"
 	"go-orders-demo/internal/db"
 	kaf "go-orders-demo/internal/kafka"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pii"
+	"go-orders-demo/internal/ratelimit"
+	"go-orders-demo/internal/stream"
+	"go-orders-demo/internal/webhooks"
+	"go-orders-demo/internal/ws"
 )
 
 type Cache interface {
 	Get(id string) (json.RawMessage, bool)
 	Set(id string, raw json.RawMessage)
+	Delete(id string)
+	// Entry и Put — заказ со временем изменения и сжатыми копиями для GET /order/{id}
+	Entry(id string) (*cache.Entry, bool)
+	Put(id string, raw json.RawMessage, modified time.Time) *cache.Entry
+	// BulkLoad и Stats — для /admin/cache
+	BulkLoad(m map[string]cache.Version)
+	Stats() cache.Stats
+}
+
+// Producer — публикация в топик заказов (реализуется kafka.Producer)
+type Producer interface {
+	Produce(ctx context.Context, payload []byte) error
+	ProduceBatch(ctx context.Context, batch []kaf.Payload) []error
+}
+
+// Outbox — durable приём заказов (реализуется db.SQLStore)
+type Outbox interface {
+	EnqueueOutbox(ctx context.Context, id string, payload json.RawMessage) error
 }
 
 type Server struct {
 	httpAddr string
 	cache    Cache
-	db       *db.Store
-	prod     *kaf.Producer
+	db       db.Repository
+	prod     Producer
+	outbox   Outbox
+	webhooks *webhooks.Service
+	exporter Exporter
+	gdpr     *gdpr
 	httpSrv  *http.Server
+	httpOpts HTTPOptions
+
+	auth   *auth.Authenticator
+	audit  auth.Auditor
+	limits *ratelimit.Limits
+	quotas *ratelimit.Quotas
+	pii    *pii.Masker
+
+	kafkaAdmin KafkaAdmin
+	replay     *replayJob
+
+	stream    *stream.Hub
+	heartbeat time.Duration // keepalive для SSE
+	ws        *ws.Hub
+
+	bulkBatch   int // заказов в одном WriteMessages для /ingest/bulk
+	bulkMaxLine int // максимальный размер одного заказа в /ingest/bulk
+
+	ingestMaxBody int64 // предел тела /ingest до и после распаковки
+	strictJSON    bool  // отклонять заказы с неизвестными полями
+}
+
+// Option настраивает необязательные возможности сервера
+type Option func(*Server)
+
+// WithOutbox включает режим outbox: /ingest пишет заказ в БД,
+// а в Kafka его переносит outbox.Relay
+func WithOutbox(o Outbox) Option {
+	return func(s *Server) { s.outbox = o }
 }
 
-func New(addr string, cache Cache, store *db.Store, prod *kaf.Producer) *Server {
-	s := &Server{httpAddr: addr, cache: cache, db: store, prod: prod}
+func New(addr string, cache Cache, store db.Repository, prod Producer, opts ...Option) *Server {
+	s := &Server{httpAddr: addr, cache: cache, db: store, prod: prod, bulkBatch: 500, bulkMaxLine: 1 << 20, ingestMaxBody: 1 << 20}
+	for _, opt := range opts {
+		opt(s)
+	}
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ingest", s.handleIngest)
-	mux.HandleFunc("/order/", s.handleGet)
+	s.handle(mux, "/ingest", auth.RoleIngester, s.handleIngest)
+	s.handle(mux, "/ingest/bulk", auth.RoleIngester, s.handleBulkIngest)
+	s.handle(mux, "/order/", auth.RoleReader, s.handleGet)
+	if s.stream != nil {
+		s.handle(mux, "/orders/stream", auth.RoleReader, s.handleStream)
+	}
+	if s.ws != nil {
+		s.handle(mux, "/orders/ws", auth.RoleReader, s.handleWS)
+	}
+	if s.exporter != nil {
+		s.handle(mux, "/export", auth.RoleReader, s.handleExport)
+	}
+	if s.gdpr != nil {
+		s.handle(mux, "/admin/customers/", auth.RoleAdmin, s.handleCustomer)
+	}
+	if s.kafkaAdmin != nil {
+		s.replay = &replayJob{}
+		s.handle(mux, "/admin/kafka/offsets", auth.RoleAdmin, s.handleKafkaOffsets)
+		s.handle(mux, "/admin/kafka/rewind", auth.RoleAdmin, s.handleKafkaRewind)
+		s.handle(mux, "/admin/kafka/replay", auth.RoleAdmin, s.handleKafkaReplay)
+	}
+	if s.webhooks != nil {
+		s.handle(mux, "/webhooks", auth.RoleAdmin, s.handleWebhooks)
+		s.handle(mux, "/webhooks/", auth.RoleAdmin, s.handleWebhook)
+	}
+	s.handle(mux, "/admin/cache", auth.RoleAdmin, s.handleCacheStats)
+	s.handle(mux, "/admin/cache/", auth.RoleAdmin, s.handleCacheEntry)
+	s.handle(mux, "/debug/vars", auth.RoleAdmin, serveVars)
+	mux.HandleFunc("/openapi.json", serveOpenAPI)
+	mux.HandleFunc("/docs", serveDocs)
 	mux.HandleFunc("/", s.serveIndex)
 
+	var h http.Handler = mux
+	if s.httpOpts.MaxBodyBytes > 0 {
+		h = limitBody(s.httpOpts.MaxBodyBytes, h)
+	}
+	if s.httpOpts.Compression != nil {
+		h = compress(*s.httpOpts.Compression, h)
+	}
+	if s.httpOpts.H2C && s.httpOpts.TLS == nil {
+		h = withH2C(h)
+	}
+	readHeader := s.httpOpts.ReadHeaderTimeout
+	if readHeader == 0 {
+		readHeader = 5 * time.Second
+	}
 	s.httpSrv = &http.Server{
 		Addr:              addr,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
+		Handler:           h,
+		ReadTimeout:       s.httpOpts.ReadTimeout,
+		ReadHeaderTimeout: readHeader,
+		WriteTimeout:      s.httpOpts.WriteTimeout,
+		IdleTimeout:       s.httpOpts.IdleTimeout,
+		MaxHeaderBytes:    s.httpOpts.MaxHeaderBytes,
 	}
 	return s
 }
 
-func (s *Server) Start() error { return s.httpSrv.ListenAndServe() }
-func (s *Server) Stop(ctx context.Context) error { return s.httpSrv.Shutdown(ctx) }
+// Handler — обработчик сервера со всеми промежуточными слоями (для httptest)
+func (s *Server) Handler() http.Handler { return s.httpSrv.Handler }
+
+// Start слушает addr; с TLS — HTTPS с HTTP/2 (ALPN)
+func (s *Server) Start() error {
+	if s.httpOpts.TLS == nil {
+		return s.httpSrv.ListenAndServe()
+	}
+	cfg, err := s.httpOpts.TLS.Config()
+	if err != nil {
+		return err
+	}
+	s.httpSrv.TLSConfig = cfg
+	return s.httpSrv.ListenAndServeTLS("", "")
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	if s.replay != nil {
+		s.replay.stop()
+	}
+	return s.httpSrv.Shutdown(ctx)
+}
 
 func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -68,56 +196,79 @@ func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Читаем тело запроса
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "failed to read request body", http.StatusBadRequest)
-		return
-	}
 	defer r.Body.Close()
-
-	// Парсим JSON в структуру заказа
-	var order models.Order
-	if err := json.Unmarshal(body, &order); err != nil {
-		http.Error(w, "invalid JSON format", http.StatusBadRequest)
-		return
+	if s.ingestMaxBody > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.ingestMaxBody)
 	}
 
-	// Базовая валидация обязательных полей
-	if strings.TrimSpace(order.OrderUID) == "" {
-		http.Error(w, "missing field: order_uid", http.StatusBadRequest)
+	// Распаковываем тело и разбираем заказ потоком, не читая тело целиком
+	body, err := decodeBody(r, s.ingestMaxBody)
+	if err != nil {
+		bodyError(w, err)
 		return
 	}
-	if strings.TrimSpace(order.TrackNumber) == "" {
-		http.Error(w, "missing field: track_number", http.StatusBadRequest)
+	defer body.Close()
+	order, err := decodeOrder(body, s.strictJSON)
+	if err != nil {
+		bodyError(w, err)
 		return
 	}
-	if order.Payment.Transaction == "" {
-		http.Error(w, "missing field: payment.transaction", http.StatusBadRequest)
+
+	// Базовая валидация обязательных полей
+	if err := order.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if order.Delivery.Name == "" || order.Delivery.Address == "" {
-		http.Error(w, "missing delivery information", http.StatusBadRequest)
+
+	if err := s.Accept(r.Context(), order); err != nil {
+		log.Printf("ingest %s: %v", order.OrderUID, err)
+		if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+			tooMany(w, s.quotas.RetryAfter(), err.Error())
+		} else if s.outbox != nil {
+			http.Error(w, "failed to store order", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "failed to send to kafka", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("order accepted"))
+}
+
+// Accept списывает заказ из дневной квоты клиента (ratelimit.ErrQuotaExceeded)
+// и передаёт его дальше: в outbox (если включён) или сразу в Kafka.
+// Если передать не удалось, заказ возвращается в квоту.
+// Используется HTTP /ingest и gRPC IngestOrder.
+func (s *Server) Accept(ctx context.Context, order models.Order) error {
 	// Сериализуем обратно в JSON перед отправкой в Kafka
 	data, err := json.Marshal(order)
 	if err != nil {
-		http.Error(w, "failed to encode JSON", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("encode JSON: %w", err)
 	}
 
-	// Публикуем в Kafka
-	if err := s.prod.Produce(r.Context(), data); err != nil {
-		log.Printf("failed to send to kafka: %v", err)
-		http.Error(w, "failed to send to kafka", http.StatusInternalServerError)
-		return
+	quota, err := s.reserveQuota(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	// В режиме outbox заказ фиксируется в БД, публикацией занимается релей
+	if s.outbox != nil {
+		if err := s.outbox.EnqueueOutbox(ctx, order.OrderUID, data); err != nil {
+			s.releaseQuota(ctx, quota, 1)
+			return fmt.Errorf("write outbox: %w", err)
+		}
+		log.Printf("accepted new order %s -> outbox", order.OrderUID)
+		return nil
 	}
 
+	// Публикуем в Kafka
+	if err := s.prod.Produce(ctx, data); err != nil {
+		s.releaseQuota(ctx, quota, 1)
+		return fmt.Errorf("send to kafka: %w", err)
+	}
 	log.Printf("accepted new order %s -> kafka", order.OrderUID)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("order accepted"))
+	return nil
 }
 
 func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
@@ -126,21 +277,40 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "id required", http.StatusBadRequest)
 		return
 	}
-	if raw, ok := s.cache.Get(id); ok {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(raw)
-		return
+	e, ok := s.cache.Entry(id)
+	if !ok {
+		raw, modified, err := s.db.GetRawModified(r.Context(), id)
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e = s.cache.Put(id, raw, modified)
 	}
-	raw, err := s.db.Get(r.Context(), id)
-	if errors.Is(err, db.ErrNotFound) {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
+	h := w.Header()
+	h.Set("Cache-Control", s.cacheControl())
+	// 304 должен нести тот же Vary, что и 200, который он подтверждает
+	addVary(h, "Accept", "Accept-Encoding")
+	if !e.Modified.IsZero() {
+		h.Set("Last-Modified", e.Modified.UTC().Format(http.TimeFormat))
+		if notModified(r, e.Modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	raw := e.Raw
+	// в кеше заказ целиком, маска — под конкретного клиента
+	if view := s.pii.For(r.Context()); view != nil {
+		var err error
+		if raw, err = view(raw); err != nil {
+			http.Error(w, "stored order is not valid JSON", http.StatusInternalServerError)
+			return
+		}
+	} else if s.writePrecompressed(w, r, e) {
 		return
 	}
-	s.cache.Set(id, raw)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(raw)
+	writeOrder(w, r, raw)
 }
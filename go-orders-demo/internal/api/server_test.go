@@ -1,35 +1,986 @@
 package api
 
 import (
-    "encoding/json"
-    "net/http"
-    "net/http/httptest"
-    "testing"
-    "go-orders-demo/internal/db"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/cache"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/events"
+	kaf "go-orders-demo/internal/kafka"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pb/ordersv1"
+	"go-orders-demo/internal/pii"
+	"go-orders-demo/internal/ratelimit"
+	"go-orders-demo/internal/stream"
+	"google.golang.org/protobuf/proto"
 )
 
 // простой мок
 type mockRepo struct {
-    data map[string][]byte
+	data     map[string][]byte
+	modified time.Time // orders.updated_at всех заказов
+}
+
+func (m *mockRepo) SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error) {
+	return m.modified, nil
 }
-func (m *mockRepo) SaveRaw(ctx context.Context, id string, raw json.RawMessage) error { return nil }
 func (m *mockRepo) GetRaw(ctx context.Context, id string) (json.RawMessage, error) {
-    v, ok := m.data[id]
-    if !ok { return nil, db.ErrNotFound }
-    return json.RawMessage(v), nil
+	v, ok := m.data[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return json.RawMessage(v), nil
+}
+func (m *mockRepo) GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error) {
+	raw, err := m.GetRaw(ctx, id)
+	return raw, m.modified, err
+}
+func (m *mockRepo) LoadAllRaw(ctx context.Context, limit int) (map[string]db.RawOrder, error) {
+	all := map[string]db.RawOrder{}
+	for id, v := range m.data {
+		all[id] = db.RawOrder{Raw: v, Modified: m.modified}
+	}
+	return all, nil
 }
-func (m *mockRepo) LoadAllRaw(ctx context.Context, limit int) (map[string]json.RawMessage, error) { return nil, nil }
 func (m *mockRepo) SaveOrder(ctx context.Context, o models.Order) error { return nil }
 
 func TestHandleGetFromDB(t *testing.T) {
-    mock := &mockRepo{data: map[string][]byte{"x":{"order_uid":"x"}}}
-    c := cache.New(10)
-    s := New(":0", c, mock, nil)
-
-    req := httptest.NewRequest("GET", "/order/x", nil)
-    w := httptest.NewRecorder()
-    s.handleGet(w, req)
-    if w.Code != http.StatusOK {
-        t.Fatalf("expected 200 got %d body=%s", w.Code, w.Body.String())
-    }
+	mock := &mockRepo{data: map[string][]byte{"x": []byte(`{"order_uid":"x"}`)}}
+	c := cache.New(10)
+	s := New(":0", c, mock, nil)
+
+	req := httptest.NewRequest("GET", "/order/x", nil)
+	w := httptest.NewRecorder()
+	s.handleGet(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleGetNegotiation(t *testing.T) {
+	raw := `{"order_uid":"x","track_number":"T","delivery":{"name":"N","city":"C"},` +
+		`"items":[{"chrt_id":1,"name":"a, b","status":202},{"chrt_id":2,"name":"c","status":200}]}`
+	c := cache.New(10)
+	c.Set("x", json.RawMessage(raw))
+	s := New(":0", c, &mockRepo{}, nil)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		s.handleGet(w, req)
+		return w
+	}
+
+	w := get("/order/x", "")
+	if w.Header().Get("Content-Type") != "application/json" || w.Body.String() != raw {
+		t.Fatalf("default json: %s %s", w.Header().Get("Content-Type"), w.Body)
+	}
+	if w := get("/order/x", "application/json; pretty=true"); !strings.Contains(w.Body.String(), "\n  \"track_number\"") {
+		t.Fatalf("pretty json: %s", w.Body)
+	}
+
+	w = get("/order/x", "text/html, text/csv;q=0.9, application/json;q=0.5")
+	want := "order_uid,track_number,delivery.name,delivery.city,items.chrt_id,items.name,items.status\n" +
+		"x,T,N,C,1,\"a, b\",202\n" +
+		"x,T,N,C,2,c,200\n"
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || w.Body.String() != want {
+		t.Fatalf("csv: %s\n%s", w.Header().Get("Content-Type"), w.Body)
+	}
+
+	w = get("/order/x?fields=order_uid,delivery&fields=items.status", "application/json")
+	if w.Body.String() != `{"delivery":{"city":"C","name":"N"},"items":[{"status":202},{"status":200}],"order_uid":"x"}` {
+		t.Fatalf("fields: %s", w.Body)
+	}
+
+	w = get("/order/x?fields=order_uid,items.status", "application/xml")
+	if !strings.Contains(w.Body.String(), "<order>\n  <order_uid>x</order_uid>\n  <items>\n    <item>\n      <status>202</status>") {
+		t.Fatalf("xml: %s", w.Body)
+	}
+
+	w = get("/order/x", "application/x-protobuf")
+	var o ordersv1.Order
+	if err := proto.Unmarshal(w.Body.Bytes(), &o); err != nil || o.GetDelivery().GetCity() != "C" || len(o.GetItems()) != 2 {
+		t.Fatalf("protobuf: %v %v", err, &o)
+	}
+
+	if w := get("/order/x", "image/png"); w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406, got %d", w.Code)
+	}
+}
+
+func TestCacheAdmin(t *testing.T) {
+	c := cache.New(10)
+	modified := time.Date(2024, 6, 19, 10, 0, 0, 0, time.UTC)
+	repo := &mockRepo{data: map[string][]byte{"a": []byte(`{"order_uid":"a"}`), "b": []byte(`{"order_uid":"b"}`)}, modified: modified}
+	s := New(":0", c, repo, nil)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := do("POST", "/admin/cache/warm"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"loaded":2`) {
+		t.Fatalf("warm: %d %s", w.Code, w.Body)
+	}
+	// прогретый заказ отдаёт Last-Modified из orders.updated_at, а не время прогрева
+	if w := do("GET", "/order/b"); w.Header().Get("Last-Modified") != "Wed, 19 Jun 2024 10:00:00 GMT" {
+		t.Fatalf("warmed Last-Modified %q", w.Header().Get("Last-Modified"))
+	}
+	if w := do("DELETE", "/admin/cache/a"); w.Code != http.StatusNoContent {
+		t.Fatalf("evict: %d", w.Code)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("order a still cached")
+	}
+	if w := do("GET", "/admin/cache/a"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("get entry: %d", w.Code)
+	}
+	var st cache.Stats
+	w := do("GET", "/admin/cache")
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || st.Entries != 1 || st.Limit != 10 {
+		t.Fatalf("stats: %s %v", w.Body, err)
+	}
+}
+
+type mockProducer struct {
+	batches [][]kaf.Payload
+	fail    string // order_uid, запись которого завершается ошибкой
+}
+
+func (m *mockProducer) Produce(ctx context.Context, payload []byte) error {
+	if m.fail != "" && bytes.Contains(payload, []byte(`"order_uid":"`+m.fail+`"`)) {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+func (m *mockProducer) ProduceBatch(ctx context.Context, batch []kaf.Payload) []error {
+	m.batches = append(m.batches, append([]kaf.Payload(nil), batch...))
+	errs := make([]error, len(batch))
+	for i, p := range batch {
+		if p.Key == m.fail {
+			errs[i] = errors.New("broker unavailable")
+		}
+	}
+	return errs
+}
+
+func bulkOrder(id string) string {
+	return `{"order_uid":"` + id + `","track_number":"T","payment":{"transaction":"` + id + `"},"delivery":{"name":"N","address":"A"}}`
+}
+
+func TestBulkIngest(t *testing.T) {
+	prod := &mockProducer{fail: "c"}
+	s := New(":0", cache.New(10), &mockRepo{}, prod, WithBulkIngest(2, 1<<10))
+
+	bodies := map[string]string{
+		"ndjson": bulkOrder("a") + "\n\n" + `{"order_uid":"x"}` + "\n" + "not json\n" + bulkOrder("b") + "\n" + bulkOrder("c") + "\n",
+		"array":  "[" + bulkOrder("a") + ",\n" + `{"order_uid":"x"}, "str", ` + bulkOrder("b") + "," + bulkOrder("c") + "]",
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			prod.batches = nil
+			req := httptest.NewRequest("POST", "/ingest/bulk", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			s.handleBulkIngest(w, req)
+
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			var got []bulkResult
+			for _, l := range lines[:len(lines)-1] {
+				var res bulkResult
+				if err := json.Unmarshal([]byte(l), &res); err != nil {
+					t.Fatalf("bad report line %q", l)
+				}
+				got = append(got, res)
+			}
+			var statuses []string
+			for _, r := range got {
+				statuses = append(statuses, r.Status)
+			}
+			if strings.Join(statuses, ",") != "accepted,rejected,rejected,accepted,failed" {
+				t.Fatalf("statuses %v\n%s", statuses, w.Body)
+			}
+			if got[1].Error != "missing field: track_number" {
+				t.Errorf("unexpected error %q", got[1].Error)
+			}
+			if !strings.Contains(lines[len(lines)-1], `"summary":{"total":5,"accepted":2,"rejected":2,"failed":1}`) {
+				t.Errorf("summary %s", lines[len(lines)-1])
+			}
+			// отчёт сбрасывается каждые 2 строки, поэтому пакеты не больше 2 заказов
+			var keys []string
+			for _, b := range prod.batches {
+				if len(b) > 2 {
+					t.Errorf("batch of %d orders", len(b))
+				}
+				for _, p := range b {
+					keys = append(keys, p.Key)
+				}
+			}
+			if strings.Join(keys, ",") != "a,b,c" {
+				t.Errorf("published %v", keys)
+			}
+		})
+	}
+}
+
+// hideController прячет от http.ResponseController методы настоящего writer
+type hideController struct{ http.ResponseWriter }
+
+func TestBulkIngestLargeBody(t *testing.T) {
+	s := New(":0", cache.New(10), &mockRepo{}, &mockProducer{}, WithBulkIngest(100, 1<<10))
+	const n = 20000
+	var body strings.Builder
+	for i := 0; i < n; i++ {
+		body.WriteString(bulkOrder(fmt.Sprintf("order-%05d", i)) + "\n")
+	}
+	if body.Len() < 1<<20 {
+		t.Fatalf("body is only %d bytes", body.Len())
+	}
+
+	for name, h := range map[string]http.Handler{
+		"full duplex": s.Handler(),
+		"buffered": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Handler().ServeHTTP(hideController{w}, r)
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			// тело без длины уходит chunked: так шлют потоковые клиенты, и его сервер дочитывает молча
+			res, err := http.Post(srv.URL+"/ingest/bulk", "application/x-ndjson", struct{ io.Reader }{strings.NewReader(body.String())})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			report, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+			want := fmt.Sprintf(`{"summary":{"total":%d,"accepted":%d,"rejected":0,"failed":0}}`, n, n)
+			if res.StatusCode != http.StatusOK || lines[len(lines)-1] != want {
+				t.Fatalf("%d: last line %s, want %s", res.StatusCode, lines[len(lines)-1], want)
+			}
+			if len(lines) != n+1 {
+				t.Fatalf("%d report lines, want %d", len(lines), n+1)
+			}
+		})
+	}
+}
+
+func TestIngestBody(t *testing.T) {
+	s := New(":0", cache.New(10), &mockRepo{}, &mockProducer{}, WithIngestDecoding(512, true))
+	gz := func(data string) string {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(data))
+		zw.Close()
+		return buf.String()
+	}
+	zs := func(data string) string {
+		enc, _ := zstd.NewWriter(nil)
+		return string(enc.EncodeAll([]byte(data), nil))
+	}
+	// большой заказ хорошо сжимается: сжатый меньше предела, распакованный — больше
+	big := `{"order_uid":"big","track_number":"` + strings.Repeat("T", 4096) + `"}`
+
+	for _, tc := range []struct {
+		name, encoding, body string
+		want                 int
+	}{
+		{"plain", "", bulkOrder("a"), http.StatusOK},
+		{"gzip", "gzip", gz(bulkOrder("a")), http.StatusOK},
+		{"zstd", "zstd", zs(bulkOrder("a")), http.StatusOK},
+		{"trailing spaces", "", bulkOrder("a") + "\n ", http.StatusOK},
+		{"too large", "", big, http.StatusRequestEntityTooLarge},
+		{"gzip bomb", "gzip", gz(big), http.StatusRequestEntityTooLarge},
+		{"zstd bomb", "zstd", zs(big), http.StatusRequestEntityTooLarge},
+		{"unknown field", "", `{"order_uid":"a","extra":1}`, http.StatusBadRequest},
+		{"trailing data", "", bulkOrder("a") + bulkOrder("b"), http.StatusBadRequest},
+		{"broken gzip", "gzip", "not gzip", http.StatusBadRequest},
+		{"brotli", "br", bulkOrder("a"), http.StatusUnsupportedMediaType},
+	} {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(tc.body))
+		if tc.encoding != "" {
+			req.Header.Set("Content-Encoding", tc.encoding)
+		}
+		w := httptest.NewRecorder()
+		s.handleIngest(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d %s", tc.name, tc.want, w.Code, w.Body)
+		}
+		if tc.want == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), "limit is 512 bytes") {
+			t.Errorf("%s: %s", tc.name, w.Body)
+		}
+		if tc.name == "unknown field" && !strings.Contains(w.Body.String(), `unknown field "extra"`) {
+			t.Errorf("%s: %s", tc.name, w.Body)
+		}
+	}
+
+	// /ingest/bulk: сжатое тело, строгий разбор построчно
+	req := httptest.NewRequest("POST", "/ingest/bulk", strings.NewReader(zs(bulkOrder("a")+"\n"+`{"order_uid":"b","extra":1}`+"\n")))
+	req.Header.Set("Content-Encoding", "zstd")
+	w := httptest.NewRecorder()
+	s.handleBulkIngest(w, req)
+	if !strings.Contains(w.Body.String(), `"summary":{"total":2,"accepted":1,"rejected":1,"failed":0}`) {
+		t.Fatalf("bulk: %s", w.Body)
+	}
+}
+
+func TestCompressionAndCaching(t *testing.T) {
+	c := cache.New(10)
+	repo := &mockRepo{data: map[string][]byte{"y": []byte(`{"order_uid":"y"}`)}}
+	s := New(":0", c, repo, nil, WithHTTPOptions(HTTPOptions{
+		Compression: &CompressionOptions{MinBytes: 100},
+		OrderMaxAge: time.Minute,
+	}))
+	big := `{"order_uid": "x", "items": [` + strings.Repeat(`{"name": "item", "price": 100}, `, 50) + `{"name": "last"}]}`
+	var compact bytes.Buffer
+	json.Compact(&compact, []byte(big))
+	modified := time.Date(2024, 6, 19, 10, 0, 0, int(500*time.Millisecond), time.UTC)
+	c.Put("x", json.RawMessage(big), modified)
+
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) string {
+		var r io.Reader = w.Body
+		switch w.Header().Get("Content-Encoding") {
+		case "gzip":
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		case "br":
+			r = brotli.NewReader(w.Body)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// целый заказ — сжатая копия из кеша
+	for _, enc := range []string{"gzip", "br"} {
+		w := get("/order/x", "Accept-Encoding", "gzip;q=0.5, "+enc)
+		if w.Header().Get("Content-Length") != fmt.Sprint(w.Body.Len()) {
+			t.Errorf("%s: content-length %s", enc, w.Header().Get("Content-Length"))
+		}
+		if w.Header().Get("Content-Encoding") != enc || decode(w) != compact.String() {
+			t.Fatalf("%s: %v", enc, w.Header())
+		}
+	}
+	w := get("/order/x", "Accept-Encoding", "gzip")
+	if w.Header().Get("Last-Modified") != "Wed, 19 Jun 2024 10:00:00 GMT" || w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("caching headers %v", w.Header())
+	}
+	if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Encoding") {
+		t.Fatalf("vary %v", w.Header().Values("Vary"))
+	}
+	if e, _ := c.Entry("x"); e == nil {
+		t.Fatal("entry evicted")
+	} else if b, _ := e.Encoded("gzip", nil); len(b) == 0 {
+		t.Fatal("compressed copy is not cached")
+	}
+
+	// без Accept-Encoding и для коротких ответов — без сжатия
+	if w := get("/order/x"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != compact.String() {
+		t.Fatalf("identity: %v", w.Header())
+	}
+	if w := get("/order/x?fields=order_uid", "Accept-Encoding", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"order_uid":"x"}` {
+		t.Fatalf("short: %v %s", w.Header(), w.Body)
+	}
+	// pretty — сжатие на лету
+	w = get("/order/x", "Accept-Encoding", "gzip", "Accept", "application/json; pretty=true")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.Contains(decode(w), "\n  \"items\"") {
+		t.Fatalf("pretty: %v", w.Header())
+	}
+
+	// If-Modified-Since
+	w = get("/order/x", "If-Modified-Since", "Wed, 19 Jun 2024 10:00:00 GMT", "Accept-Encoding", "gzip")
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected 304, got %d %v", w.Code, w.Header())
+	}
+	if vary := w.Header().Values("Vary"); strings.Join(vary, ",") != "Accept,Accept-Encoding" {
+		t.Fatalf("304 vary %v, want the same as 200", vary)
+	}
+	if w := get("/order/x", "If-Modified-Since", "Wed, 19 Jun 2024 09:59:59 GMT"); w.Code != http.StatusOK {
+		t.Fatalf("modified order: %d", w.Code)
+	}
+
+	// заказ из БД без времени изменения — без Last-Modified, но с Cache-Control
+	w = get("/order/y")
+	if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "" || w.Header().Get("Cache-Control") == "" {
+		t.Fatalf("db order: %d %v", w.Code, w.Header())
+	}
+}
+
+type mockKafkaAdmin struct {
+	rewound []kaf.PartitionOffset
+	release chan struct{} // Replay ждёт его закрытия
+}
+
+func (m *mockKafkaAdmin) Offsets(ctx context.Context) ([]kaf.PartitionState, error) {
+	return []kaf.PartitionState{{Partition: 0, First: 0, Last: 10, Committed: 4, Lag: 6}}, nil
+}
+func (m *mockKafkaAdmin) OffsetsAt(ctx context.Context, t time.Time) ([]kaf.PartitionOffset, error) {
+	return []kaf.PartitionOffset{{Partition: 0, Offset: 3}, {Partition: 1, Offset: 7}}, nil
+}
+func (m *mockKafkaAdmin) Rewind(ctx context.Context, offsets []kaf.PartitionOffset) error {
+	m.rewound = offsets
+	return nil
+}
+func (m *mockKafkaAdmin) Replay(ctx context.Context, from, to time.Time, progress func([]kaf.ReplayProgress)) error {
+	progress([]kaf.ReplayProgress{{Partition: 0, Start: 0, End: 10, Offset: 5, Saved: 4, Skipped: 1}})
+	select {
+	case <-m.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestKafkaAdmin(t *testing.T) {
+	admin := &mockKafkaAdmin{release: make(chan struct{})}
+	s := New(":0", cache.New(10), &mockRepo{}, nil, WithKafkaAdmin(admin))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("GET", "/admin/kafka/offsets", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"lag":6`) {
+		t.Fatalf("offsets: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("POST", "/admin/kafka/rewind", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("rewind without target: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/kafka/rewind", `{"offsets":[{"partition":1,"offset":-1}]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("negative offset: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/kafka/rewind", `{"timestamp":"2024-01-02T00:00:00Z"}`); w.Code != http.StatusOK || len(admin.rewound) != 2 {
+		t.Fatalf("rewind by time: %d %v", w.Code, admin.rewound)
+	}
+	if w := do("POST", "/admin/kafka/rewind", `{"offsets":[{"partition":1,"offset":42}]}`); w.Code != http.StatusOK ||
+		admin.rewound[0] != (kaf.PartitionOffset{Partition: 1, Offset: 42}) {
+		t.Fatalf("rewind by offsets: %d %v", w.Code, admin.rewound)
+	}
+
+	if w := do("POST", "/admin/kafka/replay", `{"from":"2024-01-02T00:00:00Z","to":"2024-01-01T00:00:00Z"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("replay with from > to: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/kafka/replay", `{"from":"2024-01-01T00:00:00Z"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("replay: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/admin/kafka/replay", ``); w.Code != http.StatusConflict {
+		t.Fatalf("second replay: expected 409, got %d", w.Code)
+	}
+	close(admin.release)
+	var st replayState
+	for i := 0; i < 100; i++ {
+		json.Unmarshal(do("GET", "/admin/kafka/replay", "").Body.Bytes(), &st)
+		if st.Status != replayRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Status != replayDone || st.Saved != 4 || st.Skipped != 1 || st.FinishedAt == nil {
+		t.Fatalf("unexpected replay state %+v", st)
+	}
+	if w := do("DELETE", "/admin/kafka/replay", ""); w.Code != http.StatusConflict {
+		t.Fatalf("cancel finished replay: expected 409, got %d", w.Code)
+	}
+}
+
+type mockKeys map[string][]string // ключ -> роли
+
+func (m mockKeys) LookupAPIKey(ctx context.Context, hash []byte) (db.APIKey, error) {
+	for k, roles := range m {
+		if string(auth.HashKey(k)) == string(hash) {
+			return db.APIKey{Name: k, Roles: roles}, nil
+		}
+	}
+	return db.APIKey{}, db.ErrNotFound
+}
+
+// countingKeys считает обращения к хранилищу ключей
+type countingKeys struct {
+	keys mockKeys
+	n    int
+}
+
+func (c *countingKeys) LookupAPIKey(ctx context.Context, hash []byte) (db.APIKey, error) {
+	c.n++
+	return c.keys.LookupAPIKey(ctx, hash)
+}
+
+type mockAuditor struct{ entries []db.AuditEntry }
+
+func (m *mockAuditor) RecordAudit(ctx context.Context, e db.AuditEntry) error {
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func TestAuth(t *testing.T) {
+	keys := mockKeys{"ok_reader": {auth.RoleReader}, "ok_admin": {auth.RoleAdmin}}
+	audit := &mockAuditor{}
+	c := cache.New(10)
+	c.Set("x", json.RawMessage(`{"order_uid":"x"}`))
+	s := New(":0", c, &mockRepo{}, &mockProducer{}, WithAuth(auth.New(keys, nil, auth.Options{}), audit))
+
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/order/x", ""); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("no credentials: %d %v", w.Code, w.Header())
+	}
+	if w := do("GET", "/order/x", "ok_unknown"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown key: %d", w.Code)
+	}
+	if w := do("GET", "/order/x", "ok_reader"); w.Code != http.StatusOK {
+		t.Fatalf("reader get: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/ingest", "ok_reader"); w.Code != http.StatusForbidden {
+		t.Fatalf("reader ingest: %d", w.Code)
+	}
+	// admin включает ingester; тело невалидно, но запрос дошёл до обработчика
+	if w := do("POST", "/ingest", "ok_admin"); w.Code != http.StatusBadRequest {
+		t.Fatalf("admin ingest: %d %s", w.Code, w.Body)
+	}
+
+	var got []string
+	for _, e := range audit.entries {
+		got = append(got, fmt.Sprintf("%s %s %d", e.Principal, e.Action, e.Status))
+	}
+	want := " GET /order/x 401, ok_reader POST /ingest 403, ok_admin POST /ingest 400"
+	if strings.Join(got, ", ") != want {
+		t.Fatalf("audit %q", strings.Join(got, ", "))
+	}
+}
+
+func TestStreamQueryToken(t *testing.T) {
+	keys := mockKeys{"ok_reader": {auth.RoleReader}}
+	s := New(":0", cache.New(10), &mockRepo{}, &mockProducer{},
+		WithAuth(auth.New(keys, nil, auth.Options{}), nil), WithStream(stream.NewHub(10, 10), time.Minute))
+
+	// отменённый контекст: обработчик ленты отдаёт заголовки и сразу выходит
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil).WithContext(ctx))
+		return w.Code
+	}
+	for path, want := range map[string]int{
+		"/orders/stream":                                  http.StatusUnauthorized,
+		"/orders/stream?access_token=ok_unknown":          http.StatusUnauthorized,
+		"/orders/stream?access_token=ok_reader":           http.StatusOK,
+		"/orders/stream?locale=en&access_token=ok_reader": http.StatusOK,
+		"/order/x?access_token=ok_reader":                 http.StatusUnauthorized,
+	} {
+		if got := do(path); got != want {
+			t.Errorf("%s: %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	keys := &countingKeys{keys: mockKeys{"ok_reader": {auth.RoleReader}}}
+	limits := ratelimit.NewLimits(ratelimit.Limit{}, nil)
+	limits.PerIP = ratelimit.Limit{Rate: 1, Burst: 3}
+	c := cache.New(10)
+	c.Set("x", json.RawMessage(`{"order_uid":"x"}`))
+	s := New(":0", c, &mockRepo{}, &mockProducer{},
+		WithAuth(auth.New(keys, nil, auth.Options{}), nil), WithRateLimit(limits, nil))
+
+	do := func(key, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/order/x", nil)
+		req.RemoteAddr = addr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// перебор ключей: после всплеска — 429, до поиска ключа дело не доходит
+	for i, key := range []string{"ok_bad1", "ok_bad2", ""} {
+		if w := do(key, "10.0.0.1:1000"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d: %d", i, w.Code)
+		}
+	}
+	lookups := keys.n
+	w := do("ok_bad3", "10.0.0.1:1000")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w := do("ok_reader", "10.0.0.1:1000"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("valid key from limited IP: %d", w.Code)
+	}
+	if keys.n != lookups {
+		t.Fatalf("limited requests looked up keys: %d → %d", lookups, keys.n)
+	}
+	if w := do("ok_reader", "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Fatalf("other IP limited: %d", w.Code)
+	}
+}
+
+type mockUsage map[string]int64
+
+func (m mockUsage) AddIngestUsage(ctx context.Context, client string, day time.Time, n, limit int64) (int64, bool, error) {
+	if m[client]+n > limit {
+		return m[client], false, nil
+	}
+	m[client] += n
+	return m[client], true, nil
+}
+
+func (m mockUsage) ReleaseIngestUsage(ctx context.Context, client string, day time.Time, n int64) error {
+	m[client] -= n
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	usage := mockUsage{}
+	limits := ratelimit.NewLimits(ratelimit.Limit{}, map[string]ratelimit.Limit{"/order/": {Rate: 1, Burst: 2}})
+	c := cache.New(10)
+	c.Set("x", json.RawMessage(`{"order_uid":"x"}`))
+	s := New(":0", c, &mockRepo{}, &mockProducer{}, WithRateLimit(limits, ratelimit.NewQuotas(usage, 2, nil)))
+
+	do := func(method, path, body, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := do("GET", "/order/x", "", "10.0.0.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: %d", i, w.Code)
+		}
+	}
+	w := do("GET", "/order/x", "", "10.0.0.1:1001")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w := do("GET", "/order/x", "", "10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Fatalf("other client limited: %d", w.Code)
+	}
+
+	// квота: два заказа в сутки на IP, третий — 429 до полуночи UTC
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := do("POST", "/ingest", bulkOrder(fmt.Sprint(i)), "10.0.0.1:1000")
+		if w.Code != want {
+			t.Fatalf("ingest %d: %d %s", i, w.Code, w.Body)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatal("quota response without Retry-After")
+		}
+	}
+	if usage["10.0.0.1"] != 2 {
+		t.Fatalf("usage %v", usage)
+	}
+
+	// bulk: пакет, не влезающий в квоту, целиком failed
+	w = do("POST", "/ingest/bulk", bulkOrder("a")+"\n", "10.0.0.1:1000")
+	if !strings.Contains(w.Body.String(), `"status":"failed","error":"daily ingest quota exceeded`) {
+		t.Fatalf("bulk over quota: %s", w.Body)
+	}
+}
+
+// заказ, не переданный в Kafka, не расходует квоту: повтор после сбоя не упирается в 429
+func TestQuotaReleasedOnFailure(t *testing.T) {
+	usage := mockUsage{}
+	prod := &mockProducer{fail: "down"}
+	s := New(":0", cache.New(10), &mockRepo{}, prod, WithBulkIngest(10, 1<<10),
+		WithRateLimit(ratelimit.NewLimits(ratelimit.Limit{}, nil), ratelimit.NewQuotas(usage, 2, nil)))
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1000"
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := do("/ingest", bulkOrder("down")); w.Code != http.StatusInternalServerError {
+			t.Fatalf("ingest %d: %d %s", i, w.Code, w.Body)
+		}
+	}
+	if usage["10.0.0.1"] != 0 {
+		t.Fatalf("failed ingests used quota: %v", usage)
+	}
+
+	w := do("/ingest/bulk", bulkOrder("ok")+"\n"+bulkOrder("down")+"\n")
+	if !strings.Contains(w.Body.String(), `"accepted":1`) || usage["10.0.0.1"] != 1 {
+		t.Fatalf("bulk: usage %v, report %s", usage, w.Body)
+	}
+	if w := do("/ingest", bulkOrder("next")); w.Code != http.StatusOK {
+		t.Fatalf("quota left after failed bulk order: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPIIMasking(t *testing.T) {
+	rules, _ := pii.ParseRules(pii.DefaultRules)
+	keys := mockKeys{"ok_reader": {auth.RoleReader}, "ok_admin": {auth.RoleAdmin}}
+	c := cache.New(10)
+	c.Set("x", json.RawMessage(`{"order_uid":"x","delivery":{"name":"Test Testov","phone":"+9720000000","city":"Haifa"}}`))
+	s := New(":0", c, &mockRepo{}, nil,
+		WithAuth(auth.New(keys, nil, auth.Options{}), nil),
+		WithPII(pii.New(rules, []string{auth.RoleAdmin})))
+
+	get := func(key string) string {
+		req := httptest.NewRequest("GET", "/order/x", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d", key, w.Code)
+		}
+		return w.Body.String()
+	}
+	if body := get("ok_reader"); !strings.Contains(body, `"name":"T*** T***","phone":"+9***0000"`) || !strings.Contains(body, `"city":"Haifa"`) {
+		t.Fatalf("reader got %s", body)
+	}
+	if body := get("ok_admin"); !strings.Contains(body, `"phone":"+9720000000"`) {
+		t.Fatalf("admin got %s", body)
+	}
+	// кеш хранит заказ без маски
+	if raw, _ := c.Get("x"); !strings.Contains(string(raw), "Test Testov") {
+		t.Fatalf("cache was modified: %s", raw)
+	}
+}
+
+type mockCustomers struct {
+	orders map[string][]string // customer_id -> order_uid
+	erased []string
+}
+
+func (m *mockCustomers) CustomerBundle(ctx context.Context, customerID string) (db.CustomerBundle, error) {
+	b := db.CustomerBundle{CustomerID: customerID, Orders: []json.RawMessage{}}
+	for _, id := range m.orders[customerID] {
+		b.Orders = append(b.Orders, json.RawMessage(`{"order_uid":"`+id+`"}`))
+	}
+	return b, nil
+}
+
+func (m *mockCustomers) EraseCustomer(ctx context.Context, customerID, mode string, beforeCommit func([]string) error) ([]string, error) {
+	ids := m.orders[customerID]
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := beforeCommit(ids); err != nil {
+		return nil, err
+	}
+	m.erased = append(m.erased, ids...)
+	delete(m.orders, customerID)
+	return ids, nil
+}
+
+func TestGDPR(t *testing.T) {
+	data := &mockCustomers{orders: map[string][]string{"c/1": {"a", "b"}, "c2": {"x"}}}
+	c := cache.New(10)
+	c.Set("a", json.RawMessage(`{}`))
+	var published, notified []events.Envelope
+	failPublish := false
+	publish := func(ctx context.Context, e events.Envelope) error {
+		if failPublish {
+			return errors.New("kafka down")
+		}
+		published = append(published, e)
+		return nil
+	}
+	notify := func(ctx context.Context, e events.Envelope) error {
+		notified = append(notified, e)
+		return nil
+	}
+	s := New(":0", c, &mockRepo{}, nil, WithGDPR(data, publish, notify))
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.httpSrv.Handler.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := do("GET", "/admin/customers/c%2F1/export")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"customer_id":"c/1"`) ||
+		!strings.Contains(w.Body.String(), `{"order_uid":"b"}`) {
+		t.Fatalf("export: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/admin/customers/c2/erase?mode=shred"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown mode: expected 400, got %d", w.Code)
+	}
+	if w := do("GET", "/admin/customers/c2/erase"); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET erase: expected 405, got %d", w.Code)
+	}
+
+	// событие не опубликовано — ничего не удаляется
+	failPublish = true
+	if w := do("POST", "/admin/customers/c2/erase"); w.Code != http.StatusBadGateway || len(data.erased) != 0 {
+		t.Fatalf("erase with failed publish: %d %v", w.Code, data.erased)
+	}
+	failPublish = false
+
+	w = do("POST", "/admin/customers/c%2F1/erase?mode=delete")
+	var resp eraseResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Mode != db.EraseDelete || len(resp.Orders) != 2 {
+		t.Fatalf("erase: %d %s", w.Code, w.Body.String())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("erased order left in cache")
+	}
+	if len(published) != 2 || len(notified) != 2 || published[0].Type != events.OrderErased || published[1].OrderUID != "b" {
+		t.Fatalf("events: published %v notified %v", published, notified)
+	}
+	if !strings.Contains(string(published[0].Payload), `"mode":"delete"`) {
+		t.Fatalf("event payload %s", published[0].Payload)
+	}
+	// повторный запрос ничего не находит
+	if w := do("POST", "/admin/customers/c%2F1/erase"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"orders":[]`) {
+		t.Fatalf("repeated erase: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// writeCert пишет самоподписанный сертификат (он же CA) и ключ в dir
+func writeCert(t *testing.T, dir, name string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	cert, _ = x509.ParseCertificate(der)
+	return certFile, keyFile, cert
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, serverCert := writeCert(t, dir, "server")
+	clientCert, clientKey, _ := writeCert(t, dir, "client")
+
+	opts := &TLSOptions{CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS12,
+		ClientCAFile: clientCert, ClientAuth: tls.RequireAndVerifyClientCert}
+	cfg, err := opts.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &mockRepo{data: map[string][]byte{"a": []byte(`{"order_uid":"a"}`)}}
+	s := New(":0", cache.New(10), repo, nil, WithHTTPOptions(HTTPOptions{TLS: opts}))
+	ts := httptest.NewUnstartedServer(s.httpSrv.Handler)
+	ts.TLS = cfg
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	// по имени, чтобы был SNI: без него httptest отдаёт свой сертификат
+	url := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+			ForceAttemptHTTP2: true,
+		}}
+	}
+
+	if _, err := client().Get(url + "/order/a"); err == nil {
+		t.Fatal("request without client certificate must fail")
+	}
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client(pair).Get(url + "/order/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Fatalf("expected 200 over HTTP/2, got %d %s", resp.StatusCode, resp.Proto)
+	}
+
+	// новый сертификат подхватывается без перезапуска, но не чаще certCheckInterval
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs.checked = time.Now()
+	time.Sleep(10 * time.Millisecond) // mtime должен измениться
+	_, _, rotated := writeCert(t, dir, "server")
+	serial := func() *big.Int {
+		c, _ := certs.get(nil)
+		leaf, _ := x509.ParseCertificate(c.Certificate[0])
+		return leaf.SerialNumber
+	}
+	if serial().Cmp(serverCert.SerialNumber) != 0 {
+		t.Fatal("certificate reloaded before check interval")
+	}
+	certs.checked = time.Time{}
+	if serial().Cmp(rotated.SerialNumber) != 0 {
+		t.Fatal("certificate was not reloaded")
+	}
 }
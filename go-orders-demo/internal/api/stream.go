@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/pii"
+	"go-orders-demo/internal/stream"
+)
+
+// WithStream включает SSE ленту сохранённых заказов GET /orders/stream
+func WithStream(hub *stream.Hub, heartbeat time.Duration) Option {
+	return func(s *Server) {
+		s.stream = hub
+		s.heartbeat = heartbeat
+	}
+}
+
+// handleStream отдаёт Server-Sent Events с новыми заказами.
+// Фильтры: order_uid, customer_id, delivery_service, entry, locale, status
+// (несколько значений — через запятую или повтором параметра).
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := stream.Filter{
+		OrderUIDs:        queryList(q["order_uid"]),
+		CustomerIDs:      queryList(q["customer_id"]),
+		DeliveryServices: queryList(q["delivery_service"]),
+		Entries:          queryList(q["entry"]),
+		Locales:          queryList(q["locale"]),
+		Statuses:         queryList(q["status"]),
+	}
+
+	// EventSource присылает Last-Event-ID сам; для ручного переподключения — ?last_event_id=
+	lastRaw := r.Header.Get("Last-Event-ID")
+	if lastRaw == "" {
+		lastRaw = q.Get("last_event_id")
+	}
+	var lastID uint64
+	resume := lastRaw != ""
+	if resume {
+		var err error
+		if lastID, err = strconv.ParseUint(lastRaw, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	view := s.pii.For(r.Context())
+	backlog, sub := s.stream.Subscribe(filter, lastID, resume)
+	defer sub.Close()
+
+	// соединение живёт долго — снимаем дедлайны сервера
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, e := range backlog {
+		if writeSSE(w, e, view) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	tick := time.NewTicker(s.heartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// отключены как медленный клиент
+				return
+			}
+			if writeSSE(w, e, view) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-tick.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE пишет событие; view — маска персональных данных клиента (может быть nil)
+func writeSSE(w http.ResponseWriter, e stream.Event, view pii.View) error {
+	data := e.Data
+	if view != nil {
+		var err error
+		if data, err = view(data); err != nil {
+			// без маски событие не отправляем, иначе данные уйдут как есть
+			log.Printf("stream: mask event %d: %v", e.ID, err)
+			return nil
+		}
+	}
+	_, err := fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", e.ID, compactJSON(data))
+	return err
+}
+
+// compactJSON убирает переводы строк: поле data в SSE однострочное
+func compactJSON(raw []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return bytes.ReplaceAll(bytes.ReplaceAll(raw, []byte("\r"), nil), []byte("\n"), nil)
+	}
+	return buf.Bytes()
+}
+
+// queryList разбирает ?k=a,b&k=c в [a b c]
+func queryList(vals []string) []string {
+	var res []string
+	for _, v := range vals {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// certCheckInterval — как часто проверять, не изменились ли файлы сертификата (ротация)
+const certCheckInterval = 10 * time.Second
+
+// HTTPOptions — параметры http.Server; нулевые значения не ограничивают
+type HTTPOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// MaxBodyBytes — предел тела запроса; /ingest/bulk читает тело потоком и ограничен построчно
+	MaxBodyBytes int64
+	// Compression — nil: ответы не сжимаются
+	Compression *CompressionOptions
+	// OrderMaxAge — Cache-Control max-age для GET /order/{id}; 0 — перепроверять каждый раз
+	OrderMaxAge time.Duration
+	// TLS — nil: HTTP без шифрования
+	TLS *TLSOptions
+	// H2C — HTTP/2 без TLS (за балансировщиком, который снимает TLS)
+	H2C bool
+}
+
+// TLSOptions — сертификат сервера и проверка клиентских сертификатов (mTLS)
+type TLSOptions struct {
+	CertFile   string
+	KeyFile    string
+	MinVersion uint16
+	// ClientCAFile — корневые сертификаты клиентов; ClientAuth — когда их требовать
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+}
+
+// WithHTTPOptions задаёт таймауты, пределы размеров, сжатие и TLS сервера
+func WithHTTPOptions(o HTTPOptions) Option {
+	return func(s *Server) { s.httpOpts = o }
+}
+
+// Config собирает tls.Config; сертификат перечитывается при изменении файлов
+func (o TLSOptions) Config() (*tls.Config, error) {
+	certs, err := newCertReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	t := &tls.Config{
+		MinVersion:     o.MinVersion,
+		GetCertificate: certs.get,
+		NextProtos:     []string{http2.NextProtoTLS, "http/1.1"},
+		ClientAuth:     o.ClientAuth,
+	}
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("client ca file contains no certificates")
+		}
+		t.ClientCAs = pool
+	}
+	return t, nil
+}
+
+// certReloader отдаёт сертификат сервера и перечитывает пару файлов, если изменилась
+// одна из них, не чаще раза в certCheckInterval. Битая пара (например, записан только
+// сертификат, а ключ ещё старый) не ломает сервер: остаётся прежний сертификат.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) reload() error {
+	certSt, err := os.Stat(c.certFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	keySt, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert, c.certMod, c.keyMod = &cert, certSt.ModTime(), keySt.ModTime()
+	return nil
+}
+
+func (c *certReloader) changed() bool {
+	certSt, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keySt, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certSt.ModTime().Equal(c.certMod) || !keySt.ModTime().Equal(c.keyMod)
+}
+
+func (c *certReloader) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	if time.Since(c.checked) >= certCheckInterval {
+		c.checked = time.Now()
+		if c.changed() {
+			c.mu.Unlock()
+			if err := c.reload(); err != nil {
+				log.Printf("http: %v", err)
+			} else {
+				log.Printf("http: reloaded certificate %s", c.certFile)
+			}
+			c.mu.Lock()
+		}
+	}
+	defer c.mu.Unlock()
+	return c.cert, nil
+}
+
+// limitBody ограничивает тело запроса; превышение видно обработчику как *http.MaxBytesError
+func limitBody(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest/bulk" {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withH2C принимает HTTP/2 без TLS (prior knowledge и Upgrade: h2c)
+func withH2C(h http.Handler) http.Handler {
+	return h2c.NewHandler(h, &http2.Server{})
+}
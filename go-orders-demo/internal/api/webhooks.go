@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/webhooks"
+)
+
+// WithWebhooks включает API управления подписками /webhooks
+func WithWebhooks(svc *webhooks.Service) Option {
+	return func(s *Server) { s.webhooks = svc }
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// webhookCreated — ответ на регистрацию; секрет показывается только здесь
+type webhookCreated struct {
+	db.Webhook
+	Secret string `json:"secret"`
+}
+
+// handleWebhooks: POST /webhooks — регистрация, GET /webhooks — список
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.webhooks.List(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []db.Webhook{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON format", http.StatusBadRequest)
+			return
+		}
+		hook, err := s.webhooks.Register(r.Context(), req.URL, req.Events, req.Secret)
+		if errors.Is(err, webhooks.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, webhookCreated{Webhook: hook, Secret: hook.Secret})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhook: GET|DELETE /webhooks/{id}, GET /webhooks/{id}/deliveries
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/webhooks/")
+	idStr, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid webhook id", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		hook, err := s.webhooks.Get(r.Context(), id)
+		if writeLookupError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusOK, hook)
+	case sub == "" && r.Method == http.MethodDelete:
+		if writeLookupError(w, s.webhooks.Delete(r.Context(), id)) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case sub == "deliveries" && r.Method == http.MethodGet:
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > 1000 {
+				http.Error(w, "limit must be in 1..1000", http.StatusBadRequest)
+				return
+			}
+		}
+		list, err := s.webhooks.Deliveries(r.Context(), id, limit)
+		if writeLookupError(w, err) {
+			return
+		}
+		if list == nil {
+			list = []db.WebhookDelivery{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case sub == "" || sub == "deliveries":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeLookupError пишет 404/500 и сообщает, была ли ошибка
+func writeLookupError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
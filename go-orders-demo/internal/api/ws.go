@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"go-orders-demo/internal/ws"
+)
+
+// WithWebSocket включает WebSocket подписки на заказы GET /orders/ws
+func WithWebSocket(hub *ws.Hub) Option {
+	return func(s *Server) { s.ws = hub }
+}
+
+// handleWS — клиент шлёт {"action":"subscribe","order_uid":"…"} и получает
+// {"type":"order","order_uid":"…","data":{…}} при каждом изменении заказа
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if view := s.pii.For(r.Context()); view != nil {
+		s.ws.ServeView(w, r, ws.View(view))
+		return
+	}
+	s.ws.Serve(w, r)
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go-orders-demo/internal/db"
+)
+
+// Роли; admin включает остальные
+const (
+	RoleReader   = "reader"
+	RoleIngester = "ingester"
+	RoleAdmin    = "admin"
+)
+
+// ValidRole сообщает, известна ли роль
+func ValidRole(r string) bool {
+	return r == RoleReader || r == RoleIngester || r == RoleAdmin
+}
+
+// Способы аутентификации
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal — аутентифицированный клиент
+type Principal struct {
+	Subject string   // имя API ключа или sub токена
+	Method  string   // MethodAPIKey или MethodJWT
+	Roles   []string // роли; неизвестные игнорируются
+}
+
+// Has проверяет роль; admin разрешено всё
+func (p Principal) Has(role string) bool {
+	for _, r := range p.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext возвращает клиента запроса, если запрос аутентифицирован
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// KeyPrefix отличает API ключи от JWT в заголовке Authorization: Bearer
+const KeyPrefix = "ok_"
+
+// NewKey генерирует API ключ; показывается один раз, в БД хранится HashKey
+func NewKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashKey — SHA-256 ключа: ключи случайные и длинные, медленный хеш не нужен
+func HashKey(key string) []byte {
+	h := sha256.Sum256([]byte(key))
+	return h[:]
+}
+
+// KeyStore — хранилище API ключей (реализуется db.SQLStore)
+type KeyStore interface {
+	LookupAPIKey(ctx context.Context, hash []byte) (db.APIKey, error)
+}
+
+// Auditor — журнал аудита (реализуется db.SQLStore)
+type Auditor interface {
+	RecordAudit(ctx context.Context, e db.AuditEntry) error
+}
+
+// Options — проверка JWT
+type Options struct {
+	Issuer     string // пусто — не проверяется
+	Audience   string // пусто — не проверяется
+	RolesClaim string // claim со списком ролей, по умолчанию "roles"
+}
+
+// Authenticator проверяет API ключи (по хешу в БД) и JWT (по ключам из JWKS)
+type Authenticator struct {
+	keys KeyStore
+	jwks *JWKS
+	opts Options
+}
+
+// New: keys или jwks может быть nil — тогда этот способ отключён
+func New(keys KeyStore, jwks *JWKS, opts Options) *Authenticator {
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+	return &Authenticator{keys: keys, jwks: jwks, opts: opts}
+}
+
+// Authenticate проверяет учётные данные из заголовков
+// Authorization: Bearer <jwt|api key> или X-API-Key: <api key>
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (Principal, error) {
+	if apiKey != "" {
+		return a.apiKey(ctx, apiKey)
+	}
+	scheme, cred, _ := strings.Cut(strings.TrimSpace(authorization), " ")
+	cred = strings.TrimSpace(cred)
+	switch {
+	case authorization == "":
+		return Principal{}, ErrNoCredentials
+	case !strings.EqualFold(scheme, "Bearer") || cred == "":
+		return Principal{}, fmt.Errorf("%w: unsupported authorization scheme", ErrInvalidCredentials)
+	case strings.HasPrefix(cred, KeyPrefix):
+		return a.apiKey(ctx, cred)
+	}
+	return a.token(cred)
+}
+
+func (a *Authenticator) apiKey(ctx context.Context, key string) (Principal, error) {
+	if a.keys == nil {
+		return Principal{}, fmt.Errorf("%w: api keys are disabled", ErrInvalidCredentials)
+	}
+	k, err := a.keys.LookupAPIKey(ctx, HashKey(key))
+	if errors.Is(err, db.ErrNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown or revoked api key", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return Principal{}, fmt.Errorf("lookup api key: %w", err)
+	}
+	return Principal{Subject: k.Name, Method: MethodAPIKey, Roles: k.Roles}, nil
+}
+
+func (a *Authenticator) token(raw string) (Principal, error) {
+	if a.jwks == nil {
+		return Principal{}, fmt.Errorf("%w: jwt is disabled", ErrInvalidCredentials)
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.opts.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.opts.Issuer))
+	}
+	if a.opts.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.opts.Audience))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, a.jwks.keyfunc, opts...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return Principal{}, fmt.Errorf("%w: token without sub", ErrInvalidCredentials)
+	}
+	return Principal{Subject: sub, Method: MethodJWT, Roles: claimRoles(claims[a.opts.RolesClaim])}, nil
+}
+
+// claimRoles принимает массив строк или строку через пробел (как scope)
+func claimRoles(v any) []string {
+	var roles []string
+	switch t := v.(type) {
+	case string:
+		roles = strings.Fields(t)
+	case []any:
+		for _, r := range t {
+			if s, ok := r.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+	return roles
+}
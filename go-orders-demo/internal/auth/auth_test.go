@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go-orders-demo/internal/db"
+)
+
+type fakeKeys map[string]db.APIKey // по hex хешу
+
+func (f fakeKeys) LookupAPIKey(ctx context.Context, hash []byte) (db.APIKey, error) {
+	k, ok := f[fmt.Sprintf("%x", hash)]
+	if !ok {
+		return db.APIKey{}, db.ErrNotFound
+	}
+	return k, nil
+}
+
+func TestAPIKey(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(fakeKeys{fmt.Sprintf("%x", HashKey(key)): {Name: "partner", Roles: []string{RoleReader}}}, nil, Options{})
+
+	for _, h := range [][2]string{{"Bearer " + key, ""}, {"", key}} {
+		p, err := a.Authenticate(context.Background(), h[0], h[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Subject != "partner" || p.Method != MethodAPIKey || !p.Has(RoleReader) || p.Has(RoleIngester) {
+			t.Fatalf("unexpected principal %+v", p)
+		}
+	}
+	if _, err := a.Authenticate(context.Background(), "", ""); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+	if _, err := a.Authenticate(context.Background(), "", KeyPrefix+"unknown"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := a.Authenticate(context.Background(), "Basic dTpw", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for basic auth, got %v", err)
+	}
+}
+
+func writeJWKS(t *testing.T, path, kid string, pub *ecdsa.PublicKey) {
+	t.Helper()
+	enc := base64.RawURLEncoding.EncodeToString
+	doc := fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","kid":%q,"use":"sig","x":%q,"y":%q}]}`,
+		kid, enc(pub.X.FillBytes(make([]byte, 32))), enc(pub.Y.FillBytes(make([]byte, 32))))
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, "k1", &priv.PublicKey)
+	jwks, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(nil, jwks, Options{Issuer: "idp", Audience: "orders", RolesClaim: "scope"})
+
+	sign := func(key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + s
+	}
+	claims := func(mod func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": "svc-1", "iss": "idp", "aud": "orders", "scope": "ingester reader",
+			"exp": time.Now().Add(time.Hour).Unix()}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+
+	p, err := a.Authenticate(context.Background(), sign(priv, "k1", claims(nil)), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "svc-1" || p.Method != MethodJWT || !p.Has(RoleIngester) || p.Has(RoleAdmin) {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	bad := map[string]string{
+		"expired":   sign(priv, "k1", claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+		"no exp":    sign(priv, "k1", claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+		"issuer":    sign(priv, "k1", claims(func(c jwt.MapClaims) { c["iss"] = "evil" })),
+		"audience":  sign(priv, "k1", claims(func(c jwt.MapClaims) { c["aud"] = "billing" })),
+		"no sub":    sign(priv, "k1", claims(func(c jwt.MapClaims) { delete(c, "sub") })),
+		"signature": sign(other, "k1", claims(nil)),
+		"kid":       sign(priv, "k2", claims(nil)),
+	}
+	for name, h := range bad {
+		if _, err := a.Authenticate(context.Background(), h, ""); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+
+	// ротация: новый файл подхватывается без перезапуска
+	writeJWKS(t, path, "k2", &other.PublicKey)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	jwks.mu.Lock()
+	jwks.checked = time.Time{}
+	jwks.mu.Unlock()
+	if _, err := a.Authenticate(context.Background(), sign(other, "k2", claims(nil)), ""); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksCheckInterval — как часто проверять, не изменился ли файл JWKS (ротация ключей)
+const jwksCheckInterval = time.Minute
+
+// JWKS — открытые ключи проверки JWT из локального файла (RFC 7517).
+// Файл перечитывается, если изменился, не чаще раза в jwksCheckInterval.
+type JWKS struct {
+	path string
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey // по kid
+	modTime time.Time
+	checked time.Time
+}
+
+// LoadJWKS читает файл; ошибка, если в нём нет ни одного пригодного ключа
+func LoadJWKS(path string) (*JWKS, error) {
+	j := &JWKS{path: path}
+	if err := j.reload(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWKS) reload() error {
+	st, err := os.Stat(j.path)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	b, err := os.ReadFile(j.path)
+	if err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("jwks %s: %w", j.path, err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwks %s: key %d (%s): %w", j.path, i, k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("jwks %s: no signing keys", j.path)
+	}
+	j.mu.Lock()
+	j.keys, j.modTime = keys, st.ModTime()
+	j.mu.Unlock()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := b64Int(k.N)
+		e, err2 := b64Int(k.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err1 := b64Int(k.X)
+		y, err2 := b64Int(k.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("bad base64url number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// key возвращает ключ по kid; при пустом kid — единственный ключ набора
+func (j *JWKS) key(kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	if time.Since(j.checked) >= jwksCheckInterval {
+		j.checked = time.Now()
+		if st, err := os.Stat(j.path); err == nil && !st.ModTime().Equal(j.modTime) {
+			j.mu.Unlock()
+			// битый файл не ломает проверку: остаются прежние ключи
+			if err := j.reload(); err != nil {
+				log.Printf("auth: %v", err)
+			}
+			j.mu.Lock()
+		}
+	}
+	defer j.mu.Unlock()
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k, nil
+		}
+	}
+	k, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return k, nil
+}
+
+func (j *JWKS) keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	return j.key(kid)
+}
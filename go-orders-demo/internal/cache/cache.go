@@ -3,26 +3,103 @@ package cache
 import (
 	"encoding/json"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Entry — версия заказа в кеше. Запись не меняется, Put заменяет её целиком,
+// поэтому Raw, Modified и сжатые копии всегда относятся к одной версии.
+type Entry struct {
+	Raw json.RawMessage
+	// Modified — orders.updated_at; пустое, если время изменения неизвестно (Set)
+	Modified time.Time
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+// Encoded возвращает сжатую копию заказа (encoding — значение Content-Encoding).
+// encode вызывается один раз на версию заказа: горячие заказы не сжимаются на каждый запрос.
+func (e *Entry) Encoded(encoding string, encode func(json.RawMessage) ([]byte, error)) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if b, ok := e.encoded[encoding]; ok {
+		return b, nil
+	}
+	b, err := encode(e.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if e.encoded == nil {
+		e.encoded = make(map[string][]byte, 2)
+	}
+	e.encoded[encoding] = b
+	return b, nil
+}
+
 type Cache struct {
 	mu   sync.RWMutex
 	cap  int
-	data map[string]json.RawMessage
+	data map[string]*Entry
+
+	hits, misses atomic.Uint64
+}
+
+// Stats — заполненность кеша и попадания Get/Entry с запуска
+type Stats struct {
+	Entries int    `json:"entries"`
+	Limit   int    `json:"limit"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
 }
 
 func New(limit int) *Cache {
-	return &Cache{cap: limit, data: make(map[string]json.RawMessage, limit)}
+	return &Cache{cap: limit, data: make(map[string]*Entry, limit)}
 }
 
 func (c *Cache) Get(id string) (json.RawMessage, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	r, ok := c.data[id]
-	return r, ok
+	e, ok := c.data[id]
+	c.count(ok)
+	if !ok {
+		return nil, false
+	}
+	return e.Raw, true
+}
+
+// Entry возвращает текущую версию заказа
+func (c *Cache) Entry(id string) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.data[id]
+	c.count(ok)
+	return e, ok
 }
 
+func (c *Cache) count(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Stats{Entries: len(c.data), Limit: c.cap, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Set кладёт заказ без времени изменения: для такой записи Last-Modified не отдаётся.
+// Заказы из БД кладутся через Put и BulkLoad с orders.updated_at.
 func (c *Cache) Set(id string, raw json.RawMessage) {
+	c.Put(id, raw, time.Time{})
+}
+
+// Put кладёт заказ со временем изменения из БД и возвращает новую запись
+func (c *Cache) Put(id string, raw json.RawMessage, modified time.Time) *Entry {
+	e := &Entry{Raw: raw, Modified: modified}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.cap > 0 && len(c.data) >= c.cap {
@@ -31,16 +108,31 @@ func (c *Cache) Set(id string, raw json.RawMessage) {
 			break
 		}
 	}
-	c.data[id] = raw
+	c.data[id] = e
+	return e
+}
+
+// Delete убирает заказ из кеша этого экземпляра. Другие экземпляры об этом не узнают:
+// их копия живёт до вытеснения или перезапуска (см. README, удаление данных покупателя).
+func (c *Cache) Delete(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, id)
+}
+
+// Version — заказ и его orders.updated_at для BulkLoad
+type Version struct {
+	Raw      json.RawMessage
+	Modified time.Time
 }
 
-func (c *Cache) BulkLoad(m map[string]json.RawMessage) {
+func (c *Cache) BulkLoad(m map[string]Version) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for k, v := range m {
 		if c.cap > 0 && len(c.data) >= c.cap {
 			break
 		}
-		c.data[k] = v
+		c.data[k] = &Entry{Raw: v.Raw, Modified: v.Modified}
 	}
-}
\ No newline at end of file
+}
@@ -1,17 +1,70 @@
 package cache
 
 import (
-    "encoding/json"
-    "testing"
+	"encoding/json"
+	"testing"
+	"time"
 )
 
 func TestCacheSetGet(t *testing.T) {
-    c := New(2)
-    sample := json.RawMessage(`{"order_uid":"a1"}`)
-    c.Set("a1", sample)
-    if got, ok := c.Get("a1"); !ok {
-        t.Fatal("expected key present")
-    } else if string(got) != string(sample) {
-        t.Fatalf("unexpected value: %s", string(got))
-    }
+	c := New(2)
+	sample := json.RawMessage(`{"order_uid":"a1"}`)
+	c.Set("a1", sample)
+	if got, ok := c.Get("a1"); !ok {
+		t.Fatal("expected key present")
+	} else if string(got) != string(sample) {
+		t.Fatalf("unexpected value: %s", string(got))
+	}
+}
+
+func TestCacheDelete(t *testing.T) {
+	c := New(2)
+	c.Set("a1", json.RawMessage(`{}`))
+	c.Delete("a1")
+	c.Delete("missing")
+	if _, ok := c.Get("a1"); ok {
+		t.Fatal("expected key removed")
+	}
+}
+
+func TestEntryEncoded(t *testing.T) {
+	c := New(2)
+	c.Set("a1", json.RawMessage(`{"v":1}`))
+	e, _ := c.Entry("a1")
+	calls := 0
+	encode := func(raw json.RawMessage) ([]byte, error) {
+		calls++
+		return append([]byte("z:"), raw...), nil
+	}
+	for i := 0; i < 2; i++ {
+		if b, err := e.Encoded("gzip", encode); err != nil || string(b) != `z:{"v":1}` {
+			t.Fatalf("encoded %q %v", b, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("encode called %d times", calls)
+	}
+
+	// новая версия заказа — новая запись без старых сжатых копий
+	modified := time.Date(2024, 6, 19, 10, 0, 0, 0, time.UTC)
+	c.Put("a1", json.RawMessage(`{"v":2}`), modified)
+	e, _ = c.Entry("a1")
+	if b, _ := e.Encoded("gzip", encode); string(b) != `z:{"v":2}` || !e.Modified.Equal(modified) {
+		t.Fatalf("stale entry %q %v", b, e.Modified)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c := New(3)
+	modified := time.Date(2024, 6, 19, 10, 0, 0, 0, time.UTC)
+	c.BulkLoad(map[string]Version{"a1": {Raw: json.RawMessage(`{}`), Modified: modified}, "a2": {Raw: json.RawMessage(`{}`)}})
+	c.Get("a1")
+	c.Entry("a2")
+	c.Get("missing")
+	if st := c.Stats(); st != (Stats{Entries: 2, Limit: 3, Hits: 2, Misses: 1}) {
+		t.Fatalf("stats %+v", st)
+	}
+	if e, _ := c.Entry("a1"); !e.Modified.Equal(modified) {
+		t.Fatalf("bulk loaded Modified %v, want orders.updated_at", e.Modified)
+	}
 }
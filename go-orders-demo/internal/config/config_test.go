@@ -0,0 +1,176 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envOf(m map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := m[k]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	yml := `
+http:
+  addr: ":9000"
+kafka:
+  brokers: ["a:9092", "b:9092"]
+  topic: from-file
+cache:
+  limit: 50
+  warmup_timeout: 3s
+`
+	if err := os.WriteFile(path, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"KAFKA_TOPIC": "from-env", "CACHE_LIMIT": "70"}
+	cfg, err := load([]string{"-config", path, "-cache-limit", "90"}, envOf(env))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HTTP.Addr != ":9000" {
+		t.Errorf("http.addr = %q, want value from file", cfg.HTTP.Addr)
+	}
+	if len(cfg.Kafka.Brokers) != 2 {
+		t.Errorf("kafka.brokers = %v, want 2 brokers", cfg.Kafka.Brokers)
+	}
+	if cfg.Kafka.Topic != "from-env" {
+		t.Errorf("kafka.topic = %q, env must override file", cfg.Kafka.Topic)
+	}
+	if cfg.Cache.Limit != 90 {
+		t.Errorf("cache.limit = %d, flag must override env", cfg.Cache.Limit)
+	}
+	if cfg.Cache.WarmupTimeout != 3*time.Second {
+		t.Errorf("cache.warmup_timeout = %v", cfg.Cache.WarmupTimeout)
+	}
+	if cfg.Kafka.Group != "orders-consumer" {
+		t.Errorf("kafka.group = %q, want default", cfg.Kafka.Group)
+	}
+}
+
+// переключатели работают без значения и не забирают следующий аргумент
+func TestBareBoolFlags(t *testing.T) {
+	dir := t.TempDir()
+	crt, key := filepath.Join(dir, "x.crt"), filepath.Join(dir, "x.key")
+	for _, p := range []string{crt, key} {
+		if err := os.WriteFile(p, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := map[string]string{"OUTBOX_ENABLED": "false"}
+	cfg, err := load([]string{
+		"-outbox", "-http-tls", "-http-tls-cert-file", crt, "-http-tls-key-file", key,
+		"-postgres-migrate=false", "-print-config",
+	}, envOf(env))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Outbox.Enabled || !cfg.HTTP.TLS.Enabled || !cfg.PrintConfig {
+		t.Errorf("outbox=%v http.tls=%v print-config=%v, want all true", cfg.Outbox.Enabled, cfg.HTTP.TLS.Enabled, cfg.PrintConfig)
+	}
+	if cfg.HTTP.TLS.CertFile != crt || cfg.HTTP.TLS.KeyFile != key {
+		t.Errorf("tls files = %q, %q", cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile)
+	}
+	if cfg.Postgres.Migrate {
+		t.Error("postgres.migrate: -postgres-migrate=false ignored")
+	}
+	if _, err := load([]string{"-outbox=maybe"}, envOf(nil)); err == nil {
+		t.Error("-outbox=maybe accepted")
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	data := "[kafka]\nbrokers = [\"k1:9092\"]\n[http]\nshutdown_timeout = \"7s\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := load(nil, envOf(map[string]string{"CONFIG_FILE": path}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Kafka.Brokers[0] != "k1:9092" || cfg.HTTP.ShutdownTimeout != 7*time.Second {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	env := map[string]string{
+		"CACHE_LIMIT":   "-1",
+		"KAFKA_BROKERS": "no-port",
+		"KAFKA_FORMAT":  "xml",
+		"POSTGRES_DSN":  "postgres://%zz",
+	}
+	_, err := load(nil, envOf(env))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"cache.limit", "kafka.brokers", "kafka.format", "postgres.dsn"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	if _, err := load(nil, envOf(map[string]string{"CACHE_LIMIT": "abc"})); err == nil {
+		t.Fatal("expected parse error for CACHE_LIMIT")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Postgres.DSN = "postgres://app:secret@db:5432/orders"
+	if r := cfg.Redacted(); strings.Contains(r.Postgres.DSN, "secret") {
+		t.Fatalf("password leaked: %s", r.Postgres.DSN)
+	}
+	cfg.Postgres.DSN = "host=db user=app password=secret dbname=orders"
+	if r := cfg.Redacted(); strings.Contains(r.Postgres.DSN, "secret") {
+		t.Fatalf("password leaked: %s", r.Postgres.DSN)
+	}
+
+	var sb strings.Builder
+	if err := cfg.Print(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), "secret") || !strings.Contains(sb.String(), "brokers") {
+		t.Fatalf("unexpected output:\n%s", sb.String())
+	}
+}
+
+func TestKafkaSecurityValidation(t *testing.T) {
+	env := map[string]string{
+		"KAFKA_SASL_MECHANISM": "SCRAM-SHA-512",
+		"KAFKA_SASL_PASSWORD":  "secret",
+	}
+	if _, err := load(nil, envOf(env)); err == nil || !strings.Contains(err.Error(), "kafka.sasl.username") {
+		t.Fatalf("expected username error, got %v", err)
+	}
+
+	env["KAFKA_SASL_USERNAME"] = "app"
+	env["KAFKA_TLS_ENABLED"] = "true"
+	env["KAFKA_TLS_CERT_FILE"] = filepath.Join(t.TempDir(), "missing.pem")
+	_, err := load(nil, envOf(env))
+	if err == nil || !strings.Contains(err.Error(), "cert_file and key_file") || !strings.Contains(err.Error(), "kafka.tls.cert_file") {
+		t.Fatalf("expected tls errors, got %v", err)
+	}
+
+	delete(env, "KAFKA_TLS_CERT_FILE")
+	cfg, err := load(nil, envOf(env))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Kafka.SASL.Mechanism != SASLScramSHA512 {
+		t.Errorf("mechanism = %q", cfg.Kafka.SASL.Mechanism)
+	}
+	if cfg.Redacted().Kafka.SASL.Password == "secret" {
+		t.Error("sasl password not redacted")
+	}
+}
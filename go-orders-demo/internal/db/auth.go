@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey — статический ключ доступа к API; сам ключ не хранится, только его хеш
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Roles      []string   `json:"roles"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *SQLStore) CreateAPIKey(ctx context.Context, name string, hash []byte, roles []string) (APIKey, error) {
+	k := APIKey{Name: name, Roles: roles}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (name, key_hash, roles) VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, name, hash, pq.Array(roles)).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("insert api key: %w", err)
+	}
+	return k, nil
+}
+
+// LookupAPIKey находит действующий (не отозванный) ключ по хешу и отмечает его использование
+func (s *SQLStore) LookupAPIKey(ctx context.Context, hash []byte) (APIKey, error) {
+	var k APIKey
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, name, roles, created_at, last_used_at
+	`, hash).Scan(&k.ID, &k.Name, pq.Array(&k.Roles), &k.CreatedAt, &k.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrNotFound
+	}
+	return k, err
+}
+
+func (s *SQLStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, roles, created_at, last_used_at, revoked_at FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, pq.Array(&k.Roles), &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, k)
+	}
+	return res, rows.Err()
+}
+
+// RevokeAPIKey отзывает ключ; ErrNotFound, если ключа нет или он уже отозван
+func (s *SQLStore) RevokeAPIKey(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AuditEntry — запись журнала аудита: кто, что и с каким результатом сделал
+type AuditEntry struct {
+	At         time.Time `json:"at"`
+	Principal  string    `json:"principal"`
+	AuthMethod string    `json:"auth_method"`
+	Roles      []string  `json:"roles"`
+	Action     string    `json:"action"` // например "POST /ingest"
+	Status     int       `json:"status"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+func (s *SQLStore) RecordAudit(ctx context.Context, e AuditEntry) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (at, principal, auth_method, roles, action, status, remote_addr)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, e.At, e.Principal, e.AuthMethod, pq.Array(e.Roles), e.Action, e.Status, e.RemoteAddr)
+	return err
+}
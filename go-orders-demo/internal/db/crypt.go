@@ -0,0 +1,480 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"go-orders-demo/internal/keyring"
+	"go-orders-demo/internal/models"
+)
+
+// ErrNoKeyRing — в БД зашифрованные данные, а кольцо ключей не настроено
+var ErrNoKeyRing = errors.New("data is encrypted, keyring is not configured")
+
+// piiFields — поля delivery, которые шифруются и в payload, и в deliveries
+var piiFields = []string{"name", "phone", "email", "address"}
+
+// WithKeyRing включает шифрование персональных данных получателя: новые записи шифруются
+// основным ключом кольца, при чтении расшифровываются любым ключом кольца.
+// Незашифрованные строки (записанные до включения) читаются как есть.
+//
+// Зашифрована ли строка, решает только колонка pii_key (orders, deliveries, outbox,
+// webhook_deliveries): ключ, которым она записана, или NULL. Значения, похожие на
+// шифртекст, в строках с NULL — просто данные клиента, их не расшифровывают.
+func (s *SQLStore) WithKeyRing(kr *keyring.KeyRing) *SQLStore {
+	s.keys = kr
+	return s
+}
+
+// aad привязывает шифртекст к заказу и полю
+func aad(id, field string) string { return id + "/delivery." + field }
+
+// eventAAD привязывает шифртекст payload события к событию
+func eventAAD(eventID string) string { return "event/" + eventID }
+
+// sealedWith — значение pii_key для записываемой строки: основной ключ кольца, без кольца NULL
+func (s *SQLStore) sealedWith() sql.NullString {
+	if s.keys == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: s.keys.Primary(), Valid: true}
+}
+
+// contactIndex — blind index телефона и e-mail для orders.phone_bidx/email_bidx
+type contactIndex struct {
+	phone, email []byte
+}
+
+func (s *SQLStore) index(phone, email string) contactIndex {
+	if s.keys == nil {
+		return contactIndex{}
+	}
+	return contactIndex{
+		phone: s.keys.BlindIndex(keyring.IndexPhone, phone),
+		email: s.keys.BlindIndex(keyring.IndexEmail, email),
+	}
+}
+
+// mapDelivery вызывает fn для непустых строковых полей fields в payload.delivery и возвращает
+// payload с заменёнными значениями. Остальной документ не трогается (числа не переформатируются);
+// payload без delivery возвращается как есть.
+func mapDelivery(raw json.RawMessage, fields []string, fn func(field, v string) (string, error)) (json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc["delivery"] == nil {
+		return raw, nil
+	}
+	var d map[string]json.RawMessage
+	if err := json.Unmarshal(doc["delivery"], &d); err != nil || d == nil {
+		return raw, nil
+	}
+	changed := false
+	for _, field := range fields {
+		var v string
+		if d[field] == nil || json.Unmarshal(d[field], &v) != nil || v == "" {
+			continue
+		}
+		nv, err := fn(field, v)
+		if err != nil {
+			return nil, fmt.Errorf("delivery.%s: %w", field, err)
+		}
+		if nv != v {
+			d[field] = marshalString(nv)
+			changed = true
+		}
+	}
+	if !changed {
+		return raw, nil
+	}
+	b, err := marshalNoEscape(d)
+	if err != nil {
+		return nil, err
+	}
+	doc["delivery"] = b
+	return marshalNoEscape(doc)
+}
+
+func marshalString(s string) json.RawMessage {
+	b, _ := marshalNoEscape(s)
+	return b
+}
+
+func marshalNoEscape(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// sealPayload шифрует персональные данные в payload и считает blind index по открытым значениям.
+// Значения шифруются как есть, даже если похожи на шифртекст. Без кольца payload не меняется.
+func (s *SQLStore) sealPayload(id string, raw json.RawMessage) (json.RawMessage, contactIndex, error) {
+	if s.keys == nil || raw == nil {
+		return raw, contactIndex{}, nil
+	}
+	var phone, email string
+	sealed, err := mapDelivery(raw, piiFields, func(field, v string) (string, error) {
+		switch field {
+		case "phone":
+			phone = v
+		case "email":
+			email = v
+		}
+		return s.keys.Seal(v, aad(id, field))
+	})
+	if err != nil {
+		return nil, contactIndex{}, fmt.Errorf("encrypt payload: %w", err)
+	}
+	return sealed, s.index(phone, email), nil
+}
+
+// openPayload расшифровывает персональные данные в payload строки с pii_key = key;
+// key == "" — строка записана открытой
+func (s *SQLStore) openPayload(id, key string, raw json.RawMessage) (json.RawMessage, error) {
+	if key == "" || raw == nil {
+		return raw, nil
+	}
+	if s.keys == nil {
+		return nil, fmt.Errorf("order %s: %w", id, ErrNoKeyRing)
+	}
+	res, err := mapDelivery(raw, piiFields, func(field, v string) (string, error) {
+		return s.keys.Open(v, aad(id, field))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("order %s: decrypt payload: %w", id, err)
+	}
+	return res, nil
+}
+
+// deliveryFields — указатели на шифруемые поля models.Delivery в порядке piiFields
+func deliveryFields(d *models.Delivery) []*string {
+	return []*string{&d.Name, &d.Phone, &d.Email, &d.Address}
+}
+
+// sealDelivery возвращает копию d с зашифрованными персональными данными
+func (s *SQLStore) sealDelivery(id string, d models.Delivery) (models.Delivery, error) {
+	if s.keys == nil {
+		return d, nil
+	}
+	for i, p := range deliveryFields(&d) {
+		if *p == "" {
+			continue
+		}
+		var err error
+		if *p, err = s.keys.Seal(*p, aad(id, piiFields[i])); err != nil {
+			return d, fmt.Errorf("encrypt delivery: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// openDelivery расшифровывает на месте поля d из строки deliveries с pii_key = key
+func (s *SQLStore) openDelivery(id, key string, d *models.Delivery) error {
+	if key == "" {
+		return nil
+	}
+	if s.keys == nil {
+		return fmt.Errorf("order %s: %w", id, ErrNoKeyRing)
+	}
+	for i, p := range deliveryFields(d) {
+		if *p == "" {
+			continue
+		}
+		v, err := s.keys.Open(*p, aad(id, piiFields[i]))
+		if err != nil {
+			return fmt.Errorf("order %s: decrypt delivery.%s: %w", id, piiFields[i], err)
+		}
+		*p = v
+	}
+	return nil
+}
+
+// sealEvent шифрует payload конверта события целиком: в нём бывает заказ с данными получателя.
+// Остальные поля конверта (event_id, type, order_uid) остаются открытыми. Без кольца raw не меняется.
+func (s *SQLStore) sealEvent(eventID string, raw json.RawMessage) (json.RawMessage, error) {
+	if s.keys == nil {
+		return raw, nil
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("event %s: %w", eventID, err)
+	}
+	sealed, err := s.keys.Seal(string(doc["payload"]), eventAAD(eventID))
+	if err != nil {
+		return nil, fmt.Errorf("event %s: encrypt payload: %w", eventID, err)
+	}
+	doc["payload"] = marshalString(sealed)
+	return marshalNoEscape(doc)
+}
+
+// openEvent расшифровывает payload конверта из строки webhook_deliveries с pii_key = key
+func (s *SQLStore) openEvent(eventID, key string, raw json.RawMessage) (json.RawMessage, error) {
+	if key == "" {
+		return raw, nil
+	}
+	if s.keys == nil {
+		return nil, fmt.Errorf("event %s: %w", eventID, ErrNoKeyRing)
+	}
+	var doc map[string]json.RawMessage
+	var sealed string
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("event %s: %w", eventID, err)
+	}
+	if err := json.Unmarshal(doc["payload"], &sealed); err != nil {
+		return nil, fmt.Errorf("event %s: encrypted payload: %w", eventID, err)
+	}
+	payload, err := s.keys.Open(sealed, eventAAD(eventID))
+	if err != nil {
+		return nil, fmt.Errorf("event %s: decrypt payload: %w", eventID, err)
+	}
+	doc["payload"] = json.RawMessage(payload)
+	return marshalNoEscape(doc)
+}
+
+// ReencryptStats — итог ReencryptBatch
+type ReencryptStats struct {
+	Scanned    int // просмотрено заказов
+	Orders     int // переписано orders (payload или blind index)
+	Deliveries int // переписано строк deliveries
+}
+
+// stale — строку с pii_key = key и значениями values надо переписать основным ключом:
+// она записана открытой или другим ключом, или в ней осталось значение не под основным ключом
+func (s *SQLStore) stale(key string, values ...string) bool {
+	if key != s.keys.Primary() {
+		return true
+	}
+	for _, v := range values {
+		if s.keys.NeedsReseal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReencryptBatch перешифровывает основным ключом до limit заказов с order_uid больше after
+// (по возрастанию order_uid): значения под старыми ключами и незашифрованные, а также пересчитывает
+// blind index. Возвращает последний просмотренный order_uid ("" — заказов больше нет).
+// dryRun только считает, что было бы переписано. outbox и webhook_deliveries — в
+// ReencryptOutboxBatch и ReencryptWebhookBatch.
+func (s *SQLStore) ReencryptBatch(ctx context.Context, after string, limit int, dryRun bool) (string, ReencryptStats, error) {
+	var st ReencryptStats
+	if s.keys == nil {
+		return "", st, ErrNoKeyRing
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", st, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT order_uid, payload, COALESCE(pii_key, ''), phone_bidx, email_bidx FROM orders
+		WHERE order_uid > $1 ORDER BY order_uid LIMIT $2 FOR UPDATE`, after, limit)
+	if err != nil {
+		return "", st, err
+	}
+	type orderRow struct {
+		id           string
+		payload      []byte
+		key          string
+		phone, email []byte
+	}
+	var orders []orderRow
+	var ids []string
+	for rows.Next() {
+		var o orderRow
+		if err := rows.Scan(&o.id, &o.payload, &o.key, &o.phone, &o.email); err != nil {
+			rows.Close()
+			return "", st, err
+		}
+		orders = append(orders, o)
+		ids = append(ids, o.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", st, err
+	}
+	if len(orders) == 0 {
+		return "", st, nil
+	}
+
+	for _, o := range orders {
+		st.Scanned++
+		if o.payload == nil {
+			continue
+		}
+		var values []string
+		if _, err := mapDelivery(o.payload, piiFields, func(field, v string) (string, error) {
+			values = append(values, v)
+			return v, nil
+		}); err != nil {
+			return "", st, fmt.Errorf("order %s: %w", o.id, err)
+		}
+		payload, err := s.openPayload(o.id, o.key, o.payload)
+		if err != nil {
+			return "", st, err
+		}
+		sealed, idx, err := s.sealPayload(o.id, payload)
+		if err != nil {
+			return "", st, fmt.Errorf("order %s: %w", o.id, err)
+		}
+		if !s.stale(o.key, values...) && bytes.Equal(idx.phone, o.phone) && bytes.Equal(idx.email, o.email) {
+			continue
+		}
+		st.Orders++
+		if dryRun {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE orders SET payload=$2, pii_key=$3, phone_bidx=$4, email_bidx=$5 WHERE order_uid=$1`,
+			o.id, sealed, s.sealedWith(), idx.phone, idx.email)
+		if err != nil {
+			return "", st, fmt.Errorf("order %s: %w", o.id, err)
+		}
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT id, order_uid, COALESCE(pii_key, ''), COALESCE(name, ''), COALESCE(phone, ''),
+			COALESCE(email, ''), COALESCE(address, '')
+		FROM deliveries WHERE order_uid = ANY($1) FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return "", st, err
+	}
+	type deliveryRow struct {
+		id    int64
+		order string
+		key   string
+		d     models.Delivery
+	}
+	var deliveries []deliveryRow
+	for rows.Next() {
+		var r deliveryRow
+		if err := rows.Scan(&r.id, &r.order, &r.key, &r.d.Name, &r.d.Phone, &r.d.Email, &r.d.Address); err != nil {
+			rows.Close()
+			return "", st, err
+		}
+		deliveries = append(deliveries, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", st, err
+	}
+	for _, r := range deliveries {
+		if !s.stale(r.key, r.d.Name, r.d.Phone, r.d.Email, r.d.Address) {
+			continue
+		}
+		st.Deliveries++
+		if dryRun {
+			continue
+		}
+		if err := s.openDelivery(r.order, r.key, &r.d); err != nil {
+			return "", st, err
+		}
+		sealed, err := s.sealDelivery(r.order, r.d)
+		if err != nil {
+			return "", st, fmt.Errorf("order %s: %w", r.order, err)
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE deliveries SET name=$2, phone=$3, email=$4, address=$5, pii_key=$6 WHERE id=$1`,
+			r.id, sealed.Name, sealed.Phone, sealed.Email, sealed.Address, s.sealedWith())
+		if err != nil {
+			return "", st, fmt.Errorf("order %s: delivery: %w", r.order, err)
+		}
+	}
+
+	if dryRun {
+		return orders[len(orders)-1].id, st, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return "", st, fmt.Errorf("commit: %w", err)
+	}
+	return orders[len(orders)-1].id, st, nil
+}
+
+// ReencryptOutboxBatch перешифровывает основным ключом до limit записей outbox с id больше after,
+// записанных открытыми или другим ключом (и ждущих публикации, и хранимых до очистки).
+// Возвращает последний просмотренный id (0 — записей больше нет) и число переписанных.
+func (s *SQLStore) ReencryptOutboxBatch(ctx context.Context, after int64, limit int, dryRun bool) (int64, int, error) {
+	return s.reencryptRows(ctx, "outbox", "order_uid", after, limit, dryRun, s.openPayload,
+		func(id string, raw json.RawMessage) (json.RawMessage, error) {
+			sealed, _, err := s.sealPayload(id, raw)
+			return sealed, err
+		})
+}
+
+// ReencryptWebhookBatch — то же для webhook_deliveries: payload событий шифруется целиком
+func (s *SQLStore) ReencryptWebhookBatch(ctx context.Context, after int64, limit int, dryRun bool) (int64, int, error) {
+	return s.reencryptRows(ctx, "webhook_deliveries", "event_id", after, limit, dryRun, s.openEvent, s.sealEvent)
+}
+
+// reencryptRows перешифровывает payload строк table с pii_key не основным ключом;
+// owner — колонка, к которой привязан шифртекст (order_uid или event_id)
+func (s *SQLStore) reencryptRows(ctx context.Context, table, owner string, after int64, limit int, dryRun bool,
+	open func(owner, key string, raw json.RawMessage) (json.RawMessage, error),
+	seal func(owner string, raw json.RawMessage) (json.RawMessage, error)) (int64, int, error) {
+	if s.keys == nil {
+		return 0, 0, ErrNoKeyRing
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, `+owner+`, payload, COALESCE(pii_key, '') FROM `+table+`
+		WHERE id > $1 AND pii_key IS DISTINCT FROM $2 ORDER BY id LIMIT $3 FOR UPDATE`,
+		after, s.keys.Primary(), limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	type row struct {
+		id         int64
+		owner, key string
+		payload    []byte
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.owner, &r.payload, &r.key); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+	last := batch[len(batch)-1].id
+	if dryRun {
+		return last, len(batch), nil
+	}
+	for _, r := range batch {
+		payload, err := open(r.owner, r.key, r.payload)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s %d: %w", table, r.id, err)
+		}
+		if payload, err = seal(r.owner, payload); err != nil {
+			return 0, 0, fmt.Errorf("%s %d: %w", table, r.id, err)
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE `+table+` SET payload=$2, pii_key=$3 WHERE id=$1`,
+			r.id, []byte(payload), s.sealedWith())
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s %d: %w", table, r.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return last, len(batch), nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-orders-demo/internal/keyring"
+	"go-orders-demo/internal/models"
+)
+
+func testRing(t *testing.T, primary string) *keyring.KeyRing {
+	t.Helper()
+	kr, err := keyring.New(primary, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	}, bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+// значение клиента, похожее на шифртекст, — просто строка: его не расшифровывают
+func TestClientValueLooksEncrypted(t *testing.T) {
+	const fake = "enc:v1:k1:AAAA"
+	raw := json.RawMessage(`{"order_uid":"a","delivery":{"name":"` + fake + `"}}`)
+
+	plain := &SQLStore{}
+	if got, err := plain.openPayload("a", "", raw); err != nil || string(got) != string(raw) {
+		t.Fatalf("without keyring: %s, %v", got, err)
+	}
+
+	s := (&SQLStore{}).WithKeyRing(testRing(t, "k1"))
+	if got, err := s.openPayload("a", "", raw); err != nil || string(got) != string(raw) {
+		t.Fatalf("unsealed row: %s, %v", got, err)
+	}
+	sealed, _, err := s.sealPayload("a", raw)
+	if err != nil {
+		t.Fatalf("seal client value: %v", err)
+	}
+	if bytes.Contains(sealed, []byte(fake)) {
+		t.Fatalf("client value stored as is: %s", sealed)
+	}
+	got, err := s.openPayload("a", "k1", sealed)
+	if err != nil || !sameJSON(got, raw) {
+		t.Fatalf("round trip: %s, %v", got, err)
+	}
+
+	d, err := s.sealDelivery("a", models.Delivery{Name: fake, City: "Moscow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.openDelivery("a", "k1", &d); err != nil || d.Name != fake || d.City != "Moscow" {
+		t.Fatalf("delivery round trip: %+v, %v", d, err)
+	}
+	d = models.Delivery{Name: fake}
+	if err := plain.openDelivery("a", "", &d); err != nil || d.Name != fake {
+		t.Fatalf("unsealed delivery: %+v, %v", d, err)
+	}
+	if err := plain.openDelivery("a", "k1", &d); !errors.Is(err, ErrNoKeyRing) {
+		t.Fatalf("sealed delivery without keyring: %v", err)
+	}
+}
+
+func TestSealEvent(t *testing.T) {
+	s := (&SQLStore{}).WithKeyRing(testRing(t, "k1"))
+	raw := json.RawMessage(`{"event_id":"e1","type":"OrderCreated","order_uid":"a","payload":{"delivery":{"phone":"+79991234567"}}}`)
+	sealed, err := s.sealEvent("e1", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "7999") || !strings.Contains(string(sealed), `"order_uid":"a"`) {
+		t.Fatalf("sealed event: %s", sealed)
+	}
+	if _, err := s.openEvent("e2", "k1", sealed); err == nil {
+		t.Fatal("payload moved to another event must not open")
+	}
+	got, err := s.openEvent("e1", "k1", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameJSON(got, raw) {
+		t.Fatalf("round trip: %s", got)
+	}
+}
+
+// sameJSON сравнивает документы без учёта порядка ключей
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return string(ja) == string(jb)
+}
+
+func TestReencryptOutboxAndWebhooks(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	order := json.RawMessage(`{"order_uid":"a","delivery":{"phone":"+79991234567"}}`)
+	if err := s.EnqueueOutbox(ctx, "a", order); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWebhook(ctx, Webhook{URL: "https://example.com/hook", Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	event := json.RawMessage(`{"event_id":"e1","order_uid":"a","payload":` + string(order) + `}`)
+	if _, err := s.EnqueueWebhookDeliveries(ctx, "e1", "OrderCreated", event); err != nil {
+		t.Fatal(err)
+	}
+
+	// включили шифрование, потом сменили основной ключ: reencrypt доходит до обеих таблиц
+	for _, primary := range []string{"k1", "k2"} {
+		s.WithKeyRing(testRing(t, primary))
+		for _, batch := range []func(context.Context, int64, int, bool) (int64, int, error){
+			s.ReencryptOutboxBatch, s.ReencryptWebhookBatch,
+		} {
+			if _, n, err := batch(ctx, 0, 10, false); err != nil || n != 1 {
+				t.Fatalf("%s: rewritten %d, %v", primary, n, err)
+			}
+			if last, n, err := batch(ctx, 0, 10, false); err != nil || n != 0 || last != 0 {
+				t.Fatalf("%s: second pass rewrote %d, %v", primary, n, err)
+			}
+		}
+		for _, table := range []string{"outbox", "webhook_deliveries"} {
+			var raw, key string
+			if err := s.db.QueryRow(`SELECT payload::text, pii_key FROM `+table).Scan(&raw, &key); err != nil {
+				t.Fatal(err)
+			}
+			if key != primary || strings.Contains(raw, "7999") {
+				t.Fatalf("%s under %s: pii_key=%s payload=%s", table, primary, key, raw)
+			}
+		}
+	}
+
+	ds, err := s.ClaimWebhookDeliveries(ctx, 10, 0)
+	if err != nil || len(ds) != 1 || !strings.Contains(string(ds[0].Payload), "+79991234567") {
+		t.Fatalf("claim: %v %v", ds, err)
+	}
+	var got []string
+	if _, err := s.ProcessOutbox(ctx, 10, func(recs []OutboxRecord) []OutboxResult {
+		for _, r := range recs {
+			got = append(got, string(r.Payload))
+		}
+		return nil
+	}); err != nil || len(got) != 1 || !strings.Contains(got[0], "+79991234567") {
+		t.Fatalf("outbox: %v %v", got, err)
+	}
+}
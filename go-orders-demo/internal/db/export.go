@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// exportFetchSize — сколько строк за один FETCH из серверного курсора
+const exportFetchSize = 500
+
+// ExportTx — read-only снимок БД для выгрузки: все Scan внутри него видят одни и те же данные
+type ExportTx struct {
+	tx      *sql.Tx
+	store   *SQLStore
+	cursors int
+}
+
+// BeginExport открывает снимок (REPEATABLE READ, только чтение); закрывать через Close
+func (s *SQLStore) BeginExport(ctx context.Context) (*ExportTx, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &ExportTx{tx: tx, store: s}, nil
+}
+
+// Scan проходит по заказам фильтра (по возрастанию updated_at) серверным курсором,
+// поэтому в памяти одновременно не больше exportFetchSize строк. Limit и After не используются.
+func (e *ExportTx) Scan(ctx context.Context, f ListFilter, fn func(raw json.RawMessage) error) error {
+	f.After = nil
+	where, args := f.where(e.store.keys)
+	e.cursors++
+	name := fmt.Sprintf("export_%d", e.cursors)
+	q := fmt.Sprintf(`DECLARE %s NO SCROLL CURSOR FOR
+		SELECT order_uid, payload, COALESCE(pii_key, '') FROM orders %s ORDER BY updated_at, order_uid`, name, where)
+	if _, err := e.tx.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("declare cursor: %w", err)
+	}
+	defer e.tx.ExecContext(context.Background(), "CLOSE "+name)
+
+	fetch := fmt.Sprintf("FETCH %d FROM %s", exportFetchSize, name)
+	for {
+		n, err := e.fetch(ctx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+func (e *ExportTx) fetch(ctx context.Context, q string, fn func(json.RawMessage) error) (int, error) {
+	rows, err := e.tx.QueryContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, key string
+		var raw []byte
+		if err := rows.Scan(&id, &raw, &key); err != nil {
+			return n, err
+		}
+		n++
+		order, err := e.store.openPayload(id, key, raw)
+		if err != nil {
+			return n, err
+		}
+		if err := fn(order); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
+
+// Close завершает снимок; транзакция только читала, поэтому откатывается
+func (e *ExportTx) Close() error { return e.tx.Rollback() }
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"go-orders-demo/internal/models"
+)
+
+// Режимы EraseCustomer
+const (
+	// EraseAnonymize очищает персональные данные получателя, заказы остаются
+	EraseAnonymize = "anonymize"
+	// EraseDelete удаляет заказы целиком (с доставками, оплатами и позициями)
+	EraseDelete = "delete"
+)
+
+// erasedFields — поля delivery, которые очищает EraseAnonymize; город и регион остаются для статистики
+var erasedFields = []string{"name", "phone", "email", "address", "zip"}
+
+// customerCond — заказы покупателя: customer_id есть в payload, а у заказов из SaveOrder — и в колонке
+const customerCond = `(payload->>'customer_id' = $1 OR customer_id = $1)`
+
+// CustomerDelivery — строка deliveries с заказом
+type CustomerDelivery struct {
+	OrderUID string `json:"order_uid"`
+	models.Delivery
+}
+
+// CustomerBundle — все данные покупателя: заказы (payload) и строки доставок
+type CustomerBundle struct {
+	CustomerID string             `json:"customer_id"`
+	Orders     []json.RawMessage  `json:"orders"`
+	Deliveries []CustomerDelivery `json:"deliveries"`
+}
+
+// CustomerBundle собирает заказы и доставки покупателя (расшифрованными)
+func (s *SQLStore) CustomerBundle(ctx context.Context, customerID string) (CustomerBundle, error) {
+	b := CustomerBundle{CustomerID: customerID, Orders: []json.RawMessage{}, Deliveries: []CustomerDelivery{}}
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return b, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT order_uid, payload, COALESCE(pii_key, '') FROM orders WHERE `+customerCond+` ORDER BY order_uid`, customerID)
+	if err != nil {
+		return b, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id, key string
+		var raw []byte
+		if err := rows.Scan(&id, &raw, &key); err != nil {
+			rows.Close()
+			return b, err
+		}
+		ids = append(ids, id)
+		if raw == nil {
+			continue
+		}
+		order, err := s.openPayload(id, key, raw)
+		if err != nil {
+			rows.Close()
+			return b, err
+		}
+		b.Orders = append(b.Orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return b, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT order_uid, COALESCE(pii_key, ''), COALESCE(name, ''), COALESCE(phone, ''), COALESCE(zip, ''),
+		COALESCE(city, ''), COALESCE(address, ''), COALESCE(region, ''), COALESCE(email, '')
+		FROM deliveries WHERE order_uid = ANY($1) ORDER BY order_uid, id`, pq.Array(ids))
+	if err != nil {
+		return b, fmt.Errorf("deliveries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d CustomerDelivery
+		var key string
+		if err := rows.Scan(&d.OrderUID, &key, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email); err != nil {
+			return b, err
+		}
+		if err := s.openDelivery(d.OrderUID, key, &d.Delivery); err != nil {
+			return b, err
+		}
+		b.Deliveries = append(b.Deliveries, d)
+	}
+	return b, rows.Err()
+}
+
+// EraseCustomer удаляет (EraseDelete) или обезличивает (EraseAnonymize) заказы покупателя
+// в orders, deliveries и outbox; доставки webhooks по этим заказам удаляются в обоих режимах.
+// Таблицы ревизий заказов нет, других копий данных в БД не остаётся.
+// beforeCommit вызывается с order_uid затронутых заказов до фиксации: если он вернул ошибку,
+// ничего не меняется. Возвращает затронутые order_uid (пусто — заказов нет).
+func (s *SQLStore) EraseCustomer(ctx context.Context, customerID, mode string, beforeCommit func(ids []string) error) ([]string, error) {
+	if mode != EraseAnonymize && mode != EraseDelete {
+		return nil, fmt.Errorf("unknown erase mode %q", mode)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT order_uid, payload FROM orders WHERE `+customerCond+` ORDER BY order_uid FOR UPDATE`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	payloads := map[string][]byte{}
+	var ids []string
+	for rows.Next() {
+		var id string
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		payloads[id] = raw
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	arr := pq.Array(ids)
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE payload->>'order_uid' = ANY($1)`, arr); err != nil {
+		return nil, fmt.Errorf("webhook deliveries: %w", err)
+	}
+	if mode == EraseDelete {
+		// deliveries, payments и items удаляются каскадом
+		if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE order_uid = ANY($1)`, arr); err != nil {
+			return nil, fmt.Errorf("outbox: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE order_uid = ANY($1)`, arr); err != nil {
+			return nil, fmt.Errorf("orders: %w", err)
+		}
+	} else {
+		for _, id := range ids {
+			if payloads[id] == nil {
+				continue
+			}
+			payload, err := anonymize(payloads[id])
+			if err != nil {
+				return nil, fmt.Errorf("order %s: %w", id, err)
+			}
+			_, err = tx.ExecContext(ctx, `UPDATE orders SET payload=$2, phone_bidx=NULL, email_bidx=NULL, updated_at=now() WHERE order_uid=$1`, id, payload)
+			if err != nil {
+				return nil, fmt.Errorf("order %s: %w", id, err)
+			}
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE deliveries SET name='', phone='', email='', address='', zip='' WHERE order_uid = ANY($1)`, arr)
+		if err != nil {
+			return nil, fmt.Errorf("deliveries: %w", err)
+		}
+		if err := anonymizeOutbox(ctx, tx, arr); err != nil {
+			return nil, fmt.Errorf("outbox: %w", err)
+		}
+	}
+
+	if beforeCommit != nil {
+		if err := beforeCommit(ids); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ids, nil
+}
+
+// anonymize очищает erasedFields в payload; зашифрованные значения очищаются без расшифровки
+func anonymize(raw json.RawMessage) (json.RawMessage, error) {
+	return mapDelivery(raw, erasedFields, func(string, string) (string, error) { return "", nil })
+}
+
+// anonymizeOutbox обезличивает записи outbox заказов (и ещё не отправленные, и хранимые до очистки)
+func anonymizeOutbox(ctx context.Context, tx *sql.Tx, ids any) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, payload FROM outbox WHERE order_uid = ANY($1) FOR UPDATE`, ids)
+	if err != nil {
+		return err
+	}
+	payloads := map[int64][]byte{}
+	for rows.Next() {
+		var id int64
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		payloads[id] = raw
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, raw := range payloads {
+		payload, err := anonymize(raw)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE outbox SET payload=$2 WHERE id=$1`, id, []byte(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/keyring"
+)
+
+// ListFilter — условия выборки заказов; пустые поля не ограничивают выборку
+type ListFilter struct {
+	CustomerID      string
+	DeliveryService string
+	// Phone, Email — точное совпадение после нормализации (телефон — только цифры,
+	// e-mail — без учёта регистра); при шифровании ищется по blind index
+	Phone string
+	Email string
+	// CreatedFrom/CreatedTo — полуинтервал [from, to) по date_created
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Limit       int
+	// After — продолжить после этой позиции (из предыдущей страницы)
+	After *Cursor
+}
+
+// Cursor — позиция в списке, отсортированном по (updated_at, order_uid) по убыванию
+type Cursor struct {
+	UpdatedAt time.Time
+	OrderUID  string
+}
+
+// Encode превращает курсор в непрозрачный токен страницы
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.UpdatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.OrderUID))
+}
+
+// DecodeCursor разбирает токен, полученный из Encode
+func DecodeCursor(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, errors.New("invalid page token")
+	}
+	ts, uid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, errors.New("invalid page token")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return Cursor{}, errors.New("invalid page token")
+	}
+	return Cursor{UpdatedAt: t, OrderUID: uid}, nil
+}
+
+// where собирает условие WHERE и аргументы для фильтра; keys — для поиска по blind index
+func (f ListFilter) where(keys *keyring.KeyRing) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, vals ...any) {
+		for _, v := range vals {
+			args = append(args, v)
+			cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conds = append(conds, cond)
+	}
+	if f.CustomerID != "" {
+		add("payload->>'customer_id' = ?", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("payload->>'delivery_service' = ?", f.DeliveryService)
+	}
+	if f.Phone != "" {
+		if keys != nil {
+			add("phone_bidx = ?", keys.BlindIndex(keyring.IndexPhone, f.Phone))
+		} else {
+			add(`regexp_replace(payload->'delivery'->>'phone', '\D', '', 'g') = ?`, keyring.Normalize(keyring.IndexPhone, f.Phone))
+		}
+	}
+	if f.Email != "" {
+		if keys != nil {
+			add("email_bidx = ?", keys.BlindIndex(keyring.IndexEmail, f.Email))
+		} else {
+			add("lower(payload->'delivery'->>'email') = ?", keyring.Normalize(keyring.IndexEmail, f.Email))
+		}
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("(payload->>'date_created')::timestamptz >= ?", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("(payload->>'date_created')::timestamptz < ?", f.CreatedTo)
+	}
+	if f.After != nil {
+		add("(updated_at, order_uid) < (?, ?)", f.After.UpdatedAt, f.After.OrderUID)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ListOrders возвращает страницу заказов (новые первыми) и курсор следующей страницы
+// (nil, если страница последняя)
+func (s *SQLStore) ListOrders(ctx context.Context, f ListFilter) ([]json.RawMessage, *Cursor, error) {
+	where, args := f.where(s.keys)
+	args = append(args, f.Limit+1)
+	q := fmt.Sprintf(`SELECT order_uid, payload, COALESCE(pii_key, ''), updated_at FROM orders %s
+		ORDER BY updated_at DESC, order_uid DESC LIMIT $%d`, where, len(args))
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var res []json.RawMessage
+	var last Cursor
+	var next *Cursor
+	for rows.Next() {
+		if len(res) == f.Limit {
+			c := last
+			next = &c
+			break
+		}
+		var raw []byte
+		var key string
+		if err := rows.Scan(&last.OrderUID, &raw, &key, &last.UpdatedAt); err != nil {
+			return nil, nil, err
+		}
+		order, err := s.openPayload(last.OrderUID, key, raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, order)
+	}
+	return res, next, rows.Err()
+}
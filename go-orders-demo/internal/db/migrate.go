@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// migrationLock — ключ pg_advisory_xact_lock: два migrate не применят одну миграцию дважды
+const migrationLock = 7_000_001
+
+// Migration — файл миграции и его отметка в schema_migrations
+type Migration struct {
+	Version   string     `json:"version"` // имя файла без .sql
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrations — все миграции из fsys (*.sql по порядку имён) с временем применения
+func (s *SQLStore) Migrations(ctx context.Context, fsys fs.FS) ([]Migration, error) {
+	if err := s.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	applied := map[string]time.Time{}
+	rows, err := s.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v string
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res := make([]Migration, 0, len(names))
+	for _, name := range names {
+		m := Migration{Version: strings.TrimSuffix(path.Base(name), ".sql")}
+		if at, ok := applied[m.Version]; ok {
+			m.AppliedAt = &at
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// Migrate применяет неприменённые миграции по порядку: каждую в своей транзакции
+// вместе с записью в schema_migrations. Возвращает применённые сейчас.
+func (s *SQLStore) Migrate(ctx context.Context, fsys fs.FS) ([]Migration, error) {
+	all, err := s.Migrations(ctx, fsys)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, m := range all {
+		if m.AppliedAt != nil {
+			continue
+		}
+		script, err := fs.ReadFile(fsys, m.Version+".sql")
+		if err != nil {
+			return done, err
+		}
+		applied, err := s.applyMigration(ctx, m.Version, string(script))
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		if applied != nil {
+			m.AppliedAt = applied
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// applyMigration выполняет script; nil без ошибки — миграцию уже применил параллельный migrate
+func (s *SQLStore) applyMigration(ctx context.Context, version, script string) (*time.Time, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version=$1)`, version).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return nil, err
+	}
+	var at time.Time
+	if err := tx.QueryRowContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1) RETURNING applied_at`, version).Scan(&at); err != nil {
+		return nil, err
+	}
+	return &at, tx.Commit()
+}
+
+func (s *SQLStore) ensureMigrationsTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	return err
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// outboxLockKey — ключ advisory-лока, чтобы релей работал в одном экземпляре
+const outboxLockKey = 727001
+
+// OutboxRecord — заказ, принятый через /ingest и ожидающий публикации в Kafka
+type OutboxRecord struct {
+	ID            int64
+	OrderUID      string
+	Payload       json.RawMessage
+	Attempts      int
+	NextAttemptAt time.Time
+}
+
+// OutboxResult — итог попытки доставки записи.
+// Err == nil означает успешную публикацию.
+type OutboxResult struct {
+	ID          int64
+	Err         error
+	NextAttempt time.Time
+}
+
+// EnqueueOutbox сохраняет заказ в outbox в отдельной транзакции
+func (s *SQLStore) EnqueueOutbox(ctx context.Context, id string, payload json.RawMessage) error {
+	payload, _, err := s.sealPayload(id, payload)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (order_uid, payload, pii_key) VALUES ($1, $2, $3)`, id, []byte(payload), s.sealedWith()); err != nil {
+		return fmt.Errorf("insert outbox: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// ProcessOutbox берёт до limit недоставленных записей, которые пора публиковать
+// (по возрастанию id), и передаёт их в fn. Записи заказа, чья более ранняя запись
+// ещё ждёт повтора, не берутся: иначе отложенные записи занимали бы всю пачку.
+// Результаты fn фиксируются в той же транзакции. Если лок держит другой экземпляр,
+// возвращает 0 без ошибки.
+func (s *SQLStore) ProcessOutbox(ctx context.Context, limit int, fn func([]OutboxRecord) []OutboxResult) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockKey).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("outbox lock: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT o.id, o.order_uid, o.payload, COALESCE(o.pii_key, ''), o.attempts, o.next_attempt_at
+		FROM outbox o
+		WHERE o.delivered_at IS NULL AND o.next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox p
+				WHERE p.order_uid = o.order_uid AND p.delivered_at IS NULL
+					AND p.id < o.id AND p.next_attempt_at > now()
+			)
+		ORDER BY o.id LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select outbox: %w", err)
+	}
+	var recs []OutboxRecord
+	for rows.Next() {
+		var r OutboxRecord
+		var raw []byte
+		var key string
+		if err := rows.Scan(&r.ID, &r.OrderUID, &raw, &key, &r.Attempts, &r.NextAttemptAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.Payload, err = s.openPayload(r.OrderUID, key, raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		recs = append(recs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	results := fn(recs)
+	delivered := 0
+	for _, res := range results {
+		if res.Err == nil {
+			delivered++
+			_, err = tx.ExecContext(ctx, `UPDATE outbox SET delivered_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`, res.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+				WHERE id = $1
+			`, res.ID, res.Err.Error(), res.NextAttempt)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("update outbox: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return delivered, nil
+}
+
+// CleanupOutbox удаляет доставленные записи старше before
+func (s *SQLStore) CleanupOutbox(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE delivered_at IS NOT NULL AND delivered_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProcessOutboxSkipsBackedOffRows(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	const limit = 5
+
+	// отложенных записей больше, чем влезает в пачку, и у них меньшие id
+	for i := 0; i < 2*limit; i++ {
+		id := fmt.Sprintf("slow-%d", i)
+		if err := s.EnqueueOutbox(ctx, id, json.RawMessage(`{"order_uid":"`+id+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.db.Exec(`UPDATE outbox SET attempts = 3, next_attempt_at = now() + interval '1 hour'`); err != nil {
+		t.Fatal(err)
+	}
+	// следующая запись отложенного заказа ждёт его головную запись
+	if err := s.EnqueueOutbox(ctx, "slow-0", json.RawMessage(`{"order_uid":"slow-0"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnqueueOutbox(ctx, "fresh", json.RawMessage(`{"order_uid":"fresh"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	n, err := s.ProcessOutbox(ctx, limit, func(recs []OutboxRecord) []OutboxResult {
+		var res []OutboxResult
+		for _, r := range recs {
+			got = append(got, r.OrderUID)
+			res = append(res, OutboxResult{ID: r.ID})
+		}
+		return res
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(got) != 1 || got[0] != "fresh" {
+		t.Fatalf("delivered %d, batch %v; want only [fresh]", n, got)
+	}
+
+	// после неудачи запись уходит из выборки до next_attempt_at
+	if err := s.EnqueueOutbox(ctx, "retry", json.RawMessage(`{"order_uid":"retry"}`)); err != nil {
+		t.Fatal(err)
+	}
+	fail := func(recs []OutboxRecord) []OutboxResult {
+		var res []OutboxResult
+		for _, r := range recs {
+			res = append(res, OutboxResult{ID: r.ID, Err: errors.New("broker down"), NextAttempt: time.Now().Add(time.Hour)})
+		}
+		return res
+	}
+	if _, err := s.ProcessOutbox(ctx, limit, fail); err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	if _, err := s.ProcessOutbox(ctx, limit, func(recs []OutboxRecord) []OutboxResult {
+		called = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Fatal("backed-off row selected again before next_attempt_at")
+	}
+}
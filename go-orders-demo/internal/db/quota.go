@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// AddIngestUsage атомарно прибавляет n к расходу клиента за день, если итог не превышает limit.
+// ok=false — квота исчерпана и расход не изменился; used — расход после операции
+// (при отказе — текущий).
+func (s *SQLStore) AddIngestUsage(ctx context.Context, client string, day time.Time, n, limit int64) (used int64, ok bool, err error) {
+	d := day.Format(time.DateOnly)
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO ingest_quota_usage AS q (client, day, used)
+		SELECT $1, $2, $3 WHERE $3 <= $4
+		ON CONFLICT (client, day) DO UPDATE SET used = q.used + EXCLUDED.used, updated_at = now()
+		WHERE q.used + EXCLUDED.used <= $4
+		RETURNING used
+	`, client, d, n, limit).Scan(&used)
+	if err == nil {
+		return used, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+	err = s.db.QueryRowContext(ctx, `SELECT used FROM ingest_quota_usage WHERE client = $1 AND day = $2`, client, d).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return used, false, err
+}
+
+// ReleaseIngestUsage возвращает n заказов в квоту клиента за день (расход не уходит ниже нуля)
+func (s *SQLStore) ReleaseIngestUsage(ctx context.Context, client string, day time.Time, n int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE ingest_quota_usage SET used = GREATEST(used - $3, 0), updated_at = now()
+		WHERE client = $1 AND day = $2
+	`, client, day.Format(time.DateOnly), n)
+	return err
+}
@@ -3,14 +3,25 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"time"
+
 	"go-orders-demo/internal/models"
 )
 
+// RawOrder — orders.payload и orders.updated_at
+type RawOrder struct {
+	Raw      json.RawMessage
+	Modified time.Time
+}
+
 type Repository interface {
 	// методы для кэша / API
-	SaveRaw(ctx context.Context, id string, raw json.RawMessage) error
+	// SaveRaw возвращает orders.updated_at сохранённой версии
+	SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error)
 	GetRaw(ctx context.Context, id string) (json.RawMessage, error)
-	LoadAllRaw(ctx context.Context, limit int) (map[string]json.RawMessage, error)
+	// GetRawModified — GetRaw вместе с orders.updated_at (для Last-Modified)
+	GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error)
+	LoadAllRaw(ctx context.Context, limit int) (map[string]RawOrder, error)
 
 	// новый нормализованный метод
 	SaveOrder(ctx context.Context, o models.Order) error
@@ -9,13 +9,15 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+	"go-orders-demo/internal/keyring"
 	"go-orders-demo/internal/models"
 )
 
 var ErrNotFound = errors.New("not found")
 
 type SQLStore struct {
-	db *sql.DB
+	db   *sql.DB
+	keys *keyring.KeyRing // nil — персональные данные хранятся открыто
 }
 
 func NewSQLStore(connStr string) (*SQLStore, error) {
@@ -30,37 +32,68 @@ func NewSQLStore(connStr string) (*SQLStore, error) {
 }
 
 // SaveRaw - сохраняет целый JSON в orders.payload (совместимость)
-func (s *SQLStore) SaveRaw(ctx context.Context, id string, raw json.RawMessage) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO orders(order_uid, payload, updated_at) VALUES ($1, $2, now())
-		ON CONFLICT (order_uid) DO UPDATE SET payload = EXCLUDED.payload, updated_at = now()
-	`, id, raw)
-	return err
+func (s *SQLStore) SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error) {
+	payload, idx, err := s.sealPayload(id, raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var modified time.Time
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO orders(order_uid, payload, pii_key, phone_bidx, email_bidx, updated_at) VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (order_uid) DO UPDATE SET payload = EXCLUDED.payload, pii_key = EXCLUDED.pii_key,
+			phone_bidx = EXCLUDED.phone_bidx, email_bidx = EXCLUDED.email_bidx, updated_at = now()
+		RETURNING updated_at
+	`, id, payload, s.sealedWith(), idx.phone, idx.email).Scan(&modified)
+	return modified, err
 }
 
 func (s *SQLStore) GetRaw(ctx context.Context, id string) (json.RawMessage, error) {
 	var raw []byte
-	err := s.db.QueryRowContext(ctx, `SELECT payload FROM orders WHERE order_uid=$1`, id).Scan(&raw)
+	var key string
+	err := s.db.QueryRowContext(ctx, `SELECT payload, COALESCE(pii_key, '') FROM orders WHERE order_uid=$1`, id).Scan(&raw, &key)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
-	return json.RawMessage(raw), err
+	if err != nil {
+		return nil, err
+	}
+	return s.openPayload(id, key, raw)
+}
+
+func (s *SQLStore) GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error) {
+	var raw []byte
+	var key string
+	var modified time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT payload, COALESCE(pii_key, ''), updated_at FROM orders WHERE order_uid=$1`, id).Scan(&raw, &key, &modified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	res, err := s.openPayload(id, key, raw)
+	return res, modified, err
 }
 
-func (s *SQLStore) LoadAllRaw(ctx context.Context, limit int) (map[string]json.RawMessage, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT order_uid, payload FROM orders ORDER BY updated_at DESC LIMIT $1`, limit)
+// LoadAllRaw — до limit последних изменённых заказов со временем изменения
+func (s *SQLStore) LoadAllRaw(ctx context.Context, limit int) (map[string]RawOrder, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT order_uid, payload, COALESCE(pii_key, ''), updated_at FROM orders ORDER BY updated_at DESC LIMIT $1`, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	res := make(map[string]json.RawMessage)
+	res := make(map[string]RawOrder)
 	for rows.Next() {
-		var id string
+		var id, key string
 		var raw []byte
-		if err := rows.Scan(&id, &raw); err != nil {
+		var o RawOrder
+		if err := rows.Scan(&id, &raw, &key, &o.Modified); err != nil {
 			return nil, err
 		}
-		res[id] = json.RawMessage(raw)
+		if o.Raw, err = s.openPayload(id, key, raw); err != nil {
+			return nil, err
+		}
+		res[id] = o
 	}
 	return res, rows.Err()
 }
@@ -76,23 +109,32 @@ func (s *SQLStore) SaveOrder(ctx context.Context, o models.Order) error {
 
 	// insert order row (and payload as fallback)
 	payload, _ := json.Marshal(o)
+	payload, idx, err := s.sealPayload(o.OrderUID, payload)
+	if err != nil {
+		return err
+	}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO orders (order_uid, payload, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12, now())
-		ON CONFLICT (order_uid) DO UPDATE SET payload=EXCLUDED.payload, updated_at=now()
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, phone_bidx, email_bidx, pii_key, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15, now())
+		ON CONFLICT (order_uid) DO UPDATE SET payload=EXCLUDED.payload, pii_key=EXCLUDED.pii_key,
+			phone_bidx=EXCLUDED.phone_bidx, email_bidx=EXCLUDED.email_bidx, updated_at=now()
 	`, o.OrderUID, payload, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
-		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard)
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, idx.phone, idx.email, s.sealedWith())
 	if err != nil {
 		return fmt.Errorf("insert order: %w", err)
 	}
 
+	d, err := s.sealDelivery(o.OrderUID, o.Delivery)
+	if err != nil {
+		return err
+	}
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-		ON CONFLICT (order_uid) DO UPDATE SET name=EXCLUDED.name, phone=EXCLUDED.phone
-	`, o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip,
-		o.Delivery.City, o.Delivery.Address, o.Delivery.Region, o.Delivery.Email)
+		INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email, pii_key)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		ON CONFLICT (order_uid) DO UPDATE SET name=EXCLUDED.name, phone=EXCLUDED.phone,
+			email=EXCLUDED.email, address=EXCLUDED.address, pii_key=EXCLUDED.pii_key
+	`, o.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email, s.sealedWith())
 	if err != nil {
 		return fmt.Errorf("insert delivery: %w", err)
 	}
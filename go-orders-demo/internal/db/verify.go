@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"go-orders-demo/internal/models"
+)
+
+// StoredOrder — заказ в обоих представлениях SaveOrder: orders.payload и нормализованные таблицы
+type StoredOrder struct {
+	OrderUID string
+	Payload  json.RawMessage // nil — payload пуст
+	// Tables — заказ, собранный из колонок orders и строк deliveries/payments/items;
+	// nil, если нормализованных данных нет (заказ сохранён через SaveRaw)
+	Tables *models.Order
+	// Deliveries, Payments — число строк; у заказа должна быть одна доставка и одна оплата
+	Deliveries int
+	Payments   int
+}
+
+// ScanStored возвращает до limit заказов с order_uid больше after (по возрастанию order_uid)
+func (s *SQLStore) ScanStored(ctx context.Context, after string, limit int) ([]StoredOrder, error) {
+	return s.stored(ctx, `order_uid > $1 ORDER BY order_uid LIMIT $2`, after, limit)
+}
+
+// LoadStored возвращает один заказ; ErrNotFound, если его нет
+func (s *SQLStore) LoadStored(ctx context.Context, id string) (StoredOrder, error) {
+	res, err := s.stored(ctx, `order_uid = $1`, id)
+	if err != nil {
+		return StoredOrder{}, err
+	}
+	if len(res) == 0 {
+		return StoredOrder{}, ErrNotFound
+	}
+	return res[0], nil
+}
+
+func (s *SQLStore) stored(ctx context.Context, cond string, args ...any) ([]StoredOrder, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT order_uid, payload, COALESCE(pii_key, ''), track_number IS NOT NULL,
+			COALESCE(track_number, ''), COALESCE(entry, ''), COALESCE(locale, ''),
+			COALESCE(internal_signature, ''), COALESCE(customer_id, ''), COALESCE(delivery_service, ''),
+			COALESCE(shardkey, ''), COALESCE(sm_id, 0), date_created, COALESCE(oof_shard, '')
+		FROM orders WHERE `+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []StoredOrder
+	index := map[string]int{}
+	var ids []string
+	for rows.Next() {
+		var so StoredOrder
+		var payload []byte
+		var key string
+		var hasColumns bool
+		var o models.Order
+		var created sql.NullTime
+		if err := rows.Scan(&so.OrderUID, &payload, &key, &hasColumns,
+			&o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID, &o.DeliveryService,
+			&o.ShardKey, &o.SmID, &created, &o.OofShard); err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			if so.Payload, err = s.openPayload(so.OrderUID, key, payload); err != nil {
+				return nil, err
+			}
+		}
+		if hasColumns {
+			o.OrderUID = so.OrderUID
+			o.DateCreated = created.Time
+			so.Tables = &o
+		}
+		index[so.OrderUID] = len(res)
+		ids = append(ids, so.OrderUID)
+		res = append(res, so)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return res, nil
+	}
+	// связанные строки без колонок orders всё равно считаются нормализованными данными
+	tables := func(id string) *models.Order {
+		so := &res[index[id]]
+		if so.Tables == nil {
+			so.Tables = &models.Order{OrderUID: id}
+		}
+		return so.Tables
+	}
+
+	err = s.related(ctx, `SELECT order_uid, COALESCE(pii_key, ''), COALESCE(name, ''), COALESCE(phone, ''), COALESCE(zip, ''),
+		COALESCE(city, ''), COALESCE(address, ''), COALESCE(region, ''), COALESCE(email, '')
+		FROM deliveries WHERE order_uid = ANY($1) ORDER BY id`, ids, func(rows *sql.Rows) error {
+		var id, key string
+		var d models.Delivery
+		if err := rows.Scan(&id, &key, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email); err != nil {
+			return err
+		}
+		if err := s.openDelivery(id, key, &d); err != nil {
+			return err
+		}
+		// при дублях берём первую строку; дубли сами по себе расхождение
+		if o := tables(id); res[index[id]].Deliveries == 0 {
+			o.Delivery = d
+		}
+		res[index[id]].Deliveries++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deliveries: %w", err)
+	}
+
+	err = s.related(ctx, `SELECT order_uid, COALESCE(transaction, ''), COALESCE(request_id, ''),
+		COALESCE(currency, ''), COALESCE(provider, ''), COALESCE(amount, 0), COALESCE(payment_dt, 0),
+		COALESCE(bank, ''), COALESCE(delivery_cost, 0), COALESCE(goods_total, 0), COALESCE(custom_fee, 0)
+		FROM payments WHERE order_uid = ANY($1) ORDER BY id`, ids, func(rows *sql.Rows) error {
+		var id string
+		var p models.Payment
+		if err := rows.Scan(&id, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount,
+			&p.PaymentDT, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee); err != nil {
+			return err
+		}
+		if o := tables(id); res[index[id]].Payments == 0 {
+			o.Payment = p
+		}
+		res[index[id]].Payments++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payments: %w", err)
+	}
+
+	err = s.related(ctx, `SELECT order_uid, COALESCE(chrt_id, 0), COALESCE(track_number, ''), COALESCE(price, 0),
+		COALESCE(rid, ''), COALESCE(name, ''), COALESCE(sale, 0), COALESCE(size, ''), COALESCE(total_price, 0),
+		COALESCE(nm_id, 0), COALESCE(brand, ''), COALESCE(status, 0)
+		FROM items WHERE order_uid = ANY($1) ORDER BY id`, ids, func(rows *sql.Rows) error {
+		var id string
+		var it models.Item
+		if err := rows.Scan(&id, &it.ChrtID, &it.TrackNumber, &it.Price, &it.Rid, &it.Name, &it.Sale,
+			&it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status); err != nil {
+			return err
+		}
+		o := tables(id)
+		o.Items = append(o.Items, it)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+	return res, nil
+}
+
+func (s *SQLStore) related(ctx context.Context, q string, ids []string, fn func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, q, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RewriteTables заново записывает нормализованное представление заказа из o
+// (все колонки, а не только те, что обновляет ON CONFLICT в SaveOrder); payload не меняется
+func (s *SQLStore) RewriteTables(ctx context.Context, o models.Order) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE orders SET track_number=$2, entry=$3, locale=$4, internal_signature=$5, customer_id=$6,
+			delivery_service=$7, shardkey=$8, sm_id=$9, date_created=$10, oof_shard=$11, updated_at=now()
+		WHERE order_uid=$1
+	`, o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard)
+	if err != nil {
+		return fmt.Errorf("update order: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid=$1`, o.OrderUID); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+
+	d, err := s.sealDelivery(o.OrderUID, o.Delivery)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email, pii_key)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+	`, o.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email, s.sealedWith())
+	if err != nil {
+		return fmt.Errorf("insert delivery: %w", err)
+	}
+
+	// transaction и chrt_id уникальны во всей таблице. Строки заказа уже удалены,
+	// значит конфликт — со строкой другого заказа: её не трогаем, восстановление падает
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO payments (transaction, order_uid, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		ON CONFLICT (transaction) DO NOTHING
+	`, o.Payment.Transaction, o.OrderUID, o.Payment.RequestID, o.Payment.Currency,
+		o.Payment.Provider, o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank,
+		o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee)
+	if err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return conflict(ctx, tx, `SELECT order_uid FROM payments WHERE transaction=$1`, o.OrderUID,
+			fmt.Sprintf("payment transaction %q", o.Payment.Transaction), o.Payment.Transaction)
+	}
+
+	for _, it := range o.Items {
+		res, err = tx.ExecContext(ctx, `
+			INSERT INTO items (chrt_id, order_uid, track_number, price, rid, name,
+				sale, size, total_price, nm_id, brand, status)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+			ON CONFLICT (chrt_id) DO NOTHING
+		`, it.ChrtID, o.OrderUID, it.TrackNumber, it.Price, it.Rid,
+			it.Name, it.Sale, it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status)
+		if err != nil {
+			return fmt.Errorf("insert item: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return conflict(ctx, tx, `SELECT order_uid FROM items WHERE chrt_id=$1`, o.OrderUID,
+				fmt.Sprintf("item chrt_id %d", it.ChrtID), it.ChrtID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// ErrConflict — уникальное значение заказа (transaction, chrt_id) уже занято другим заказом
+var ErrConflict = errors.New("conflict")
+
+// conflict — ошибка RewriteTables со ссылкой на заказ, которому принадлежит строка
+func conflict(ctx context.Context, tx *sql.Tx, q, id, what string, key any) error {
+	var owner string
+	if err := tx.QueryRowContext(ctx, q, key).Scan(&owner); err != nil {
+		return fmt.Errorf("%s: %w (owner lookup: %v)", what, ErrConflict, err)
+	}
+	if owner == id {
+		return fmt.Errorf("%s appears twice in the order: %w", what, ErrConflict)
+	}
+	return fmt.Errorf("%s belongs to order %s: %w", what, owner, ErrConflict)
+}
+
+// ReplacePayload перезаписывает orders.payload; ErrNotFound, если заказа нет
+func (s *SQLStore) ReplacePayload(ctx context.Context, id string, payload json.RawMessage) error {
+	payload, idx, err := s.sealPayload(id, payload)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE orders SET payload=$2, pii_key=$3, phone_bidx=$4, email_bidx=$5, updated_at=now() WHERE order_uid=$1`,
+		id, payload, s.sealedWith(), idx.phone, idx.email)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-orders-demo/internal/models"
+)
+
+func TestRewriteTablesConflict(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	order := func(id, tx string, chrt int) models.Order {
+		return models.Order{
+			OrderUID: id, TrackNumber: "T",
+			Delivery: models.Delivery{Name: "N", Address: "A"},
+			Payment:  models.Payment{Transaction: tx},
+			Items:    []models.Item{{ChrtID: chrt, Name: id}},
+		}
+	}
+	for _, o := range []models.Order{order("a", "ta", 1), order("b", "tb", 2)} {
+		if err := s.SaveOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, o := range map[string]models.Order{
+		"item":    order("b", "tb", 1),
+		"payment": order("b", "ta", 2),
+	} {
+		err := s.RewriteTables(ctx, o)
+		if !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "belongs to order a") {
+			t.Fatalf("%s: err = %v, want conflict with order a", name, err)
+		}
+	}
+	dup := order("b", "tb", 2)
+	dup.Items = append(dup.Items, dup.Items[0])
+	if err := s.RewriteTables(ctx, dup); !errors.Is(err, ErrConflict) {
+		t.Fatalf("duplicate chrt_id: err = %v", err)
+	}
+
+	// строки обоих заказов остались на месте
+	for _, id := range []string{"a", "b"} {
+		so, err := s.LoadStored(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if so.Tables == nil || len(so.Tables.Items) != 1 || so.Tables.Items[0].Name != id || so.Payments != 1 {
+			t.Fatalf("order %s changed: %+v", id, so)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook — подписка партнёра на события заказов
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Статусы доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery — одна доставка события на webhook
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"-"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"` // nil у доставленных и failed
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func (s *SQLStore) CreateWebhook(ctx context.Context, w Webhook) (Webhook, error) {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, events, secret) VALUES ($1, $2, $3)
+		RETURNING id, active, created_at
+	`, w.URL, pq.Array(w.Events), w.Secret).Scan(&w.ID, &w.Active, &w.CreatedAt)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("insert webhook: %w", err)
+	}
+	return w, nil
+}
+
+func (s *SQLStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, url, events, secret, active, created_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []Webhook
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Secret, &w.Active, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+func (s *SQLStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var w Webhook
+	err := s.db.QueryRowContext(ctx, `SELECT id, url, events, secret, active, created_at FROM webhooks WHERE id=$1`, id).
+		Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Secret, &w.Active, &w.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrNotFound
+	}
+	return w, err
+}
+
+func (s *SQLStore) DeleteWebhook(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries создаёт доставки события для всех активных подписок,
+// у которых фильтр пуст или содержит тип события
+func (s *SQLStore) EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error) {
+	payload, err := s.sealEvent(eventID, payload)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, pii_key)
+		SELECT id, $1, $2, $3, $4 FROM webhooks
+		WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))
+	`, eventID, eventType, []byte(payload), s.sealedWith())
+	if err != nil {
+		return 0, fmt.Errorf("insert deliveries: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ClaimWebhookDeliveries захватывает до limit доставок, срок которых наступил,
+// и откладывает их на lease, чтобы другие экземпляры не взяли их повторно
+func (s *SQLStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, COALESCE(d.pii_key, ''), d.attempts, d.created_at
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim deliveries: %w", err)
+	}
+	defer rows.Close()
+	var res []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		var raw []byte
+		var key string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &raw, &key, &d.Attempts, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		if d.Payload, err = s.openEvent(d.EventID, key, raw); err != nil {
+			return nil, err
+		}
+		d.Status = DeliveryPending
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// UpdateWebhookDelivery сохраняет результат попытки доставки
+func (s *SQLStore) UpdateWebhookDelivery(ctx context.Context, d WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status=$2, attempts=$3, last_status_code=$4, last_error=$5,
+			next_attempt_at=$6, delivered_at=$7
+		WHERE id=$1
+	`, d.ID, d.Status, d.Attempts, nullInt(d.LastStatusCode), nullString(d.LastError), d.NextAttemptAt, d.DeliveredAt)
+	return err
+}
+
+// ListWebhookDeliveries — журнал доставок подписки, новые первыми
+func (s *SQLStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, `WHERE webhook_id=$1 ORDER BY id DESC LIMIT $2`, webhookID, limit)
+}
+
+// FailedWebhookDeliveries — доставки, исчерпавшие попытки (dead letters), новые первыми;
+// webhookID 0 — всех подписок
+func (s *SQLStore) FailedWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	return s.queryDeliveries(ctx, `WHERE status='failed' AND ($1::bigint=0 OR webhook_id=$1) ORDER BY id DESC LIMIT $2`, webhookID, limit)
+}
+
+// RedriveWebhookDeliveries возвращает failed доставки в очередь с обнулённым счётчиком попыток.
+// Пустой ids — все failed доставки подписки webhookID (0 — всех подписок).
+func (s *SQLStore) RedriveWebhookDeliveries(ctx context.Context, webhookID int64, ids []int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status='pending', attempts=0, next_attempt_at=now()
+		WHERE status='failed' AND ($1::bigint=0 OR webhook_id=$1) AND (cardinality($2::bigint[])=0 OR id=ANY($2))
+	`, webhookID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLStore) queryDeliveries(ctx context.Context, cond string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, status, attempts,
+			COALESCE(last_status_code, 0), COALESCE(last_error, ''), next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries `+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDeliveredWebhookHasNoNextAttempt(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	w, err := s.CreateWebhook(ctx, Webhook{URL: "https://example.com/hook", Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnqueueWebhookDeliveries(ctx, "e1", "OrderCreated", json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := s.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("claim: %v, %d deliveries", err, len(ds))
+	}
+
+	now := time.Now()
+	d := ds[0]
+	d.Status, d.Attempts, d.DeliveredAt, d.NextAttemptAt = DeliveryDelivered, 1, &now, nil
+	if err := s.UpdateWebhookDelivery(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	ds, err = s.ListWebhookDeliveries(ctx, w.ID, 10)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("list: %v, %d deliveries", err, len(ds))
+	}
+	if ds[0].NextAttemptAt != nil || ds[0].DeliveredAt == nil {
+		t.Fatalf("delivered row: next_attempt_at=%v delivered_at=%v", ds[0].NextAttemptAt, ds[0].DeliveredAt)
+	}
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"go-orders-demo/internal/models"
+)
+
+// Type — тип доменного события
+type Type string
+
+const (
+	OrderCreated       Type = "OrderCreated"
+	OrderUpdated       Type = "OrderUpdated"
+	OrderStatusChanged Type = "OrderStatusChanged"
+	OrderCancelled     Type = "OrderCancelled"
+	// OrderErased — персональные данные заказа удалены по запросу покупателя
+	OrderErased Type = "OrderErased"
+)
+
+// Types — все известные типы событий
+var Types = []Type{OrderCreated, OrderUpdated, OrderStatusChanged, OrderCancelled, OrderErased}
+
+// Known сообщает, является ли t известным типом события
+func Known(t string) bool {
+	for _, k := range Types {
+		if string(k) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Version — версия формата конверта и payload
+const Version = 1
+
+// Envelope — стабильный конверт события в топике событий
+type Envelope struct {
+	ID         string          `json:"event_id"`
+	Type       Type            `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Version    int             `json:"version"`
+	OrderUID   string          `json:"order_uid"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// StatusChange — payload события OrderStatusChanged
+type StatusChange struct {
+	OrderUID       string       `json:"order_uid"`
+	PreviousStatus string       `json:"previous_status,omitempty"`
+	Status         string       `json:"status,omitempty"`
+	Items          []ItemStatus `json:"items,omitempty"`
+}
+
+type ItemStatus struct {
+	ChrtID         int `json:"chrt_id"`
+	PreviousStatus int `json:"previous_status"`
+	Status         int `json:"status"`
+}
+
+// Erasure — payload события OrderErased
+type Erasure struct {
+	OrderUID   string `json:"order_uid"`
+	CustomerID string `json:"customer_id"`
+	// Mode — anonymize (персональные данные очищены) или delete (заказ удалён)
+	Mode string `json:"mode"`
+}
+
+// Erased собирает конверт OrderErased
+func Erased(e Erasure, now time.Time) (Envelope, error) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		ID:         newID(),
+		Type:       OrderErased,
+		OccurredAt: now.UTC(),
+		Version:    Version,
+		OrderUID:   e.OrderUID,
+		Payload:    raw,
+	}, nil
+}
+
+// Detect определяет события по предыдущей (nil — заказа не было) и новой версии заказа
+func Detect(prev *models.Order, cur models.Order) []Type {
+	if prev == nil {
+		return []Type{OrderCreated}
+	}
+	types := []Type{OrderUpdated}
+	if statusChange(*prev, cur) != nil {
+		types = append(types, OrderStatusChanged)
+	}
+	if cur.Status == models.StatusCancelled && prev.Status != models.StatusCancelled {
+		types = append(types, OrderCancelled)
+	}
+	return types
+}
+
+// statusChange возвращает изменения статусов заказа и товаров или nil
+func statusChange(prev, cur models.Order) *StatusChange {
+	ch := &StatusChange{OrderUID: cur.OrderUID, PreviousStatus: prev.Status, Status: cur.Status}
+	before := make(map[int]int, len(prev.Items))
+	for _, it := range prev.Items {
+		before[it.ChrtID] = it.Status
+	}
+	for _, it := range cur.Items {
+		if old, ok := before[it.ChrtID]; ok && old != it.Status {
+			ch.Items = append(ch.Items, ItemStatus{ChrtID: it.ChrtID, PreviousStatus: old, Status: it.Status})
+		}
+	}
+	if prev.Status == cur.Status && len(ch.Items) == 0 {
+		return nil
+	}
+	return ch
+}
+
+// Build собирает конверты для всех событий, вызванных изменением заказа
+func Build(prev *models.Order, cur models.Order, now time.Time) ([]Envelope, error) {
+	var res []Envelope
+	for _, t := range Detect(prev, cur) {
+		var payload any = cur
+		if t == OrderStatusChanged {
+			payload = statusChange(*prev, cur)
+		}
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Envelope{
+			ID:         newID(),
+			Type:       t,
+			OccurredAt: now.UTC(),
+			Version:    Version,
+			OrderUID:   cur.OrderUID,
+			Payload:    raw,
+		})
+	}
+	return res, nil
+}
+
+// Producer — публикация в топик событий (реализуется kafka.Producer)
+type Producer interface {
+	ProduceKeyed(ctx context.Context, key string, payload []byte) error
+}
+
+// Publisher превращает сохранённые заказы в события и публикует их
+type Publisher struct {
+	prod Producer
+}
+
+func NewPublisher(prod Producer) *Publisher {
+	return &Publisher{prod: prod}
+}
+
+// OnSave — хук для kafka.Consumer.OnSave. Доставка не более одного раза:
+// событие, которое не удалось опубликовать, только пишется в лог и не повторяется.
+func (p *Publisher) OnSave(ctx context.Context, id string, prev, cur json.RawMessage) {
+	envs, err := FromSave(prev, cur, time.Now())
+	if err != nil {
+		log.Printf("events: %s: %v", id, err)
+		return
+	}
+	for _, e := range envs {
+		if err := p.Publish(ctx, e); err != nil {
+			log.Printf("events: publish %s %s: %v", e.Type, id, err)
+		}
+	}
+}
+
+// FromSave строит события по сырым JSON версиям заказа из kafka.SaveHook.
+// Повторная доставка того же сообщения ничего не меняет и событий не даёт.
+func FromSave(prev, cur json.RawMessage, now time.Time) ([]Envelope, error) {
+	if sameJSON(prev, cur) {
+		return nil, nil
+	}
+	var o models.Order
+	if err := json.Unmarshal(cur, &o); err != nil {
+		return nil, fmt.Errorf("decode order: %w", err)
+	}
+	var before *models.Order
+	if len(prev) > 0 {
+		before = &models.Order{}
+		if err := json.Unmarshal(prev, before); err != nil {
+			log.Printf("events: decode previous %s: %v", o.OrderUID, err)
+			before = nil
+		}
+	}
+	return Build(before, o, now)
+}
+
+// sameJSON сравнивает документы по значению: из JSONB Postgres возвращает
+// заказ с другим порядком ключей и пробелами, чем пришёл в сообщении
+func sameJSON(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// Publish отправляет конверт в топик событий с ключом order_uid
+func (p *Publisher) Publish(ctx context.Context, e Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return p.prod.ProduceKeyed(ctx, e.OrderUID, data)
+}
+
+// newID — случайный UUID v4
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/models"
+)
+
+func TestDetect(t *testing.T) {
+	base := models.Order{OrderUID: "o1", Items: []models.Item{{ChrtID: 1, Status: 202}}}
+
+	if got := Detect(nil, base); len(got) != 1 || got[0] != OrderCreated {
+		t.Fatalf("new order: %v", got)
+	}
+	if got := Detect(&base, base); len(got) != 1 || got[0] != OrderUpdated {
+		t.Fatalf("unchanged order: %v", got)
+	}
+
+	moved := base
+	moved.Items = []models.Item{{ChrtID: 1, Status: 300}}
+	if got := Detect(&base, moved); len(got) != 2 || got[1] != OrderStatusChanged {
+		t.Fatalf("item status change: %v", got)
+	}
+
+	cancelled := base
+	cancelled.Status = models.StatusCancelled
+	got := Detect(&base, cancelled)
+	if len(got) != 3 || got[1] != OrderStatusChanged || got[2] != OrderCancelled {
+		t.Fatalf("cancel: %v", got)
+	}
+}
+
+type captureProducer struct {
+	keys []string
+	msgs [][]byte
+}
+
+func (p *captureProducer) ProduceKeyed(ctx context.Context, key string, payload []byte) error {
+	p.keys = append(p.keys, key)
+	p.msgs = append(p.msgs, payload)
+	return nil
+}
+
+func TestPublisherOnSave(t *testing.T) {
+	prod := &captureProducer{}
+	p := NewPublisher(prod)
+
+	prev := json.RawMessage(`{"order_uid":"o1","items":[{"chrt_id":1,"status":1}]}`)
+	cur := json.RawMessage(`{"order_uid":"o1","items":[{"chrt_id":1,"status":2}]}`)
+	p.OnSave(context.Background(), "o1", prev, cur)
+
+	if len(prod.msgs) != 2 {
+		t.Fatalf("published %d events, want 2", len(prod.msgs))
+	}
+	var e Envelope
+	if err := json.Unmarshal(prod.msgs[1], &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != OrderStatusChanged || e.Version != Version || e.ID == "" || prod.keys[1] != "o1" {
+		t.Fatalf("unexpected envelope: %+v", e)
+	}
+	if time.Since(e.OccurredAt) > time.Minute {
+		t.Fatalf("occurred_at = %v", e.OccurredAt)
+	}
+	var ch StatusChange
+	if err := json.Unmarshal(e.Payload, &ch); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Items) != 1 || ch.Items[0].PreviousStatus != 1 || ch.Items[0].Status != 2 {
+		t.Fatalf("unexpected payload: %+v", ch)
+	}
+}
+
+// повторная доставка того же заказа (в том числе в нормализованном JSONB виде) событий не даёт
+func TestFromSaveRedelivery(t *testing.T) {
+	cur := json.RawMessage(`{"order_uid":"o1", "status":"new","items":[{"chrt_id":1,"status":1}]}`)
+	stored := json.RawMessage(`{"items": [{"status": 1, "chrt_id": 1}], "status": "new", "order_uid": "o1"}`)
+	for name, prev := range map[string]json.RawMessage{"same bytes": cur, "normalized": stored} {
+		envs, err := FromSave(prev, cur, time.Now())
+		if err != nil || len(envs) != 0 {
+			t.Fatalf("%s: %d events, %v", name, len(envs), err)
+		}
+	}
+	if envs, err := FromSave(nil, cur, time.Now()); err != nil || len(envs) != 1 || envs[0].Type != OrderCreated {
+		t.Fatalf("new order: %+v, %v", envs, err)
+	}
+}
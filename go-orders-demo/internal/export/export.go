@@ -0,0 +1,351 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+)
+
+// Форматы выгрузки
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv" // zip с orders.csv, items.csv и payments.csv
+	FormatParquet = "parquet"
+)
+
+// Source — заказы, по которым можно пройти несколько раз в одном снимке (реализуется db.ExportTx)
+type Source interface {
+	Scan(ctx context.Context, f db.ListFilter, fn func(raw json.RawMessage) error) error
+}
+
+// Masked — Source, в котором каждый заказ проходит через view (например, маску персональных данных)
+func Masked(src Source, view func(raw json.RawMessage) (json.RawMessage, error)) Source {
+	if view == nil {
+		return src
+	}
+	return maskedSource{src: src, view: view}
+}
+
+type maskedSource struct {
+	src  Source
+	view func(raw json.RawMessage) (json.RawMessage, error)
+}
+
+func (m maskedSource) Scan(ctx context.Context, f db.ListFilter, fn func(raw json.RawMessage) error) error {
+	return m.src.Scan(ctx, f, func(raw json.RawMessage) error {
+		raw, err := m.view(raw)
+		if err != nil {
+			return err
+		}
+		return fn(raw)
+	})
+}
+
+// ContentType и FileName — для Content-Type/Content-Disposition и имени файла по умолчанию
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "application/zip"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/x-ndjson"
+}
+
+func FileName(format string) string {
+	switch format {
+	case FormatCSV:
+		return "orders.zip"
+	case FormatParquet:
+		return "orders.parquet"
+	}
+	return "orders.ndjson"
+}
+
+// ParseFilter собирает фильтр выгрузки (как у ListOrders).
+// Даты — RFC3339 или YYYY-MM-DD, интервал [from, to).
+func ParseFilter(customerID, deliveryService, from, to string) (db.ListFilter, error) {
+	f := db.ListFilter{CustomerID: customerID, DeliveryService: deliveryService}
+	var err error
+	if f.CreatedFrom, err = parseTime(from); err != nil {
+		return f, fmt.Errorf("created_from: %w", err)
+	}
+	if f.CreatedTo, err = parseTime(to); err != nil {
+		return f, fmt.Errorf("created_to: %w", err)
+	}
+	return f, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+// Write выгружает заказы фильтра в w и возвращает их число.
+// Память не зависит от числа заказов: строки читаются курсором и сразу пишутся.
+func Write(ctx context.Context, w io.Writer, format string, src Source, f db.ListFilter) (int, error) {
+	switch format {
+	case FormatNDJSON, "":
+		return writeNDJSON(ctx, w, src, f)
+	case FormatCSV:
+		return writeCSVZip(ctx, w, src, f)
+	case FormatParquet:
+		return writeParquet(ctx, w, src, f)
+	}
+	return 0, fmt.Errorf("unknown format %q (want ndjson, csv or parquet)", format)
+}
+
+func writeNDJSON(ctx context.Context, w io.Writer, src Source, f db.ListFilter) (int, error) {
+	bw := bufio.NewWriter(w)
+	n := 0
+	var buf bytes.Buffer
+	err := src.Scan(ctx, f, func(raw json.RawMessage) error {
+		buf.Reset()
+		if err := json.Compact(&buf, raw); err != nil {
+			return fmt.Errorf("order is not valid JSON: %w", err)
+		}
+		buf.WriteByte('\n')
+		n++
+		_, err := bw.Write(buf.Bytes())
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// csvTable — один файл архива: заголовок и строки, которые даёт заказ
+type csvTable struct {
+	name   string
+	header []string
+	rows   func(o models.Order) [][]string
+}
+
+var csvTables = []csvTable{
+	{
+		name: "orders.csv",
+		header: []string{"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+			"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "status",
+			"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+			"delivery_region", "delivery_email"},
+		rows: func(o models.Order) [][]string {
+			d := o.Delivery
+			return [][]string{{o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+				o.DeliveryService, o.ShardKey, strconv.Itoa(o.SmID), formatTime(o.DateCreated), o.OofShard, o.Status,
+				d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email}}
+		},
+	},
+	{
+		name: "items.csv",
+		header: []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size",
+			"total_price", "nm_id", "brand", "status"},
+		rows: func(o models.Order) [][]string {
+			rows := make([][]string, 0, len(o.Items))
+			for _, it := range o.Items {
+				rows = append(rows, []string{o.OrderUID, strconv.Itoa(it.ChrtID), it.TrackNumber, strconv.Itoa(it.Price),
+					it.Rid, it.Name, strconv.Itoa(it.Sale), it.Size, strconv.Itoa(it.TotalPrice), strconv.Itoa(it.NmID),
+					it.Brand, strconv.Itoa(it.Status)})
+			}
+			return rows
+		},
+	},
+	{
+		name: "payments.csv",
+		header: []string{"order_uid", "transaction", "request_id", "currency", "provider", "amount", "payment_dt",
+			"bank", "delivery_cost", "goods_total", "custom_fee"},
+		rows: func(o models.Order) [][]string {
+			p := o.Payment
+			return [][]string{{o.OrderUID, p.Transaction, p.RequestID, p.Currency, p.Provider, strconv.Itoa(p.Amount),
+				strconv.FormatInt(p.PaymentDT, 10), p.Bank, strconv.Itoa(p.DeliveryCost), strconv.Itoa(p.GoodsTotal),
+				strconv.Itoa(p.CustomFee)}}
+		},
+	},
+}
+
+// writeCSVZip пишет файлы архива по очереди (zip не умеет писать их параллельно),
+// поэтому заказы читаются по разу на файл — в одном снимке данные совпадают
+func writeCSVZip(ctx context.Context, w io.Writer, src Source, f db.ListFilter) (int, error) {
+	zw := zip.NewWriter(w)
+	n := 0
+	for i, t := range csvTables {
+		fw, err := zw.Create(t.name)
+		if err != nil {
+			return n, err
+		}
+		cw := csv.NewWriter(fw)
+		cw.Write(t.header)
+		count := 0
+		err = src.Scan(ctx, f, func(raw json.RawMessage) error {
+			var o models.Order
+			if err := json.Unmarshal(raw, &o); err != nil {
+				return fmt.Errorf("decode order: %w", err)
+			}
+			count++
+			for _, row := range t.rows(o) {
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return n, err
+		}
+		if i == 0 {
+			n = count
+		}
+	}
+	return n, zw.Close()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parquetRowGroup — строк в группе; группа копится в памяти до записи
+const parquetRowGroup = 10000
+
+type parquetOrder struct {
+	OrderUID          string          `parquet:"order_uid"`
+	TrackNumber       string          `parquet:"track_number"`
+	Entry             string          `parquet:"entry"`
+	Locale            string          `parquet:"locale"`
+	InternalSignature string          `parquet:"internal_signature"`
+	CustomerID        string          `parquet:"customer_id"`
+	DeliveryService   string          `parquet:"delivery_service"`
+	ShardKey          string          `parquet:"shardkey"`
+	SmID              int64           `parquet:"sm_id"`
+	DateCreated       time.Time       `parquet:"date_created,timestamp(millisecond)"`
+	OofShard          string          `parquet:"oof_shard"`
+	Status            string          `parquet:"status"`
+	Delivery          parquetDelivery `parquet:"delivery"`
+	Payment           parquetPayment  `parquet:"payment"`
+	Items             []parquetItem   `parquet:"items,list"`
+}
+
+type parquetDelivery struct {
+	Name    string `parquet:"name"`
+	Phone   string `parquet:"phone"`
+	Zip     string `parquet:"zip"`
+	City    string `parquet:"city"`
+	Address string `parquet:"address"`
+	Region  string `parquet:"region"`
+	Email   string `parquet:"email"`
+}
+
+type parquetPayment struct {
+	Transaction  string `parquet:"transaction"`
+	RequestID    string `parquet:"request_id"`
+	Currency     string `parquet:"currency"`
+	Provider     string `parquet:"provider"`
+	Amount       int64  `parquet:"amount"`
+	PaymentDT    int64  `parquet:"payment_dt"`
+	Bank         string `parquet:"bank"`
+	DeliveryCost int64  `parquet:"delivery_cost"`
+	GoodsTotal   int64  `parquet:"goods_total"`
+	CustomFee    int64  `parquet:"custom_fee"`
+}
+
+type parquetItem struct {
+	ChrtID      int64  `parquet:"chrt_id"`
+	TrackNumber string `parquet:"track_number"`
+	Price       int64  `parquet:"price"`
+	Rid         string `parquet:"rid"`
+	Name        string `parquet:"name"`
+	Sale        int64  `parquet:"sale"`
+	Size        string `parquet:"size"`
+	TotalPrice  int64  `parquet:"total_price"`
+	NmID        int64  `parquet:"nm_id"`
+	Brand       string `parquet:"brand"`
+	Status      int64  `parquet:"status"`
+}
+
+func toParquet(o models.Order) parquetOrder {
+	p := parquetOrder{
+		OrderUID:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SmID:              int64(o.SmID),
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+		Status:            o.Status,
+		Delivery:          parquetDelivery(o.Delivery),
+		Payment: parquetPayment{
+			Transaction:  o.Payment.Transaction,
+			RequestID:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDT:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+	}
+	for _, it := range o.Items {
+		p.Items = append(p.Items, parquetItem{
+			ChrtID:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmID:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return p
+}
+
+func writeParquet(ctx context.Context, w io.Writer, src Source, f db.ListFilter) (int, error) {
+	pw := parquet.NewGenericWriter[parquetOrder](w, parquet.MaxRowsPerRowGroup(parquetRowGroup))
+	n := 0
+	row := make([]parquetOrder, 1)
+	err := src.Scan(ctx, f, func(raw json.RawMessage) error {
+		var o models.Order
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return fmt.Errorf("decode order: %w", err)
+		}
+		row[0] = toParquet(o)
+		n++
+		_, err := pw.Write(row)
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, pw.Close()
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"go-orders-demo/internal/db"
+)
+
+// sliceSource — Source поверх среза; считает проходы, как курсоры в ExportTx
+type sliceSource struct {
+	orders []string
+	scans  int
+}
+
+func (s *sliceSource) Scan(ctx context.Context, f db.ListFilter, fn func(json.RawMessage) error) error {
+	s.scans++
+	for _, o := range s.orders {
+		if err := fn(json.RawMessage(o)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var testOrders = []string{
+	`{"order_uid":"a", "customer_id":"c1", "date_created":"2024-01-02T03:04:05Z",
+	  "delivery":{"name":"N","city":"C"}, "payment":{"transaction":"a","amount":100},
+	  "items":[{"chrt_id":1,"name":"x","price":60},{"chrt_id":2,"name":"y, z","price":40}]}`,
+	`{"order_uid":"b","payment":{"transaction":"b","amount":5},"items":[]}`,
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Write(context.Background(), &buf, FormatNDJSON, &sliceSource{orders: testOrders}, db.ListFilter{})
+	if err != nil || n != 2 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"order_uid":"a","customer_id":"c1"`) {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestWriteCSVZip(t *testing.T) {
+	src := &sliceSource{orders: testOrders}
+	var buf bytes.Buffer
+	n, err := Write(context.Background(), &buf, FormatCSV, src, db.ListFilter{})
+	if err != nil || n != 2 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if src.scans != 3 {
+		t.Errorf("expected a scan per file, got %d", src.scans)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	if !strings.Contains(files["orders.csv"], "\na,,,,,c1,,,0,2024-01-02T03:04:05Z,,,N,,,C,,,\n") {
+		t.Errorf("orders.csv:\n%s", files["orders.csv"])
+	}
+	if want := "order_uid,chrt_id,track_number,price,rid,name,sale,size,total_price,nm_id,brand,status\n" +
+		"a,1,,60,,x,0,,0,0,,0\n" +
+		"a,2,,40,,\"y, z\",0,,0,0,,0\n"; files["items.csv"] != want {
+		t.Errorf("items.csv:\n%s", files["items.csv"])
+	}
+	if strings.Count(files["payments.csv"], "\n") != 3 || !strings.Contains(files["payments.csv"], "\nb,b,,,,5,") {
+		t.Errorf("payments.csv:\n%s", files["payments.csv"])
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Write(context.Background(), &buf, FormatParquet, &sliceSource{orders: testOrders}, db.ListFilter{})
+	if err != nil || n != 2 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	rows, err := parquet.Read[parquetOrder](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].OrderUID != "a" || len(rows[0].Items) != 2 || rows[0].Items[1].Name != "y, z" ||
+		rows[0].Payment.Amount != 100 || !rows[0].DateCreated.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("c1", "", "2024-01-01", "2024-02-01T00:00:00+03:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.CustomerID != "c1" || f.CreatedFrom.Format(time.DateOnly) != "2024-01-01" || f.CreatedTo.Hour() != 0 {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+	if _, err := ParseFilter("", "", "yesterday", ""); err == nil {
+		t.Fatal("expected error for bad date")
+	}
+	if _, err := Write(context.Background(), io.Discard, "xlsx", &sliceSource{}, db.ListFilter{}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
@@ -0,0 +1,119 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/pb/ordersv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles — роли методов OrderService; health и reflection остаются открытыми
+var methodRoles = map[string]string{
+	ordersv1.OrderService_GetOrder_FullMethodName:    auth.RoleReader,
+	ordersv1.OrderService_ListOrders_FullMethodName:  auth.RoleReader,
+	ordersv1.OrderService_WatchOrder_FullMethodName:  auth.RoleReader,
+	ordersv1.OrderService_IngestOrder_FullMethodName: auth.RoleIngester,
+}
+
+// WithAuth — перехватчики, проверяющие учётные данные из метаданных
+// authorization / x-api-key и роль метода; IngestOrder и отказы пишутся в журнал аудита
+func WithAuth(a *auth.Authenticator, audit auth.Auditor) []grpc.ServerOption {
+	g := &guard{auth: a, audit: audit}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			ctx, err := g.check(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := h(ctx, req)
+			if info.FullMethod == ordersv1.OrderService_IngestOrder_FullMethodName {
+				p, _ := auth.FromContext(ctx)
+				g.record(ctx, p, info.FullMethod, status.Code(err))
+			}
+			return resp, err
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			ctx, err := g.check(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return h(srv, &authStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+}
+
+type guard struct {
+	auth  *auth.Authenticator
+	audit auth.Auditor
+}
+
+func (g *guard) check(ctx context.Context, method string) (context.Context, error) {
+	role, ok := methodRoles[method]
+	if !ok {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := g.auth.Authenticate(ctx, first(md, "authorization"), first(md, "x-api-key"))
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return nil, status.Error(codes.Unauthenticated, "credentials required")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		g.record(ctx, auth.Principal{}, method, codes.Unauthenticated)
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	case err != nil:
+		log.Printf("grpc auth: %v", err)
+		return nil, status.Error(codes.Unavailable, "authentication unavailable")
+	}
+	if !p.Has(role) {
+		g.record(ctx, p, method, codes.PermissionDenied)
+		return nil, status.Errorf(codes.PermissionDenied, "role %s required", role)
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+// record пишет в журнал код gRPC вместо HTTP статуса
+func (g *guard) record(ctx context.Context, p auth.Principal, method string, code codes.Code) {
+	if g.audit == nil {
+		return
+	}
+	e := db.AuditEntry{
+		At:         time.Now(),
+		Principal:  p.Subject,
+		AuthMethod: p.Method,
+		Roles:      p.Roles,
+		Action:     "grpc " + method,
+		Status:     int(code),
+	}
+	if pr, ok := peer.FromContext(ctx); ok {
+		e.RemoteAddr = pr.Addr.String()
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := g.audit.RecordAudit(ctx, e); err != nil {
+		log.Printf("audit %s %s: %v", e.Principal, e.Action, err)
+	}
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// authStream подменяет контекст потока, чтобы обработчик видел Principal
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context { return s.ctx }
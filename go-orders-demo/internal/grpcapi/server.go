@@ -0,0 +1,264 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+
+	"go-orders-demo/internal/api"
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pb/ordersv1"
+	"go-orders-demo/internal/pii"
+	"go-orders-demo/internal/ratelimit"
+	"go-orders-demo/internal/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Ingester принимает проверенный заказ (реализуется api.Server)
+type Ingester interface {
+	Accept(ctx context.Context, order models.Order) error
+}
+
+// Lister — постраничная выборка заказов (реализуется db.SQLStore)
+type Lister interface {
+	ListOrders(ctx context.Context, f db.ListFilter) ([]json.RawMessage, *db.Cursor, error)
+}
+
+// Server — gRPC реализация OrderService поверх тех же кеша и БД, что и HTTP API
+type Server struct {
+	ordersv1.UnimplementedOrderServiceServer
+
+	addr   string
+	cache  api.Cache
+	db     db.Repository
+	lister Lister
+	ingest Ingester
+	feed   *stream.Hub
+	pii    *pii.Masker
+
+	grpcSrv *grpc.Server
+	health  *health.Server
+}
+
+func New(addr string, cache api.Cache, store db.Repository, lister Lister, ingest Ingester, feed *stream.Hub, opts ...grpc.ServerOption) *Server {
+	s := &Server{addr: addr, cache: cache, db: store, lister: lister, ingest: ingest, feed: feed}
+	s.grpcSrv = grpc.NewServer(opts...)
+	s.health = health.NewServer()
+
+	ordersv1.RegisterOrderServiceServer(s.grpcSrv, s)
+	healthpb.RegisterHealthServer(s.grpcSrv, s.health)
+	reflection.Register(s.grpcSrv)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(ordersv1.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+// WithPII маскирует персональные данные в ответах для клиентов без права их видеть
+func (s *Server) WithPII(m *pii.Masker) *Server {
+	s.pii = m
+	return s
+}
+
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve обслуживает уже открытый listener (удобно для тестов)
+func (s *Server) Serve(lis net.Listener) error { return s.grpcSrv.Serve(lis) }
+
+// Stop переводит health в NOT_SERVING и дожидается активных вызовов до отмены ctx
+func (s *Server) Stop(ctx context.Context) {
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.grpcSrv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpcSrv.Stop()
+	}
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid required")
+	}
+	raw, err := s.lookup(ctx, req.GetOrderUid())
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.decodeFor(ctx, raw)
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *ordersv1.ListOrdersRequest) (*ordersv1.ListOrdersResponse, error) {
+	if s.lister == nil {
+		return nil, status.Error(codes.Unimplemented, "listing is not available")
+	}
+	f := db.ListFilter{
+		CustomerID:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+		Limit:           int(req.GetPageSize()),
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	if req.GetCreatedFrom() != nil {
+		f.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		f.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+	if tok := req.GetPageToken(); tok != "" {
+		c, err := db.DecodeCursor(tok)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		f.After = &c
+	}
+
+	raws, next, err := s.lister.ListOrders(ctx, f)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &ordersv1.ListOrdersResponse{}
+	for _, raw := range raws {
+		o, err := s.decodeFor(ctx, raw)
+		if err != nil {
+			return nil, err
+		}
+		resp.Orders = append(resp.Orders, o)
+	}
+	if next != nil {
+		resp.NextPageToken = next.Encode()
+	}
+	return resp, nil
+}
+
+func (s *Server) IngestOrder(ctx context.Context, req *ordersv1.IngestOrderRequest) (*ordersv1.IngestOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order required")
+	}
+	order := req.GetOrder().ToModel()
+	if err := order.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// без аутентификации квота считается по адресу клиента, как в HTTP
+	if pr, ok := peer.FromContext(ctx); ok {
+		if _, authed := auth.FromContext(ctx); !authed {
+			host, _, _ := net.SplitHostPort(pr.Addr.String())
+			ctx = ratelimit.WithClient(ctx, host)
+		}
+	}
+	if err := s.ingest.Accept(ctx, order); err != nil {
+		log.Printf("grpc ingest %s: %v", order.OrderUID, err)
+		if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, status.Error(codes.Unavailable, "failed to accept order")
+	}
+	return &ordersv1.IngestOrderResponse{OrderUid: order.OrderUID}, nil
+}
+
+func (s *Server) WatchOrder(req *ordersv1.WatchOrderRequest, srv ordersv1.OrderService_WatchOrderServer) error {
+	id := req.GetOrderUid()
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "order_uid required")
+	}
+	ctx := srv.Context()
+
+	// подписываемся до чтения снимка, чтобы не пропустить изменение между ними
+	_, sub := s.feed.Subscribe(stream.Filter{OrderUIDs: []string{id}}, 0, false)
+	defer sub.Close()
+
+	raw, err := s.lookup(ctx, id)
+	switch {
+	case err == nil:
+		o, err := s.decodeFor(ctx, raw)
+		if err != nil {
+			return err
+		}
+		if err := srv.Send(o); err != nil {
+			return err
+		}
+	case !errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher is too slow, resubscribe")
+			}
+			o, err := s.decodeFor(ctx, e.Data)
+			if err != nil {
+				return err
+			}
+			if err := srv.Send(o); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lookup — как handleGet: сначала кеш, затем БД с заполнением кеша
+func (s *Server) lookup(ctx context.Context, id string) (json.RawMessage, error) {
+	if e, ok := s.cache.Entry(id); ok {
+		return e.Raw, nil
+	}
+	raw, modified, err := s.db.GetRawModified(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Put(id, raw, modified)
+	return raw, nil
+}
+
+// decodeFor — decode с маской персональных данных клиента запроса
+func (s *Server) decodeFor(ctx context.Context, raw json.RawMessage) (*ordersv1.Order, error) {
+	if view := s.pii.For(ctx); view != nil {
+		var err error
+		if raw, err = view(raw); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return decode(raw)
+}
+
+func decode(raw json.RawMessage) (*ordersv1.Order, error) {
+	var o models.Order
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, status.Errorf(codes.DataLoss, "stored order is not valid JSON: %v", err)
+	}
+	return ordersv1.FromModel(o), nil
+}
@@ -0,0 +1,144 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/cache"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pb/ordersv1"
+	"go-orders-demo/internal/stream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type mockRepo struct {
+	data map[string][]byte
+}
+
+func (m *mockRepo) SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error) {
+	return time.Time{}, nil
+}
+func (m *mockRepo) GetRaw(ctx context.Context, id string) (json.RawMessage, error) {
+	v, ok := m.data[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return json.RawMessage(v), nil
+}
+func (m *mockRepo) GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error) {
+	raw, err := m.GetRaw(ctx, id)
+	return raw, time.Time{}, err
+}
+func (m *mockRepo) LoadAllRaw(ctx context.Context, limit int) (map[string]db.RawOrder, error) {
+	return nil, nil
+}
+func (m *mockRepo) SaveOrder(ctx context.Context, o models.Order) error { return nil }
+
+type mockIngester struct {
+	got []models.Order
+}
+
+func (m *mockIngester) Accept(ctx context.Context, o models.Order) error {
+	m.got = append(m.got, o)
+	return nil
+}
+
+func newClient(t *testing.T, s *Server) ordersv1.OrderServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ordersv1.NewOrderServiceClient(conn)
+}
+
+func TestGetOrder(t *testing.T) {
+	repo := &mockRepo{data: map[string][]byte{"x": []byte(`{"order_uid":"x","track_number":"T1","items":[{"chrt_id":1,"price":100}]}`)}}
+	cl := newClient(t, New(":0", cache.New(10), repo, nil, &mockIngester{}, stream.NewHub(10, 10)))
+
+	o, err := cl.GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.GetTrackNumber() != "T1" || len(o.GetItems()) != 1 || o.GetItems()[0].GetPrice() != 100 {
+		t.Fatalf("unexpected order %v", o)
+	}
+
+	_, err = cl.GetOrder(context.Background(), &ordersv1.GetOrderRequest{OrderUid: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	_, err = cl.ListOrders(context.Background(), &ordersv1.ListOrdersRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented without lister, got %v", err)
+	}
+}
+
+func TestIngestOrder(t *testing.T) {
+	ing := &mockIngester{}
+	cl := newClient(t, New(":0", cache.New(10), &mockRepo{}, nil, ing, stream.NewHub(10, 10)))
+
+	_, err := cl.IngestOrder(context.Background(), &ordersv1.IngestOrderRequest{Order: &ordersv1.Order{OrderUid: "x"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if len(ing.got) != 0 {
+		t.Fatalf("invalid order must not be accepted")
+	}
+
+	valid := &ordersv1.Order{
+		OrderUid:    "y",
+		TrackNumber: "T",
+		Delivery:    &ordersv1.Delivery{Name: "n", Phone: "+1", Address: "a"},
+		Payment:     &ordersv1.Payment{Transaction: "y", Amount: 10},
+		Items:       []*ordersv1.Item{{ChrtId: 1, Price: 10}},
+	}
+	resp, err := cl.IngestOrder(context.Background(), &ordersv1.IngestOrderRequest{Order: valid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetOrderUid() != "y" || len(ing.got) != 1 {
+		t.Fatalf("unexpected result %v, accepted %d", resp, len(ing.got))
+	}
+}
+
+func TestWatchOrder(t *testing.T) {
+	feed := stream.NewHub(10, 10)
+	repo := &mockRepo{data: map[string][]byte{"x": []byte(`{"order_uid":"x","track_number":"T1"}`)}}
+	cl := newClient(t, New(":0", cache.New(10), repo, nil, &mockIngester{}, feed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w, err := cl.WatchOrder(ctx, &ordersv1.WatchOrderRequest{OrderUid: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.Recv()
+	if err != nil || o.GetTrackNumber() != "T1" {
+		t.Fatalf("expected snapshot, got %v %v", o, err)
+	}
+
+	// подписка регистрируется до снимка, поэтому публикация после него дойдёт
+	feed.Publish("other", json.RawMessage(`{"order_uid":"other"}`))
+	feed.Publish("x", json.RawMessage(`{"order_uid":"x","track_number":"T2"}`))
+	o, err = w.Recv()
+	if err != nil || o.GetTrackNumber() != "T2" {
+		t.Fatalf("expected update, got %v %v", o, err)
+	}
+}
@@ -0,0 +1,249 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/db"
+	kaf "go-orders-demo/internal/kafka"
+	"go-orders-demo/internal/models"
+)
+
+// Форматы входных файлов
+const (
+	FormatNDJSON = "ndjson"
+	// FormatCSV — плоский CSV, как GET /order/{id} с Accept: text/csv,
+	// или zip из GET /export?format=csv (распознаётся по содержимому)
+	FormatCSV = "csv"
+)
+
+// FormatByName определяет формат по расширению файла (.csv и .zip, иначе NDJSON)
+func FormatByName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".zip":
+		return FormatCSV
+	}
+	return FormatNDJSON
+}
+
+// Sink — куда пишутся заказы. Возвращает ошибку для каждого заказа (nil — записан).
+type Sink interface {
+	Write(ctx context.Context, orders []models.Order) []error
+}
+
+// KafkaSink публикует заказы в топик заказов пакетом, как /ingest/bulk
+type KafkaSink struct {
+	prod *kaf.Producer
+}
+
+func NewKafkaSink(p *kaf.Producer) *KafkaSink { return &KafkaSink{prod: p} }
+
+func (s *KafkaSink) Write(ctx context.Context, orders []models.Order) []error {
+	batch := make([]kaf.Payload, len(orders))
+	for i, o := range orders {
+		payload, _ := json.Marshal(o)
+		batch[i] = kaf.Payload{Key: o.OrderUID, Value: payload}
+	}
+	return s.prod.ProduceBatch(ctx, batch)
+}
+
+// StoreSink пишет заказы прямо в БД через SaveOrder, минуя Kafka:
+// кеш, события и webhooks об этих заказах не узнают
+type StoreSink struct {
+	repo db.Repository
+}
+
+func NewStoreSink(repo db.Repository) *StoreSink { return &StoreSink{repo: repo} }
+
+func (s *StoreSink) Write(ctx context.Context, orders []models.Order) []error {
+	errs := make([]error, len(orders))
+	for i, o := range orders {
+		errs[i] = s.repo.SaveOrder(ctx, o)
+	}
+	return errs
+}
+
+// Options — параметры импорта
+type Options struct {
+	Format       string  // ndjson или csv
+	BatchSize    int     // заказов в одной записи в Sink
+	Rate         float64 // заказов в секунду, 0 — без ограничения
+	MaxLineBytes int     // лимит строки NDJSON
+	Checkpoint   string  // файл с последней обработанной строкой; пусто — без чекпойнта
+	DryRun       bool    // только разбор и валидация, без записи и чекпойнта
+}
+
+// Stats — итог импорта
+type Stats struct {
+	Skipped  int // пропущены по чекпойнту
+	Imported int
+	Invalid  int
+	Line     int // последняя обработанная строка
+}
+
+// Run читает заказы из r и пишет валидные в sink пакетами.
+// Невалидные заказы логируются и пропускаются. После каждого пакета номер
+// последней обработанной строки сохраняется в чекпойнт; повторный запуск
+// с тем же чекпойнтом продолжает с места остановки. При ошибке записи импорт
+// останавливается, а чекпойнт указывает на строку перед первым незаписанным заказом.
+func Run(ctx context.Context, r io.Reader, sink Sink, opts Options) (Stats, error) {
+	var st Stats
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.MaxLineBytes <= 0 {
+		opts.MaxLineBytes = 1 << 20
+	}
+	// при малом rate пакет не должен копиться дольше секунды
+	if opts.Rate > 0 && float64(opts.BatchSize) > opts.Rate {
+		opts.BatchSize = max(1, int(opts.Rate))
+	}
+	from, err := LoadCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return st, err
+	}
+	st.Line = from
+	rd, err := newReader(r, opts.Format, opts.MaxLineBytes)
+	if err != nil {
+		return st, err
+	}
+
+	p := newPacer(opts.Rate)
+	var batch []record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		orders := make([]models.Order, len(batch))
+		for i, rec := range batch {
+			orders[i] = rec.Order
+		}
+		errs := sink.Write(ctx, orders)
+		for i, err := range errs {
+			if err != nil {
+				// всё до этого заказа записано; он и следующие — при повторном запуске
+				st.Imported += i
+				st.Line = batch[i].First - 1
+				saveErr := saveCheckpoint(opts, st.Line)
+				return errors.Join(fmt.Errorf("line %d (%s): %w", batch[i].First, orders[i].OrderUID, err), saveErr)
+			}
+		}
+		st.Imported += len(batch)
+		batch = batch[:0]
+		return saveCheckpoint(opts, st.Line)
+	}
+
+	for {
+		rec, err := rd.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return st, errors.Join(err, flush())
+		}
+		if rec.Line <= from {
+			st.Skipped++
+			continue
+		}
+		if rec.Err != nil {
+			st.Invalid++
+			log.Printf("import: line %d (%s): %v", rec.First, rec.Order.OrderUID, rec.Err)
+		} else if opts.DryRun {
+			st.Imported++
+		} else {
+			if err := p.wait(ctx); err != nil {
+				return st, errors.Join(err, flush())
+			}
+			batch = append(batch, rec)
+		}
+		st.Line = rec.Line
+		if len(batch) >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return st, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return st, errors.Join(err, flush())
+		}
+	}
+	return st, flush()
+}
+
+// pacer равномерно распределяет заказы во времени: не больше rate в секунду
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newPacer(rate float64) *pacer {
+	if rate <= 0 {
+		return &pacer{}
+	}
+	return &pacer{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (p *pacer) wait(ctx context.Context) error {
+	if p.interval == 0 {
+		return nil
+	}
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	d := p.next.Sub(now)
+	p.next = p.next.Add(p.interval)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// LoadCheckpoint возвращает последнюю обработанную строку; нет файла — 0
+func LoadCheckpoint(path string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint: %w", err)
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || line < 0 {
+		return 0, fmt.Errorf("checkpoint %s: bad line number %q", path, strings.TrimSpace(string(b)))
+	}
+	return line, nil
+}
+
+// saveCheckpoint пишет номер строки через временный файл, чтобы не оставить обрезанный чекпойнт
+func saveCheckpoint(opts Options, line int) error {
+	if opts.Checkpoint == "" || opts.DryRun {
+		return nil
+	}
+	tmp := opts.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, opts.Checkpoint); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
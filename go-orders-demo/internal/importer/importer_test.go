@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/export"
+	"go-orders-demo/internal/models"
+)
+
+// fakeSink запоминает записанные заказы; заказ с failUID не записывается
+type fakeSink struct {
+	written []string
+	batches int
+	failUID string
+}
+
+func (s *fakeSink) Write(ctx context.Context, orders []models.Order) []error {
+	s.batches++
+	errs := make([]error, len(orders))
+	for i, o := range orders {
+		if o.OrderUID == s.failUID {
+			errs[i] = errors.New("broker unavailable")
+			continue
+		}
+		s.written = append(s.written, o.OrderUID)
+	}
+	return errs
+}
+
+func order(uid string) string {
+	return `{"order_uid":"` + uid + `","track_number":"T","delivery":{"name":"N","address":"A"},"payment":{"transaction":"` + uid + `"}}`
+}
+
+var testNDJSON = strings.Join([]string{
+	order("a"),
+	"",
+	`{"order_uid":"bad"}`,
+	order("b"),
+	"not json",
+	order("c"),
+}, "\n")
+
+func TestRunNDJSONCheckpoint(t *testing.T) {
+	cp := filepath.Join(t.TempDir(), "orders.checkpoint")
+	opts := Options{Format: FormatNDJSON, BatchSize: 2, Checkpoint: cp}
+
+	// первый запуск падает на "b": "a" записан, чекпойнт — строка перед "b"
+	sink := &fakeSink{failUID: "b"}
+	st, err := Run(context.Background(), strings.NewReader(testNDJSON), sink, opts)
+	if err == nil || !strings.Contains(err.Error(), "line 4 (b)") {
+		t.Fatalf("expected failure at line 4, got %v", err)
+	}
+	if st.Imported != 1 || st.Invalid != 1 || st.Line != 3 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if line, _ := LoadCheckpoint(cp); line != 3 {
+		t.Fatalf("checkpoint = %d, want 3", line)
+	}
+
+	// повторный запуск продолжает с "b"
+	sink = &fakeSink{}
+	st, err = Run(context.Background(), strings.NewReader(testNDJSON), sink, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sink.written, ",") != "b,c" || st.Skipped != 2 || st.Invalid != 1 || st.Line != 6 {
+		t.Fatalf("written=%v stats=%+v", sink.written, st)
+	}
+	if line, _ := LoadCheckpoint(cp); line != 6 {
+		t.Fatalf("checkpoint = %d, want 6", line)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	cp := filepath.Join(t.TempDir(), "orders.checkpoint")
+	sink := &fakeSink{}
+	st, err := Run(context.Background(), strings.NewReader(testNDJSON), sink,
+		Options{Format: FormatNDJSON, BatchSize: 10, Checkpoint: cp, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.batches != 0 || st.Imported != 3 || st.Invalid != 2 {
+		t.Fatalf("batches=%d stats=%+v", sink.batches, st)
+	}
+	if line, _ := LoadCheckpoint(cp); line != 0 {
+		t.Fatal("dry run must not write checkpoint")
+	}
+}
+
+func TestRunCSV(t *testing.T) {
+	data := "order_uid,track_number,delivery.name,delivery.address,payment.transaction,payment.amount,sm_id,items.chrt_id,items.name\n" +
+		"a,T,N,A,a,100,7,1,x\n" +
+		"a,T,N,A,a,100,7,2,\"y, z\"\n" +
+		"b,T,N,A,b,abc,0,,\n" +
+		"c,T,N,A,c,5,0,,\n"
+	var got []models.Order
+	sink := sinkFunc(func(orders []models.Order) { got = append(got, orders...) })
+	st, err := Run(context.Background(), strings.NewReader(data), sink, Options{Format: FormatCSV, BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Imported != 2 || st.Invalid != 1 || st.Line != 5 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	a := got[0]
+	if a.OrderUID != "a" || a.SmID != 7 || a.Payment.Amount != 100 || a.Delivery.Address != "A" ||
+		len(a.Items) != 2 || a.Items[1].Name != "y, z" || a.Items[1].ChrtID != 2 {
+		t.Fatalf("unexpected order %+v", a)
+	}
+	if got[1].OrderUID != "c" || len(got[1].Items) != 0 {
+		t.Fatalf("unexpected order %+v", got[1])
+	}
+}
+
+type sinkFunc func([]models.Order)
+
+func (f sinkFunc) Write(ctx context.Context, orders []models.Order) []error {
+	f(orders)
+	return make([]error, len(orders))
+}
+
+type exportSource []models.Order
+
+func (s exportSource) Scan(ctx context.Context, f db.ListFilter, fn func(json.RawMessage) error) error {
+	for _, o := range s {
+		raw, _ := json.Marshal(o)
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestRunExportZip: zip из GET /export?format=csv загружается обратно без потерь
+func TestRunExportZip(t *testing.T) {
+	orders := exportSource{
+		{
+			OrderUID: "a", TrackNumber: "T", Entry: "WBIL", Locale: "en", CustomerID: "c1", DeliveryService: "meest",
+			ShardKey: "9", SmID: 99, DateCreated: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), OofShard: "1", Status: "new",
+			Delivery: models.Delivery{Name: "N", Phone: "+1", Zip: "2", City: "C", Address: "A", Region: "R", Email: "e@x"},
+			Payment:  models.Payment{Transaction: "a", Currency: "USD", Provider: "wbpay", Amount: 100, PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 10, GoodsTotal: 90},
+			Items: []models.Item{
+				{ChrtID: 1, TrackNumber: "T", Price: 60, Rid: "r1", Name: "x", Size: "0", TotalPrice: 60, NmID: 5, Brand: "B", Status: 202},
+				{ChrtID: 2, TrackNumber: "T", Price: 40, Rid: "r2", Name: "y, z", Sale: 10, TotalPrice: 30, NmID: 6, Status: 202},
+			},
+		},
+		{OrderUID: "b", TrackNumber: "T", Delivery: models.Delivery{Name: "N", Address: "A"}, Payment: models.Payment{Transaction: "b", Amount: 5}},
+		{OrderUID: "c", TrackNumber: "T", Delivery: models.Delivery{Name: "N", Address: "A"}, Payment: models.Payment{Transaction: "c"},
+			Items: []models.Item{{ChrtID: 3, Name: "w"}}},
+	}
+	name := filepath.Join(t.TempDir(), "orders.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := export.Write(context.Background(), f, export.FormatCSV, orders, db.ListFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []models.Order
+	sink := sinkFunc(func(batch []models.Order) { got = append(got, batch...) })
+	st, err := Run(context.Background(), f, sink, Options{Format: FormatByName(name), BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Imported != 3 || st.Invalid != 0 || st.Line != 4 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if !reflect.DeepEqual([]models.Order(orders), got) {
+		t.Fatalf("round trip mismatch:\nwant %+v\ngot  %+v", orders, got)
+	}
+
+	// zip читается с конца, из потока его не прочитать
+	if _, err := Run(context.Background(), struct{ io.Reader }{strings.NewReader("PK\x03\x04")}, sink, Options{Format: FormatCSV}); err == nil ||
+		!strings.Contains(err.Error(), "need a file") {
+		t.Fatalf("zip from stream: %v", err)
+	}
+}
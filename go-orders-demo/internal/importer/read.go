@@ -0,0 +1,369 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"time"
+
+	"go-orders-demo/internal/models"
+)
+
+// record — заказ из файла; First..Line — строки файла, которые он занимает.
+// Err — заказ не разобран или не прошёл валидацию.
+type record struct {
+	First, Line int
+	Order       models.Order
+	Err         error
+}
+
+// reader отдаёт заказы по порядку; в конце — io.EOF
+type reader interface {
+	next() (record, error)
+}
+
+func newReader(r io.Reader, format string, maxLine int) (reader, error) {
+	switch format {
+	case FormatNDJSON:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64<<10), maxLine)
+		return &ndjsonReader{sc: sc, maxLine: maxLine}, nil
+	case FormatCSV:
+		br := bufio.NewReader(r)
+		if magic, _ := br.Peek(4); string(magic) == "PK\x03\x04" {
+			return newZipReader(r)
+		}
+		return newCSVReader(newCSV(br), flatColumn)
+	}
+	return nil, fmt.Errorf("unknown format %q (want ndjson or csv)", format)
+}
+
+func newCSV(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return cr
+}
+
+// ndjsonReader — заказ на строку; пустые строки пропускаются
+type ndjsonReader struct {
+	sc      *bufio.Scanner
+	line    int
+	maxLine int
+}
+
+func (r *ndjsonReader) next() (record, error) {
+	for r.sc.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		rec := record{First: r.line, Line: r.line}
+		if err := json.Unmarshal(data, &rec.Order); err != nil {
+			rec.Err = errors.New("invalid JSON format")
+		} else {
+			rec.Err = rec.Order.Validate()
+		}
+		return rec, nil
+	}
+	if errors.Is(r.sc.Err(), bufio.ErrTooLong) {
+		return record{}, fmt.Errorf("line %d: longer than %d bytes", r.line+1, r.maxLine)
+	}
+	if err := r.sc.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// csvReader читает плоский CSV, как в ответе GET /order/{id} с Accept: text/csv:
+// колонки delivery.name, payment.amount, items.price, …, строка на позицию.
+// Подряд идущие строки с одним order_uid собираются в один заказ.
+// Колонки delivery_name, … из orders.csv выгрузки тоже понимаются.
+type csvReader struct {
+	cr     *csv.Reader
+	header []string
+	kinds  []reflect.Kind
+
+	pending map[string]string // первая строка следующего заказа
+	line    int               // строка pending
+	eof     bool
+}
+
+// csvKinds — тип значения по имени колонки, чтобы числа попали в JSON числами
+var csvKinds = fieldKinds(reflect.TypeOf(models.Order{}), "", map[string]reflect.Kind{})
+
+func fieldKinds(t reflect.Type, path string, dst map[string]reflect.Kind) map[string]reflect.Kind {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+			fieldKinds(ft, name, dst)
+			continue
+		}
+		dst[name] = ft.Kind()
+	}
+	return dst
+}
+
+// flatColumn — путь поля заказа по колонке плоского CSV или orders.csv выгрузки
+func flatColumn(h string) string {
+	if f, ok := strings.CutPrefix(h, "delivery_"); ok && h != "delivery_service" {
+		return "delivery." + f
+	}
+	return h
+}
+
+// newCSVReader читает заголовок; column переводит имя колонки в путь поля заказа
+func newCSVReader(cr *csv.Reader, column func(string) string) (*csvReader, error) {
+	header, err := cr.Read()
+	if err == io.EOF {
+		return &csvReader{cr: cr, eof: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	r := &csvReader{cr: cr}
+	hasUID := false
+	for _, h := range header {
+		h = column(h)
+		r.header = append(r.header, h)
+		r.kinds = append(r.kinds, csvKinds[h])
+		hasUID = hasUID || h == "order_uid"
+	}
+	if !hasUID {
+		return nil, errors.New("csv header: no order_uid column")
+	}
+	return r, r.advance()
+}
+
+// advance читает следующую строку в pending
+func (r *csvReader) advance() error {
+	rec, err := r.cr.Read()
+	if err == io.EOF {
+		r.pending, r.eof = nil, true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.line, _ = r.cr.FieldPos(0)
+	row := make(map[string]string, len(r.header))
+	for i, h := range r.header {
+		if i < len(rec) {
+			row[h] = rec[i]
+		}
+	}
+	r.pending = row
+	return nil
+}
+
+func (r *csvReader) next() (record, error) {
+	if r.eof {
+		return record{}, io.EOF
+	}
+	g, err := r.group()
+	if err != nil {
+		return record{}, err
+	}
+	return g.record(), nil
+}
+
+// csvGroup — подряд идущие строки одного order_uid, собранные в JSON объект заказа
+type csvGroup struct {
+	uid         string
+	doc         map[string]any
+	first, line int
+	err         error
+}
+
+func (r *csvReader) group() (csvGroup, error) {
+	uid := r.pending["order_uid"]
+	g := csvGroup{uid: uid, doc: map[string]any{}, first: r.line}
+	var items []any
+	for first := true; !r.eof && r.pending["order_uid"] == uid; first = false {
+		g.line = r.line
+		item := map[string]any{}
+		for i, h := range r.header {
+			v, err := csvJSONValue(r.pending[h], r.kinds[i])
+			if err != nil && g.err == nil {
+				g.err = fmt.Errorf("column %s: %w", h, err)
+			}
+			if v == nil {
+				continue
+			}
+			if key, ok := strings.CutPrefix(h, "items."); ok {
+				item[key] = v
+			} else if first {
+				setPath(g.doc, h, v)
+			}
+		}
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+		if err := r.advance(); err != nil {
+			return g, err
+		}
+	}
+	g.doc["items"] = items
+	return g, nil
+}
+
+// record разбирает собранный заказ и проверяет его
+func (g csvGroup) record() record {
+	rec := record{First: g.first, Line: g.line, Err: g.err}
+	if rec.Err != nil {
+		return rec
+	}
+	b, err := json.Marshal(g.doc)
+	if err == nil {
+		err = json.Unmarshal(b, &rec.Order)
+	}
+	if err != nil {
+		rec.Err = err
+	} else {
+		rec.Err = rec.Order.Validate()
+	}
+	return rec
+}
+
+// zipReader читает zip из GET /export?format=csv: orders.csv, items.csv и payments.csv.
+// Выгрузка пишет все три файла в одном порядке заказов, поэтому они читаются
+// параллельно, без загрузки в память. Номера строк — строки orders.csv.
+type zipReader struct {
+	orders *csvReader
+	parts  []zipPart // items.csv и payments.csv
+}
+
+type zipPart struct {
+	name  string
+	field string // поле заказа, которое даёт файл
+	r     *csvReader
+}
+
+// newZipReader открывает архив; ему нужен файл, а не поток: zip читается с конца
+func newZipReader(r io.Reader) (*zipReader, error) {
+	f, ok := r.(interface {
+		io.ReaderAt
+		Stat() (fs.FileInfo, error)
+	})
+	if !ok {
+		return nil, errors.New("zip: need a file, not a stream")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("zip: %w", err)
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("zip: %w", err)
+	}
+	open := func(name string, column func(string) string) (*csvReader, error) {
+		f, err := zr.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("zip: %w", err)
+		}
+		cr, err := newCSVReader(newCSV(f), column)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return cr, nil
+	}
+	prefixed := func(prefix string) func(string) string {
+		return func(h string) string {
+			if h == "order_uid" {
+				return h
+			}
+			return prefix + h
+		}
+	}
+	z := &zipReader{}
+	if z.orders, err = open("orders.csv", flatColumn); err != nil {
+		return nil, err
+	}
+	for _, p := range []zipPart{{name: "items.csv", field: "items"}, {name: "payments.csv", field: "payment"}} {
+		if p.r, err = open(p.name, prefixed(p.field+".")); err != nil {
+			return nil, err
+		}
+		z.parts = append(z.parts, p)
+	}
+	return z, nil
+}
+
+func (z *zipReader) next() (record, error) {
+	if z.orders.eof {
+		for _, p := range z.parts {
+			if !p.r.eof {
+				return record{}, fmt.Errorf("%s line %d: order_uid %q is not in orders.csv or out of its order",
+					p.name, p.r.line, p.r.pending["order_uid"])
+			}
+		}
+		return record{}, io.EOF
+	}
+	g, err := z.orders.group()
+	if err != nil {
+		return record{}, fmt.Errorf("orders.csv: %w", err)
+	}
+	for _, p := range z.parts {
+		if p.r.eof || p.r.pending["order_uid"] != g.uid {
+			continue
+		}
+		pg, err := p.r.group()
+		if err != nil {
+			return record{}, fmt.Errorf("%s: %w", p.name, err)
+		}
+		if g.err == nil && pg.err != nil {
+			g.err = fmt.Errorf("%s: %w", p.name, pg.err)
+		}
+		g.doc[p.field] = pg.doc[p.field]
+	}
+	return g.record(), nil
+}
+
+// csvJSONValue — значение ячейки для JSON; пустая ячейка — поле не задано
+func csvJSONValue(s string, kind reflect.Kind) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+	switch kind {
+	case reflect.Int, reflect.Int64:
+		n := json.Number(s)
+		if _, err := n.Int64(); err != nil {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		return n, nil
+	}
+	return s, nil
+}
+
+// setPath кладёт значение по пути delivery.name во вложенные объекты
+func setPath(doc map[string]any, path string, v any) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := doc[p].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			doc[p] = child
+		}
+		doc = child
+	}
+	doc[parts[len(parts)-1]] = v
+}
@@ -0,0 +1,416 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go-orders-demo/internal/db"
+)
+
+// PartitionOffset — offset партиции топика
+type PartitionOffset struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// Admin — служебные операции над топиком заказов: offsets группы, перемотка, replay
+type Admin struct {
+	client  *kafka.Client
+	brokers []string
+	topic   string
+	sec     *Security
+}
+
+func NewAdmin(brokers []string, topic string, sec *Security) *Admin {
+	return &Admin{
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   10 * time.Second,
+			Transport: sec.transport(),
+		},
+		brokers: brokers,
+		topic:   topic,
+		sec:     sec,
+	}
+}
+
+// Partitions возвращает номера партиций топика по возрастанию
+func (a *Admin) Partitions(ctx context.Context) ([]int, error) {
+	res, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{a.topic}})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range res.Topics {
+		if t.Name != a.topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("topic %s: %w", a.topic, t.Error)
+		}
+		ids := make([]int, len(t.Partitions))
+		for i, p := range t.Partitions {
+			ids[i] = p.ID
+		}
+		sort.Ints(ids)
+		return ids, nil
+	}
+	return nil, fmt.Errorf("topic %s not found", a.topic)
+}
+
+// PartitionState — границы партиции и offset группы (-1 — группа ещё ничего не коммитила)
+type PartitionState struct {
+	Partition int   `json:"partition"`
+	First     int64 `json:"first_offset"`
+	Last      int64 `json:"last_offset"` // следующий offset для записи
+	Committed int64 `json:"committed_offset"`
+	Lag       int64 `json:"lag"`
+}
+
+// GroupOffsets показывает, где группа находится в каждой партиции
+func (a *Admin) GroupOffsets(ctx context.Context, group string) ([]PartitionState, error) {
+	parts, err := a.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := a.bounds(ctx, parts)
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: group,
+		Topics:  map[string][]int{a.topic: parts},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	committed := map[int]int64{}
+	for _, p := range res.Topics[a.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+		committed[p.Partition] = p.CommittedOffset
+	}
+	states := make([]PartitionState, len(parts))
+	for i, p := range parts {
+		st := PartitionState{Partition: p, First: bounds[p].FirstOffset, Last: bounds[p].LastOffset, Committed: -1}
+		if off, ok := committed[p]; ok {
+			st.Committed = off
+		}
+		if st.Committed >= 0 {
+			st.Lag = st.Last - st.Committed
+		} else {
+			st.Lag = st.Last - st.First
+		}
+		states[i] = st
+	}
+	return states, nil
+}
+
+// bounds — первый и следующий за последним offset каждой партиции
+func (a *Admin) bounds(ctx context.Context, parts []int) (map[int]kafka.PartitionOffsets, error) {
+	reqs := make([]kafka.OffsetRequest, 0, 2*len(parts))
+	for _, p := range parts {
+		reqs = append(reqs, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	return a.listOffsets(ctx, reqs)
+}
+
+func (a *Admin) listOffsets(ctx context.Context, reqs []kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	res, err := a.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{a.topic: reqs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m := map[int]kafka.PartitionOffsets{}
+	for _, p := range res.Topics[a.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+		m[p.Partition] = p
+	}
+	return m, nil
+}
+
+// OffsetsAt возвращает для каждой партиции первый offset с временем сообщения не раньше t.
+// Если таких сообщений нет — конец партиции.
+func (a *Admin) OffsetsAt(ctx context.Context, t time.Time) ([]PartitionOffset, error) {
+	parts, err := a.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]kafka.OffsetRequest, 0, 2*len(parts))
+	for _, p := range parts {
+		reqs = append(reqs, kafka.TimeOffsetOf(p, t), kafka.LastOffsetOf(p))
+	}
+	res, err := a.listOffsets(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]PartitionOffset, len(parts))
+	for i, p := range parts {
+		po := PartitionOffset{Partition: p, Offset: res[p].LastOffset}
+		for off := range res[p].Offsets {
+			if off >= 0 {
+				po.Offset = off
+			}
+		}
+		offsets[i] = po
+	}
+	return offsets, nil
+}
+
+// CommitOffsets записывает offsets группы вне её сессии (generation -1).
+// Брокер принимает такой коммит, только если в группе нет активных участников.
+func (a *Admin) CommitOffsets(ctx context.Context, group string, offsets []PartitionOffset) error {
+	if len(offsets) == 0 {
+		return errors.New("no offsets to commit")
+	}
+	commits := make([]kafka.OffsetCommit, len(offsets))
+	for i, o := range offsets {
+		commits[i] = kafka.OffsetCommit{Partition: o.Partition, Offset: o.Offset}
+	}
+	res, err := a.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{a.topic: commits},
+	})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range res.Topics[a.topic] {
+		if p.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", p.Partition, p.Error))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("commit offsets of group %s (is it stopped?): %w", group, err)
+	}
+	return nil
+}
+
+// ReplayProgress — ход replay; End — offset, на котором чтение партиции остановится
+type ReplayProgress struct {
+	Partition int   `json:"partition"`
+	Start     int64 `json:"start_offset"`
+	End       int64 `json:"end_offset"`
+	Offset    int64 `json:"offset"` // следующий к чтению
+	Saved     int   `json:"saved"`
+	Skipped   int   `json:"skipped"`
+	Done      bool  `json:"done"`
+}
+
+// ReplayOptions — окно replay по времени сообщений Kafka.
+// Нулевой From — с начала партиции, нулевой To — до конца на момент старта.
+type ReplayOptions struct {
+	From, To time.Time
+	Codecs   Codecs
+	// OnSave вызывается после сохранения заказа (например, чтобы обновить кеш)
+	OnSave Handler
+}
+
+// Replay — разовое чтение окна топика без группы (offsets группы не меняются)
+// с записью заказов в target через SaveRaw. Хуки consumer (события, webhooks) не вызываются.
+type Replay struct {
+	admin  *Admin
+	target db.Repository
+	opts   ReplayOptions
+
+	mu       sync.Mutex
+	progress []ReplayProgress
+}
+
+// NewReplay определяет границы окна по партициям; чтение начинается в Run
+func (a *Admin) NewReplay(ctx context.Context, target db.Repository, opts ReplayOptions) (*Replay, error) {
+	if opts.Codecs == nil {
+		opts.Codecs = baseCodecs()
+	}
+	if !opts.To.IsZero() && !opts.From.IsZero() && !opts.From.Before(opts.To) {
+		return nil, errors.New("replay: from must be before to")
+	}
+	parts, err := a.Partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := a.bounds(ctx, parts)
+	if err != nil {
+		return nil, err
+	}
+	start := map[int]int64{}
+	end := map[int]int64{}
+	for _, p := range parts {
+		start[p], end[p] = bounds[p].FirstOffset, bounds[p].LastOffset
+	}
+	if !opts.From.IsZero() {
+		offs, err := a.OffsetsAt(ctx, opts.From)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range offs {
+			start[o.Partition] = o.Offset
+		}
+	}
+	if !opts.To.IsZero() {
+		offs, err := a.OffsetsAt(ctx, opts.To)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range offs {
+			end[o.Partition] = min(end[o.Partition], o.Offset)
+		}
+	}
+	r := &Replay{admin: a, target: target, opts: opts}
+	for _, p := range parts {
+		r.progress = append(r.progress, ReplayProgress{
+			Partition: p, Start: start[p], End: end[p], Offset: start[p], Done: start[p] >= end[p],
+		})
+	}
+	return r, nil
+}
+
+// Progress — снимок хода replay по партициям
+func (r *Replay) Progress() []ReplayProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReplayProgress(nil), r.progress...)
+}
+
+// Run читает партиции параллельно до конца окна
+func (r *Replay) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(r.progress))
+	for i, p := range r.Progress() {
+		if p.Done {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, p ReplayProgress) {
+			defer wg.Done()
+			if errs[i] = r.partition(ctx, i, p); errs[i] != nil {
+				errs[i] = fmt.Errorf("partition %d: %w", p.Partition, errs[i])
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (r *Replay) partition(ctx context.Context, i int, p ReplayProgress) error {
+	rd := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   r.admin.brokers,
+		Topic:     r.admin.topic,
+		Partition: p.Partition,
+		Dialer:    r.admin.sec.dialer(),
+	})
+	defer rd.Close()
+	if err := rd.SetOffset(p.Start); err != nil {
+		return err
+	}
+	for {
+		m, err := rd.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if m.Offset >= p.End {
+			r.update(i, func(pr *ReplayProgress) { pr.Done = true })
+			return nil
+		}
+		saved := r.save(ctx, m)
+		r.update(i, func(pr *ReplayProgress) {
+			pr.Offset = m.Offset + 1
+			if saved {
+				pr.Saved++
+			} else {
+				pr.Skipped++
+			}
+			pr.Done = pr.Offset >= pr.End
+		})
+		if m.Offset+1 >= p.End {
+			return nil
+		}
+	}
+}
+
+func (r *Replay) save(ctx context.Context, m kafka.Message) bool {
+	id, raw, err := r.opts.Codecs.decode(m)
+	if err != nil {
+		log.Printf("replay: skip message at %d/%d: %v", m.Partition, m.Offset, err)
+		return false
+	}
+	modified, err := r.target.SaveRaw(ctx, id, raw)
+	if err != nil {
+		log.Printf("replay: db save %s: %v", id, err)
+		return false
+	}
+	if r.opts.OnSave != nil {
+		r.opts.OnSave(id, raw, modified)
+	}
+	return true
+}
+
+func (r *Replay) update(i int, fn func(*ReplayProgress)) {
+	r.mu.Lock()
+	fn(&r.progress[i])
+	r.mu.Unlock()
+}
+
+// Watch запускает Run и вызывает fn с ходом replay каждые every и в конце
+func (r *Replay) Watch(ctx context.Context, every time.Duration, fn func([]ReplayProgress)) error {
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case err := <-done:
+			fn(r.Progress())
+			return err
+		case <-t.C:
+			fn(r.Progress())
+		}
+	}
+}
+
+// GroupAdmin — операции над группой работающего Consumer:
+// перемотка идёт через Consumer.Rewind, replay пишет в его хранилище
+type GroupAdmin struct {
+	admin  *Admin
+	c      *Consumer
+	onSave Handler
+}
+
+// GroupAdmin связывает Admin с consumer; onSave получает заказы, сохранённые replay
+func (c *Consumer) GroupAdmin(a *Admin, onSave Handler) *GroupAdmin {
+	return &GroupAdmin{admin: a, c: c, onSave: onSave}
+}
+
+func (g *GroupAdmin) Offsets(ctx context.Context) ([]PartitionState, error) {
+	return g.admin.GroupOffsets(ctx, g.c.cfg.GroupID)
+}
+
+func (g *GroupAdmin) OffsetsAt(ctx context.Context, t time.Time) ([]PartitionOffset, error) {
+	return g.admin.OffsetsAt(ctx, t)
+}
+
+func (g *GroupAdmin) Rewind(ctx context.Context, offsets []PartitionOffset) error {
+	return g.c.Rewind(ctx, g.admin, offsets)
+}
+
+// Replay читает окно [from, to) в хранилище consumer, сообщая ход каждую секунду
+func (g *GroupAdmin) Replay(ctx context.Context, from, to time.Time, progress func([]ReplayProgress)) error {
+	r, err := g.admin.NewReplay(ctx, g.c.db, ReplayOptions{From: from, To: to, Codecs: g.c.codecs, OnSave: g.onSave})
+	if err != nil {
+		return err
+	}
+	return r.Watch(ctx, time.Second, progress)
+}
@@ -0,0 +1,256 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"go-orders-demo/internal/models"
+)
+
+// FileRegistry — локальная замена Schema Registry: каталог с файлами <id>.avsc.
+// id схемы записывается в каждое сообщение, поэтому старые схемы удалять нельзя.
+type FileRegistry struct {
+	schemas map[int]*goavro.Codec
+	latest  int
+}
+
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.avsc"))
+	if err != nil {
+		return nil, err
+	}
+	r := &FileRegistry{schemas: make(map[int]*goavro.Codec)}
+	for _, f := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(f), ".avsc"))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("schema %s: file name must be a positive id", f)
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+		c, err := goavro.NewCodec(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("parse schema %s: %w", f, err)
+		}
+		r.schemas[id] = c
+		if id > r.latest {
+			r.latest = id
+		}
+	}
+	if len(r.schemas) == 0 {
+		return nil, fmt.Errorf("no *.avsc schemas in %s", dir)
+	}
+	return r, nil
+}
+
+// Schema возвращает схему по id
+func (r *FileRegistry) Schema(id int) (*goavro.Codec, bool) {
+	c, ok := r.schemas[id]
+	return c, ok
+}
+
+// Latest — наибольший id в реестре
+func (r *FileRegistry) Latest() int { return r.latest }
+
+// Формат сообщения как у Confluent: magic byte 0, id схемы (uint32 BE), Avro binary
+const (
+	avroMagic     = 0
+	avroHeaderLen = 5
+)
+
+// AvroCodec пишет заказы выбранной схемой и читает любой схемой из реестра
+type AvroCodec struct {
+	reg *FileRegistry
+	id  int
+}
+
+func NewAvroCodec(reg *FileRegistry, schemaID int) (*AvroCodec, error) {
+	if schemaID == 0 {
+		schemaID = reg.Latest()
+	}
+	if _, ok := reg.Schema(schemaID); !ok {
+		return nil, fmt.Errorf("avro: schema %d not found", schemaID)
+	}
+	return &AvroCodec{reg: reg, id: schemaID}, nil
+}
+
+func (c *AvroCodec) ContentType() string { return ContentTypeAvro }
+
+func (c *AvroCodec) Encode(o models.Order) ([]byte, error) {
+	schema, _ := c.reg.Schema(c.id)
+	buf := make([]byte, avroHeaderLen, 512)
+	buf[0] = avroMagic
+	binary.BigEndian.PutUint32(buf[1:], uint32(c.id))
+	return schema.BinaryFromNative(buf, orderToNative(o))
+}
+
+func (c *AvroCodec) Decode(data []byte) (models.Order, error) {
+	if len(data) < avroHeaderLen || data[0] != avroMagic {
+		return models.Order{}, errors.New("avro: missing schema header")
+	}
+	id := int(binary.BigEndian.Uint32(data[1:avroHeaderLen]))
+	schema, ok := c.reg.Schema(id)
+	if !ok {
+		return models.Order{}, fmt.Errorf("avro: unknown schema %d", id)
+	}
+	native, _, err := schema.NativeFromBinary(data[avroHeaderLen:])
+	if err != nil {
+		return models.Order{}, err
+	}
+	m, ok := native.(map[string]any)
+	if !ok {
+		return models.Order{}, fmt.Errorf("avro: schema %d is not a record", id)
+	}
+	return orderFromNative(m), nil
+}
+
+// orderToNative строит запись для goavro; поля, которых нет в схеме, goavro игнорирует
+func orderToNative(o models.Order) map[string]any {
+	items := make([]any, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, map[string]any{
+			"chrt_id":      int64(it.ChrtID),
+			"track_number": it.TrackNumber,
+			"price":        int64(it.Price),
+			"rid":          it.Rid,
+			"name":         it.Name,
+			"sale":         int64(it.Sale),
+			"size":         it.Size,
+			"total_price":  int64(it.TotalPrice),
+			"nm_id":        int64(it.NmID),
+			"brand":        it.Brand,
+			"status":       int64(it.Status),
+		})
+	}
+	// нулевое время кодируем как 0, иначе получится отрицательная метка года 1
+	var created any = int64(0)
+	if !o.DateCreated.IsZero() {
+		created = o.DateCreated
+	}
+	return map[string]any{
+		"order_uid":    o.OrderUID,
+		"track_number": o.TrackNumber,
+		"entry":        o.Entry,
+		"delivery": map[string]any{
+			"name":    o.Delivery.Name,
+			"phone":   o.Delivery.Phone,
+			"zip":     o.Delivery.Zip,
+			"city":    o.Delivery.City,
+			"address": o.Delivery.Address,
+			"region":  o.Delivery.Region,
+			"email":   o.Delivery.Email,
+		},
+		"payment": map[string]any{
+			"transaction":   o.Payment.Transaction,
+			"request_id":    o.Payment.RequestID,
+			"currency":      o.Payment.Currency,
+			"provider":      o.Payment.Provider,
+			"amount":        int64(o.Payment.Amount),
+			"payment_dt":    o.Payment.PaymentDT,
+			"bank":          o.Payment.Bank,
+			"delivery_cost": int64(o.Payment.DeliveryCost),
+			"goods_total":   int64(o.Payment.GoodsTotal),
+			"custom_fee":    int64(o.Payment.CustomFee),
+		},
+		"items":              items,
+		"locale":             o.Locale,
+		"internal_signature": o.InternalSignature,
+		"customer_id":        o.CustomerID,
+		"delivery_service":   o.DeliveryService,
+		"shardkey":           o.ShardKey,
+		"sm_id":              int64(o.SmID),
+		"date_created":       created,
+		"oof_shard":          o.OofShard,
+		"status":             o.Status,
+	}
+}
+
+// orderFromNative читает запись любой версии схемы: отсутствующие поля остаются нулевыми
+func orderFromNative(m map[string]any) models.Order {
+	o := models.Order{
+		OrderUID:          avroString(m, "order_uid"),
+		TrackNumber:       avroString(m, "track_number"),
+		Entry:             avroString(m, "entry"),
+		Locale:            avroString(m, "locale"),
+		InternalSignature: avroString(m, "internal_signature"),
+		CustomerID:        avroString(m, "customer_id"),
+		DeliveryService:   avroString(m, "delivery_service"),
+		ShardKey:          avroString(m, "shardkey"),
+		SmID:              int(avroInt(m, "sm_id")),
+		OofShard:          avroString(m, "oof_shard"),
+		Status:            avroString(m, "status"),
+	}
+	if t, ok := m["date_created"].(time.Time); ok && t.UnixMilli() != 0 {
+		o.DateCreated = t
+	}
+	if d, ok := m["delivery"].(map[string]any); ok {
+		o.Delivery = models.Delivery{
+			Name:    avroString(d, "name"),
+			Phone:   avroString(d, "phone"),
+			Zip:     avroString(d, "zip"),
+			City:    avroString(d, "city"),
+			Address: avroString(d, "address"),
+			Region:  avroString(d, "region"),
+			Email:   avroString(d, "email"),
+		}
+	}
+	if p, ok := m["payment"].(map[string]any); ok {
+		o.Payment = models.Payment{
+			Transaction:  avroString(p, "transaction"),
+			RequestID:    avroString(p, "request_id"),
+			Currency:     avroString(p, "currency"),
+			Provider:     avroString(p, "provider"),
+			Amount:       int(avroInt(p, "amount")),
+			PaymentDT:    avroInt(p, "payment_dt"),
+			Bank:         avroString(p, "bank"),
+			DeliveryCost: int(avroInt(p, "delivery_cost")),
+			GoodsTotal:   int(avroInt(p, "goods_total")),
+			CustomFee:    int(avroInt(p, "custom_fee")),
+		}
+	}
+	items, _ := m["items"].([]any)
+	for _, v := range items {
+		it, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		o.Items = append(o.Items, models.Item{
+			ChrtID:      int(avroInt(it, "chrt_id")),
+			TrackNumber: avroString(it, "track_number"),
+			Price:       int(avroInt(it, "price")),
+			Rid:         avroString(it, "rid"),
+			Name:        avroString(it, "name"),
+			Sale:        int(avroInt(it, "sale")),
+			Size:        avroString(it, "size"),
+			TotalPrice:  int(avroInt(it, "total_price")),
+			NmID:        int(avroInt(it, "nm_id")),
+			Brand:       avroString(it, "brand"),
+			Status:      int(avroInt(it, "status")),
+		})
+	}
+	return o
+}
+
+func avroString(m map[string]any, k string) string {
+	s, _ := m[k].(string)
+	return s
+}
+
+// avroInt принимает и int, и long: в старой схеме поле могло быть int
+func avroInt(m map[string]any, k string) int64 {
+	switch v := m[k].(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
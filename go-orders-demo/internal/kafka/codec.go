@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-orders-demo/internal/config"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/pb/ordersv1"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderContentType — заголовок сообщения с форматом payload.
+// Сообщения без заголовка считаются JSON (так писали старые версии).
+const HeaderContentType = "content-type"
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// Codec переводит заказ в payload сообщения и обратно
+type Codec interface {
+	ContentType() string
+	Encode(o models.Order) ([]byte, error)
+	Decode(data []byte) (models.Order, error)
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+func (JSONCodec) Encode(o models.Order) ([]byte, error) { return json.Marshal(o) }
+
+func (JSONCodec) Decode(data []byte) (models.Order, error) {
+	var o models.Order
+	err := json.Unmarshal(data, &o)
+	return o, err
+}
+
+// ProtobufCodec использует сообщение orders.v1.Order из proto/
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (ProtobufCodec) Encode(o models.Order) ([]byte, error) {
+	return proto.Marshal(ordersv1.FromModel(o))
+}
+
+func (ProtobufCodec) Decode(data []byte) (models.Order, error) {
+	var p ordersv1.Order
+	if err := proto.Unmarshal(data, &p); err != nil {
+		return models.Order{}, err
+	}
+	return p.ToModel(), nil
+}
+
+// NewCodec возвращает кодек для kafka.format.
+// Для avro нужен реестр схем; schemaID == 0 — последняя схема в реестре.
+func NewCodec(format string, reg *FileRegistry, schemaID int) (Codec, error) {
+	switch strings.ToLower(format) {
+	case "", config.FormatJSON:
+		return JSONCodec{}, nil
+	case config.FormatProtobuf:
+		return ProtobufCodec{}, nil
+	case config.FormatAvro:
+		if reg == nil {
+			return nil, fmt.Errorf("avro: schema registry required")
+		}
+		return NewAvroCodec(reg, schemaID)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Codecs — кодеки, которые умеет читать consumer, по content-type
+type Codecs map[string]Codec
+
+// NewCodecs собирает кодеки для чтения; Avro доступен, только если передан реестр
+func NewCodecs(reg *FileRegistry) Codecs {
+	cs := baseCodecs()
+	if reg != nil {
+		// при чтении id схемы берётся из сообщения, поэтому id кодека не важен
+		cs[ContentTypeAvro] = &AvroCodec{reg: reg, id: reg.Latest()}
+	}
+	return cs
+}
+
+// baseCodecs — форматы, не требующие реестра схем
+func baseCodecs() Codecs {
+	return Codecs{
+		ContentTypeJSON:     JSONCodec{},
+		ContentTypeProtobuf: ProtobufCodec{},
+	}
+}
+
+// Lookup находит кодек по значению заголовка (параметры вроде charset игнорируются)
+func (cs Codecs) Lookup(contentType string) (Codec, bool) {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	if ct == "" {
+		ct = ContentTypeJSON
+	}
+	c, ok := cs[ct]
+	return c, ok
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go-orders-demo/internal/models"
+)
+
+func sampleOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery:    models.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Address: "Ploshad Mira 15"},
+		Payment:     models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817, PaymentDT: 1637907727, DeliveryCost: 1500},
+		Items: []models.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Name: "Mascaras", Sale: 30, TotalPrice: 317, NmID: 2389212, Status: 202},
+		},
+		Locale:      "en",
+		CustomerID:  "test",
+		SmID:        99,
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Status:      "new",
+	}
+}
+
+func testRegistry(t *testing.T) *FileRegistry {
+	t.Helper()
+	reg, err := NewFileRegistry("../../schemas/avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	avro, err := NewAvroCodec(testRegistry(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Codec{JSONCodec{}, ProtobufCodec{}, avro} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			data, err := c.Encode(sampleOrder())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, sampleOrder()) {
+				t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, sampleOrder())
+			}
+		})
+	}
+}
+
+func TestAvroSchemaEvolution(t *testing.T) {
+	dir := t.TempDir()
+	v1, err := os.ReadFile("../../schemas/avro/1.avsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// вторая версия схемы знает только часть полей
+	v2 := `{"type":"record","name":"Order","namespace":"orders.v1","fields":[
+		{"name":"order_uid","type":"string"},
+		{"name":"track_number","type":"string","default":""},
+		{"name":"sm_id","type":"int","default":0}
+	]}`
+	os.WriteFile(filepath.Join(dir, "1.avsc"), v1, 0o644)
+	os.WriteFile(filepath.Join(dir, "2.avsc"), []byte(v2), 0o644)
+
+	reg, err := NewFileRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w1, _ := NewAvroCodec(reg, 1)
+	w2, _ := NewAvroCodec(reg, 0)
+	if w2.id != 2 {
+		t.Fatalf("expected latest schema 2, got %d", w2.id)
+	}
+
+	// читатель понимает сообщения обеих версий
+	reader := NewCodecs(reg)[ContentTypeAvro]
+	old, _ := w1.Encode(sampleOrder())
+	if o, err := reader.Decode(old); err != nil || o.Delivery.City != "Kiryat Mozkin" {
+		t.Fatalf("decode v1: %v %+v", err, o)
+	}
+	cur, _ := w2.Encode(sampleOrder())
+	o, err := reader.Decode(cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.OrderUID != "b563feb7b2b84b6test" || o.SmID != 99 || o.Delivery.City != "" {
+		t.Fatalf("decode v2: %+v", o)
+	}
+
+	if _, err := reader.Decode([]byte{0, 0, 0, 0, 9, 1}); err == nil {
+		t.Fatal("expected error for unknown schema id")
+	}
+}
+
+func TestConsumerDecodeByContentType(t *testing.T) {
+	c := &Consumer{codecs: NewCodecs(testRegistry(t))}
+
+	// без заголовка — JSON как есть, включая поля вне models.Order
+	id, raw, err := c.decode(kafka.Message{Value: []byte(`{"order_uid":"x","name":"Sample order"}`)})
+	if err != nil || id != "x" || string(raw) != `{"order_uid":"x","name":"Sample order"}` {
+		t.Fatalf("json: %q %s %v", id, raw, err)
+	}
+
+	for _, format := range []string{"protobuf", "avro"} {
+		codec, err := NewCodec(format, testRegistry(t), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &Producer{codec: codec}
+		src, _ := json.Marshal(sampleOrder())
+		m, err := p.message(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, raw, err := c.decode(m)
+		if err != nil || id != "b563feb7b2b84b6test" {
+			t.Fatalf("%s: %q %v", format, id, err)
+		}
+		var got models.Order
+		if err := json.Unmarshal(raw, &got); err != nil || !reflect.DeepEqual(got, sampleOrder()) {
+			t.Fatalf("%s: stored %s", format, raw)
+		}
+	}
+
+	_, _, err = c.decode(kafka.Message{
+		Value:   []byte("x"),
+		Headers: []kafka.Header{{Key: HeaderContentType, Value: []byte("text/plain")}},
+	})
+	if err == nil {
+		t.Fatal("expected error for unsupported content-type")
+	}
+}
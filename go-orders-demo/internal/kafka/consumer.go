@@ -3,54 +3,201 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
-	"go-orders-demo/internal/db"
 	"github.com/segmentio/kafka-go"
+	"go-orders-demo/internal/db"
 )
 
-type Handler func(id string, raw json.RawMessage)
+// Handler получает сохранённый заказ и его orders.updated_at
+type Handler func(id string, raw json.RawMessage, modified time.Time)
+
+// SaveHook вызывается после сохранения заказа.
+// prev — предыдущая версия из БД (nil, если заказ новый).
+type SaveHook func(ctx context.Context, id string, prev, cur json.RawMessage)
 
 type Consumer struct {
-	r *kafka.Reader
-	db *db.Store
-	h  Handler
+	cfg   kafka.ReaderConfig
+	mu    sync.Mutex // защищает r: Rewind пересоздаёт reader
+	r     *kafka.Reader
+	db    db.Repository
+	h     Handler
+	hooks []SaveHook
+	// codecs — форматы, которые умеет читать consumer (по заголовку content-type)
+	codecs Codecs
 }
 
-func NewConsumer(brokers, topic, group string, store db.Repository, h Handler) *Consumer {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{brokers},
+func NewConsumer(brokers []string, topic, group string, sec *Security, store db.Repository, h Handler) *Consumer {
+	cfg := kafka.ReaderConfig{
+		Brokers: brokers,
 		GroupID: group,
 		Topic:   topic,
-	})
-	return &Consumer{r: r, db: store, h: h}
+		Dialer:  sec.dialer(),
+	}
+	return &Consumer{cfg: cfg, r: kafka.NewReader(cfg), db: store, h: h, codecs: baseCodecs()}
 }
 
+// WithCodecs заменяет набор поддерживаемых форматов (например, чтобы добавить Avro)
+func (c *Consumer) WithCodecs(cs Codecs) *Consumer {
+	c.codecs = cs
+	return c
+}
+
+// Run читает сообщения до ошибки или отмены ctx.
+// Если reader заменён через Rewind, чтение продолжается новым reader.
 func (c *Consumer) Run(ctx context.Context) error {
 	for {
-		m, err := c.r.ReadMessage(ctx)
+		r := c.reader()
+		err := c.consume(ctx, r)
+		if ctx.Err() != nil || c.reader() == r {
+			return err
+		}
+	}
+}
+
+func (c *Consumer) reader() *kafka.Reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.r
+}
+
+// Rewind переводит группу consumer на offsets: закрывает reader (выходя из группы),
+// коммитит offsets и подключается заново. Другие участники группы должны быть
+// остановлены, иначе брокер отклонит коммит.
+func (c *Consumer) Rewind(ctx context.Context, admin *Admin, offsets []PartitionOffset) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.r.Close(); err != nil {
+		log.Printf("consumer close before rewind: %v", err)
+	}
+	err := admin.CommitOffsets(ctx, c.cfg.GroupID, offsets)
+	// reader пересоздаётся и при ошибке: группа продолжит с прежних offsets
+	c.r = kafka.NewReader(c.cfg)
+	return err
+}
+
+func (c *Consumer) consume(ctx context.Context, r *kafka.Reader) error {
+	for {
+		m, err := r.ReadMessage(ctx)
 		if err != nil {
 			return err
 		}
+		id, raw, err := c.decode(m)
+		if err != nil {
+			log.Printf("skip message at offset %d: %v", m.Offset, err)
+			continue
+		}
+		if err := c.save(ctx, id, raw); err != nil {
+			return err
+		}
+	}
+}
+
+// Паузы между повторами чтения предыдущей версии заказа
+var (
+	prevMinBackoff = 200 * time.Millisecond
+	prevMaxBackoff = 10 * time.Second
+)
+
+// save сохраняет заказ и вызывает обработчик и хуки. Ошибку возвращает только
+// при отмене ctx: ошибка записи в БД пропускает сообщение, как и раньше.
+func (c *Consumer) save(ctx context.Context, id string, raw json.RawMessage) error {
+	var prev json.RawMessage
+	if len(c.hooks) > 0 {
+		var err error
+		if prev, err = c.previous(ctx, id); err != nil {
+			return err
+		}
+	}
+	modified, err := c.db.SaveRaw(ctx, id, raw)
+	if err != nil {
+		log.Printf("db save: %v", err)
+		return nil
+	}
+	if c.h != nil {
+		c.h(id, raw, modified)
+	}
+	for _, hook := range c.hooks {
+		hook(ctx, id, prev, raw)
+	}
+	return nil
+}
+
+// previous читает сохранённую версию заказа (nil — заказа ещё нет). Сбой БД
+// повторяется до успеха: с prev == nil хуки сочли бы заказ новым и выпустили
+// лишний OrderCreated.
+func (c *Consumer) previous(ctx context.Context, id string) (json.RawMessage, error) {
+	wait := prevMinBackoff
+	for {
+		prev, err := c.db.GetRaw(ctx, id)
+		if err == nil || errors.Is(err, db.ErrNotFound) {
+			return prev, nil
+		}
+		log.Printf("db get previous %s: %v, retry in %s", id, err, wait)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		if wait *= 2; wait > prevMaxBackoff {
+			wait = prevMaxBackoff
+		}
+	}
+}
+
+func (c *Consumer) decode(m kafka.Message) (string, json.RawMessage, error) {
+	return c.codecs.decode(m)
+}
+
+// decode выбирает кодек по заголовку content-type и возвращает заказ в JSON,
+// в котором он хранится в БД и кеше
+func (cs Codecs) decode(m kafka.Message) (string, json.RawMessage, error) {
+	var ct string
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Key, HeaderContentType) {
+			ct = string(h.Value)
+		}
+	}
+	codec, ok := cs.Lookup(ct)
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported content-type %q", ct)
+	}
+
+	// JSON сохраняем как пришёл: в нём могут быть поля, которых нет в models.Order
+	if codec.ContentType() == ContentTypeJSON {
 		var tmp map[string]any
 		if err := json.Unmarshal(m.Value, &tmp); err != nil {
-			log.Printf("skip invalid json: %v", err)
-			continue
+			return "", nil, fmt.Errorf("invalid json: %w", err)
 		}
 		id, _ := tmp["order_uid"].(string)
 		if id == "" {
-			log.Printf("skip without order_uid")
-			continue
-		}
-		if err := c.db.Save(ctx, id, json.RawMessage(m.Value)); err != nil {
-			log.Printf("db save: %v", err)
-			continue
-		}
-		if c.h != nil {
-			c.h(id, json.RawMessage(m.Value))
+			return "", nil, errors.New("without order_uid")
 		}
+		return id, json.RawMessage(m.Value), nil
+	}
+
+	o, err := codec.Decode(m.Value)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %s: %w", codec.ContentType(), err)
+	}
+	if o.OrderUID == "" {
+		return "", nil, errors.New("without order_uid")
+	}
+	raw, err := json.Marshal(o)
+	if err != nil {
+		return "", nil, err
 	}
+	return o.OrderUID, raw, nil
 }
 
+// OnSave регистрирует хук, вызываемый после каждого сохранённого заказа
+func (c *Consumer) OnSave(h SaveHook) { c.hooks = append(c.hooks, h) }
 
-func (c *Consumer) Close() error { return c.r.Close() }
+func (c *Consumer) Close() error { return c.reader().Close() }
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+)
+
+// flakyRepo отвечает на GetRaw ошибкой failures раз, затем отдаёт сохранённое
+type flakyRepo struct {
+	failures int
+	gets     int
+	saved    map[string]json.RawMessage
+}
+
+func (r *flakyRepo) SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error) {
+	r.saved[id] = raw
+	return time.Time{}, nil
+}
+func (r *flakyRepo) GetRaw(ctx context.Context, id string) (json.RawMessage, error) {
+	r.gets++
+	if r.gets <= r.failures {
+		return nil, errors.New("connection refused")
+	}
+	if raw, ok := r.saved[id]; ok {
+		return raw, nil
+	}
+	return nil, db.ErrNotFound
+}
+func (r *flakyRepo) GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error) {
+	raw, err := r.GetRaw(ctx, id)
+	return raw, time.Time{}, err
+}
+func (r *flakyRepo) LoadAllRaw(ctx context.Context, limit int) (map[string]db.RawOrder, error) {
+	return nil, nil
+}
+func (r *flakyRepo) SaveOrder(ctx context.Context, o models.Order) error { return nil }
+
+func TestSaveRetriesPreviousLookup(t *testing.T) {
+	defer func(min, max time.Duration) { prevMinBackoff, prevMaxBackoff = min, max }(prevMinBackoff, prevMaxBackoff)
+	prevMinBackoff, prevMaxBackoff = time.Millisecond, 2*time.Millisecond
+
+	repo := &flakyRepo{failures: 3, saved: map[string]json.RawMessage{"a": json.RawMessage(`{"v":1}`)}}
+	c := &Consumer{db: repo}
+	var gotPrev json.RawMessage
+	c.OnSave(func(ctx context.Context, id string, prev, cur json.RawMessage) { gotPrev = prev })
+
+	if err := c.save(context.Background(), "a", json.RawMessage(`{"v":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if string(gotPrev) != `{"v":1}` || repo.gets != 4 {
+		t.Fatalf("prev = %s after %d lookups; want {\"v\":1} after 4", gotPrev, repo.gets)
+	}
+
+	// БД не отвечает: без prev заказ не сохраняется и хуки не вызываются
+	repo.failures, repo.gets = 1<<30, 0
+	gotPrev = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.save(ctx, "a", json.RawMessage(`{"v":3}`)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if gotPrev != nil || string(repo.saved["a"]) != `{"v":2}` {
+		t.Fatalf("order saved or hook fired without previous version: %s", repo.saved["a"])
+	}
+}
@@ -2,24 +2,118 @@ package kafka
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go-orders-demo/internal/models"
 )
 
 type Producer struct {
 	w *kafka.Writer
+	// codec — формат заказов в топике; nil — payload уходит как есть (JSON)
+	codec Codec
 }
 
-func NewProducer(brokers, topic string) *Producer {
+func NewProducer(brokers []string, topic string, sec *Security) *Producer {
 	return &Producer{w: &kafka.Writer{
-		Addr:         kafka.TCP(brokers),
+		Addr:         kafka.TCP(brokers...),
 		Topic:        topic,
 		RequiredAcks: kafka.RequireOne,
+		Transport:    sec.transport(),
+		// одинаковый ключ -> одна партиция -> порядок сообщений сохраняется
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
 	}}
 }
 
+// WithCodec включает перекодирование: Produce/ProduceKeyed получают JSON заказа,
+// а в топик пишут формат кодека с заголовком content-type
+func (p *Producer) WithCodec(c Codec) *Producer {
+	p.codec = c
+	return p
+}
+
 func (p *Producer) Produce(ctx context.Context, payload []byte) error {
-	return p.w.WriteMessages(ctx, kafka.Message{Value: payload})
+	m, err := p.message(payload)
+	if err != nil {
+		return err
+	}
+	return p.w.WriteMessages(ctx, m)
+}
+
+// ProduceKeyed публикует сообщение с ключом партиционирования
+func (p *Producer) ProduceKeyed(ctx context.Context, key string, payload []byte) error {
+	m, err := p.message(payload)
+	if err != nil {
+		return err
+	}
+	m.Key = []byte(key)
+	return p.w.WriteMessages(ctx, m)
+}
+
+// Payload — одно сообщение пакета: ключ партиционирования и JSON заказа
+type Payload struct {
+	Key   string
+	Value []byte
+}
+
+// ProduceBatch публикует пакет одним вызовом WriteMessages.
+// Возвращает ошибку для каждого сообщения (nil — записано) в порядке batch.
+func (p *Producer) ProduceBatch(ctx context.Context, batch []Payload) []error {
+	errs := make([]error, len(batch))
+	msgs := make([]kafka.Message, 0, len(batch))
+	idx := make([]int, 0, len(batch)) // позиция сообщения в batch
+	for i, pl := range batch {
+		m, err := p.message(pl.Value)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		m.Key = []byte(pl.Key)
+		msgs = append(msgs, m)
+		idx = append(idx, i)
+	}
+	if len(msgs) == 0 {
+		return errs
+	}
+	err := p.w.WriteMessages(ctx, msgs...)
+	var werrs kafka.WriteErrors
+	switch {
+	case err == nil:
+	case errors.As(err, &werrs) && len(werrs) == len(msgs):
+		for j, e := range werrs {
+			errs[idx[j]] = e
+		}
+	default:
+		for _, i := range idx {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (p *Producer) message(payload []byte) (kafka.Message, error) {
+	if p.codec == nil || p.codec.ContentType() == ContentTypeJSON {
+		return kafka.Message{
+			Value:   payload,
+			Headers: []kafka.Header{{Key: HeaderContentType, Value: []byte(ContentTypeJSON)}},
+		}, nil
+	}
+	var o models.Order
+	if err := json.Unmarshal(payload, &o); err != nil {
+		return kafka.Message{}, fmt.Errorf("decode order: %w", err)
+	}
+	data, err := p.codec.Encode(o)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("encode %s: %w", p.codec.ContentType(), err)
+	}
+	return kafka.Message{
+		Value:   data,
+		Headers: []kafka.Header{{Key: HeaderContentType, Value: []byte(p.codec.ContentType())}},
+	}, nil
 }
 
-func (p *Producer) Close() error { return p.w.Close() }
\ No newline at end of file
+func (p *Producer) Close() error { return p.w.Close() }
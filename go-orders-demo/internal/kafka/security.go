@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"go-orders-demo/internal/config"
+)
+
+// Security — параметры защищённого подключения к брокерам.
+// Нулевое значение (или nil) означает PLAINTEXT без аутентификации.
+type Security struct {
+	TLS  *tls.Config
+	SASL sasl.Mechanism
+}
+
+// NewSecurity собирает TLS и SASL настройки из конфигурации приложения
+func NewSecurity(c config.KafkaConfig) (*Security, error) {
+	sec := &Security{}
+	if c.TLS.Enabled {
+		t, err := newTLSConfig(c.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("kafka tls: %w", err)
+		}
+		sec.TLS = t
+	}
+	m, err := newSASLMechanism(c.SASL)
+	if err != nil {
+		return nil, fmt.Errorf("kafka sasl: %w", err)
+	}
+	sec.SASL = m
+	return sec, nil
+}
+
+func newTLSConfig(c config.KafkaTLSConfig) (*tls.Config, error) {
+	t := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ca file contains no certificates")
+		}
+		t.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client cert: %w", err)
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return t, nil
+}
+
+func newSASLMechanism(c config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch c.Mechanism {
+	case "":
+		return nil, nil
+	case config.SASLPlain:
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case config.SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case config.SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	default:
+		return nil, fmt.Errorf("unsupported mechanism %q", c.Mechanism)
+	}
+}
+
+// dialer — для kafka.Reader
+func (s *Security) dialer() *kafka.Dialer {
+	d := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+	if s != nil {
+		d.TLS = s.TLS
+		d.SASLMechanism = s.SASL
+	}
+	return d
+}
+
+// transport — для kafka.Writer
+func (s *Security) transport() *kafka.Transport {
+	t := &kafka.Transport{}
+	if s != nil {
+		t.TLS = s.TLS
+		t.SASL = s.SASL
+	}
+	return t
+}
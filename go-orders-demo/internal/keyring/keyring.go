@@ -0,0 +1,228 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Prefix помечает зашифрованное значение: enc:v1:<key id>:<base64url>
+const Prefix = "enc:v1:"
+
+const (
+	keySize   = 32 // AES-256
+	nonceSize = 12
+	// wrappedSize — DEK, зашифрованный ключом кольца: 32 байта + тег GCM
+	wrappedSize = keySize + 16
+)
+
+var ErrUnknownKey = errors.New("unknown key id")
+
+// KeyRing — локальное кольцо ключей шифрования (KEK) с идентификаторами.
+// Каждое значение шифруется своим случайным ключом данных (DEK), а DEK —
+// основным ключом кольца (envelope encryption). Старые ключи остаются в кольце
+// для расшифровки, пока данные не перешифрованы основным.
+type KeyRing struct {
+	primary string
+	keys    map[string]cipher.AEAD
+	index   []byte // ключ HMAC для blind index
+}
+
+// File — формат файла кольца; ключи — 32 байта в base64
+type File struct {
+	Primary  string            `json:"primary"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// Load читает кольцо из JSON файла
+func Load(path string) (*KeyRing, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, v := range f.Keys {
+		if keys[id], err = base64.StdEncoding.DecodeString(v); err != nil {
+			return nil, fmt.Errorf("keyring %s: key %q: %w", path, id, err)
+		}
+	}
+	index, err := base64.StdEncoding.DecodeString(f.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: index_key: %w", path, err)
+	}
+	kr, err := New(f.Primary, keys, index)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return kr, nil
+}
+
+// New собирает кольцо; primary — ключ, которым шифруются новые значения
+func New(primary string, keys map[string][]byte, indexKey []byte) (*KeyRing, error) {
+	kr := &KeyRing{primary: primary, keys: map[string]cipher.AEAD{}, index: indexKey}
+	for id, k := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q: must be non-empty and without ':'", id)
+		}
+		if len(k) != keySize {
+			return nil, fmt.Errorf("key %q: want %d bytes, got %d", id, keySize, len(k))
+		}
+		aead, err := newAEAD(k)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[id] = aead
+	}
+	if _, ok := kr.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the ring", primary)
+	}
+	if len(indexKey) < keySize {
+		return nil, fmt.Errorf("index_key: want at least %d bytes", keySize)
+	}
+	return kr, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *KeyRing) Primary() string { return k.primary }
+
+// IDs — идентификаторы ключей кольца по алфавиту
+func (k *KeyRing) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Seal шифрует значение основным ключом. aad привязывает шифртекст к месту
+// хранения (например, order_uid и полю): перенесённое в другое место значение не расшифруется.
+func (k *KeyRing) Seal(plaintext, aad string) (string, error) {
+	dek := make([]byte, keySize)
+	buf := make([]byte, nonceSize, 2*nonceSize+wrappedSize+len(plaintext)+16)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(buf[:nonceSize]); err != nil {
+		return "", err
+	}
+	buf = k.keys[k.primary].Seal(buf, buf[:nonceSize], dek, []byte(k.primary))
+
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	buf = append(buf, nonce...)
+	buf = data.Seal(buf, nonce, []byte(plaintext), []byte(aad))
+	return Prefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Open расшифровывает значение из Seal; незашифрованное значение возвращается как есть
+func (k *KeyRing) Open(value, aad string) (string, error) {
+	id, ok := KeyID(value)
+	if !ok {
+		return value, nil
+	}
+	kek, found := k.keys[id]
+	if !found {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(value[len(Prefix)+len(id)+1:])
+	if err != nil || len(buf) < 2*nonceSize+wrappedSize+16 {
+		return "", errors.New("keyring: malformed ciphertext")
+	}
+	dek, err := kek.Open(nil, buf[:nonceSize], buf[nonceSize:nonceSize+wrappedSize], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("keyring: unwrap data key: %w", err)
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	rest := buf[nonceSize+wrappedSize:]
+	plain, err := data.Open(nil, rest[:nonceSize], rest[nonceSize:], []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("keyring: decrypt: %w", err)
+	}
+	return string(plain), nil
+}
+
+// KeyID возвращает идентификатор ключа зашифрованного значения
+func KeyID(value string) (string, bool) {
+	if !strings.HasPrefix(value, Prefix) {
+		return "", false
+	}
+	id, _, ok := strings.Cut(value[len(Prefix):], ":")
+	return id, ok
+}
+
+// NeedsReseal — значение не зашифровано или зашифровано не основным ключом
+func (k *KeyRing) NeedsReseal(value string) bool {
+	if value == "" {
+		return false
+	}
+	id, ok := KeyID(value)
+	return !ok || id != k.primary
+}
+
+// Виды blind index
+const (
+	IndexPhone = "phone"
+	IndexEmail = "email"
+)
+
+// BlindIndex — HMAC нормализованного значения: позволяет искать по равенству,
+// не храня значение открытым. Для пустого значения — nil.
+func (k *KeyRing) BlindIndex(kind, value string) []byte {
+	value = Normalize(kind, value)
+	if value == "" {
+		return nil
+	}
+	m := hmac.New(sha256.New, k.index)
+	m.Write([]byte(kind + ":" + value))
+	return m.Sum(nil)
+}
+
+// Normalize приводит телефон к цифрам, e-mail — к нижнему регистру,
+// чтобы "+7 (999) 123-45-67" и "79991234567" давали один индекс
+func Normalize(kind, value string) string {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case IndexPhone:
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+	case IndexEmail:
+		return strings.ToLower(value)
+	}
+	return value
+}
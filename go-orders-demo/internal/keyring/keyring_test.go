@@ -0,0 +1,112 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func key(b byte) []byte { return bytes.Repeat([]byte{b}, keySize) }
+
+func TestSealOpen(t *testing.T) {
+	old, err := New("k1", map[string][]byte{"k1": key(1)}, key(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal("+79991234567", "a/delivery.phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := KeyID(sealed); !ok || id != "k1" || strings.Contains(sealed, "7999") {
+		t.Fatalf("unexpected token %q", sealed)
+	}
+	again, _ := old.Seal("+79991234567", "a/delivery.phone")
+	if again == sealed {
+		t.Fatal("same plaintext must give different ciphertext")
+	}
+	if _, err := old.Open(sealed, "b/delivery.phone"); err == nil {
+		t.Fatal("ciphertext moved to another order must not open")
+	}
+
+	// ротация: новый основной ключ, старый остаётся для чтения
+	rotated, err := New("k2", map[string][]byte{"k1": key(1), "k2": key(2)}, key(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := rotated.Open(sealed, "a/delivery.phone"); err != nil || v != "+79991234567" {
+		t.Fatalf("open with old key: %q %v", v, err)
+	}
+	if !rotated.NeedsReseal(sealed) || rotated.NeedsReseal("") || !rotated.NeedsReseal("plain") {
+		t.Fatal("NeedsReseal")
+	}
+	resealed, _ := rotated.Seal("+79991234567", "a/delivery.phone")
+	if rotated.NeedsReseal(resealed) {
+		t.Fatal("value under primary key needs no reseal")
+	}
+	if _, err := old.Open(resealed, "a/delivery.phone"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	// незашифрованное значение читается как есть
+	if v, err := rotated.Open("Иван", "a/delivery.name"); err != nil || v != "Иван" {
+		t.Fatalf("plaintext passthrough: %q %v", v, err)
+	}
+	if _, err := rotated.Open(Prefix+"k2:!!", "x"); err == nil {
+		t.Fatal("expected error for malformed ciphertext")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	kr, _ := New("k1", map[string][]byte{"k1": key(1)}, key(9))
+	if !bytes.Equal(kr.BlindIndex(IndexPhone, "+7 (999) 123-45-67"), kr.BlindIndex(IndexPhone, "79991234567")) {
+		t.Fatal("phone index must ignore formatting")
+	}
+	if !bytes.Equal(kr.BlindIndex(IndexEmail, " Anna@Mail.ru"), kr.BlindIndex(IndexEmail, "anna@mail.ru")) {
+		t.Fatal("email index must ignore case")
+	}
+	if bytes.Equal(kr.BlindIndex(IndexPhone, "123"), kr.BlindIndex(IndexEmail, "123")) {
+		t.Fatal("index kinds must not collide")
+	}
+	if kr.BlindIndex(IndexPhone, "нет") != nil {
+		t.Fatal("empty value must have no index")
+	}
+	other, _ := New("k1", map[string][]byte{"k1": key(1)}, key(8))
+	if bytes.Equal(kr.BlindIndex(IndexEmail, "a@b.c"), other.BlindIndex(IndexEmail, "a@b.c")) {
+		t.Fatal("index depends on index_key")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	data := `{"primary":"2024-06","keys":{"2024-01":"` + b64(key(1)) + `","2024-06":"` + b64(key(2)) + `"},"index_key":"` + b64(key(9)) + `"}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kr.Primary() != "2024-06" || strings.Join(kr.IDs(), ",") != "2024-01,2024-06" {
+		t.Fatalf("loaded %s %v", kr.Primary(), kr.IDs())
+	}
+
+	for name, bad := range map[string]struct {
+		primary string
+		keys    map[string][]byte
+		index   []byte
+	}{
+		"missing primary": {"k2", map[string][]byte{"k1": key(1)}, key(9)},
+		"short key":       {"k1", map[string][]byte{"k1": key(1)[:16]}, key(9)},
+		"colon in id":     {"k:1", map[string][]byte{"k:1": key(1)}, key(9)},
+		"short index key": {"k1", map[string][]byte{"k1": key(1)}, key(9)[:8]},
+	} {
+		if _, err := New(bad.primary, bad.keys, bad.index); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
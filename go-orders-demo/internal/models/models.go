@@ -17,8 +17,13 @@ type Order struct {
     SmID              int       `json:"sm_id"`
     DateCreated       time.Time `json:"date_created"`
     OofShard          string    `json:"oof_shard"`
+    // Status — статус заказа целиком (например, StatusCancelled); может отсутствовать
+    Status string `json:"status,omitempty"`
 }
 
+// StatusCancelled — статус отменённого заказа
+const StatusCancelled = "cancelled"
+
 type Delivery struct {
     Name    string `json:"name"`
     Phone   string `json:"phone"`
@@ -54,4 +59,4 @@ type Item struct {
     NmID        int    `json:"nm_id"`
     Brand       string `json:"brand"`
     Status      int    `json:"status"`
-}
\ No newline at end of file
+}
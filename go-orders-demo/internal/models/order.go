@@ -1,5 +0,0 @@
-package models
-
-type Order struct {
-	OrderUID string `json:"order_uid"`
-}
\ No newline at end of file
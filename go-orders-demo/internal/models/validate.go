@@ -0,0 +1,21 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Validate — базовая проверка обязательных полей заказа перед публикацией
+func (o Order) Validate() error {
+	switch {
+	case strings.TrimSpace(o.OrderUID) == "":
+		return errors.New("missing field: order_uid")
+	case strings.TrimSpace(o.TrackNumber) == "":
+		return errors.New("missing field: track_number")
+	case o.Payment.Transaction == "":
+		return errors.New("missing field: payment.transaction")
+	case o.Delivery.Name == "" || o.Delivery.Address == "":
+		return errors.New("missing delivery information")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-orders-demo/internal/db"
+)
+
+// Store — хранилище outbox (реализуется db.SQLStore)
+type Store interface {
+	ProcessOutbox(ctx context.Context, limit int, fn func([]db.OutboxRecord) []db.OutboxResult) (int, error)
+	CleanupOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher публикует сообщение в Kafka с ключом order_uid
+type Publisher interface {
+	ProduceKeyed(ctx context.Context, key string, payload []byte) error
+}
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Retention — сколько хранить доставленные записи до удаления
+	Retention  time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Relay периодически переносит записи из outbox в Kafka.
+// Записи одного order_uid публикуются строго по порядку: если запись
+// не доставлена, следующие записи этого заказа ждут её повтора.
+type Relay struct {
+	store Store
+	pub   Publisher
+	opts  Options
+	now   func() time.Time
+}
+
+func NewRelay(store Store, pub Publisher, opts Options) *Relay {
+	return &Relay{store: store, pub: pub, opts: opts, now: time.Now}
+}
+
+// Run работает до отмены ctx
+func (r *Relay) Run(ctx context.Context) error {
+	tick := time.NewTicker(r.opts.PollInterval)
+	defer tick.Stop()
+	lastCleanup := r.now()
+	for {
+		for {
+			n, err := r.store.ProcessOutbox(ctx, r.opts.BatchSize, func(recs []db.OutboxRecord) []db.OutboxResult {
+				return r.publish(ctx, recs)
+			})
+			if err != nil {
+				log.Printf("outbox: %v", err)
+			}
+			// полная пачка — скорее всего есть ещё, не ждём тика
+			if err != nil || n < r.opts.BatchSize {
+				break
+			}
+		}
+
+		if r.now().Sub(lastCleanup) >= r.opts.Retention {
+			if n, err := r.store.CleanupOutbox(ctx, r.now().Add(-r.opts.Retention)); err != nil {
+				log.Printf("outbox cleanup: %v", err)
+			} else if n > 0 {
+				log.Printf("outbox cleanup: removed %d delivered rows", n)
+			}
+			lastCleanup = r.now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, recs []db.OutboxRecord) []db.OutboxResult {
+	now := r.now()
+	blocked := make(map[string]bool)
+	var res []db.OutboxResult
+	for _, rec := range recs {
+		if blocked[rec.OrderUID] {
+			continue
+		}
+		if rec.NextAttemptAt.After(now) {
+			blocked[rec.OrderUID] = true
+			continue
+		}
+		if err := r.pub.ProduceKeyed(ctx, rec.OrderUID, rec.Payload); err != nil {
+			blocked[rec.OrderUID] = true
+			next := now.Add(r.backoff(rec.Attempts))
+			log.Printf("outbox: publish %s (id=%d, attempt %d): %v, retry at %s",
+				rec.OrderUID, rec.ID, rec.Attempts+1, err, next.Format(time.RFC3339))
+			res = append(res, db.OutboxResult{ID: rec.ID, Err: err, NextAttempt: next})
+			continue
+		}
+		res = append(res, db.OutboxResult{ID: rec.ID})
+	}
+	return res
+}
+
+// backoff — экспоненциальная задержка перед повтором
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.MinBackoff
+	for i := 0; i < attempts && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.opts.MaxBackoff {
+		d = r.opts.MaxBackoff
+	}
+	return d
+}
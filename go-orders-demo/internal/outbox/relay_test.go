@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/db"
+)
+
+type fakePublisher struct {
+	fail map[string]bool
+	sent []string
+}
+
+func (p *fakePublisher) ProduceKeyed(ctx context.Context, key string, payload []byte) error {
+	if p.fail[key] {
+		return errors.New("broker down")
+	}
+	p.sent = append(p.sent, string(payload))
+	return nil
+}
+
+func TestPublishKeepsPerOrderSequence(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pub := &fakePublisher{fail: map[string]bool{"b": true}}
+	r := NewRelay(nil, pub, Options{MinBackoff: time.Second, MaxBackoff: time.Minute})
+	r.now = func() time.Time { return now }
+
+	recs := []db.OutboxRecord{
+		{ID: 1, OrderUID: "a", Payload: []byte("a1")},
+		{ID: 2, OrderUID: "b", Payload: []byte("b1"), Attempts: 2},
+		{ID: 3, OrderUID: "c", Payload: []byte("c1"), NextAttemptAt: now.Add(time.Minute)},
+		{ID: 4, OrderUID: "b", Payload: []byte("b2")},
+		{ID: 5, OrderUID: "c", Payload: []byte("c2")},
+		{ID: 6, OrderUID: "a", Payload: []byte("a2")},
+	}
+	res := r.publish(context.Background(), recs)
+
+	if got := pub.sent; len(got) != 2 || got[0] != "a1" || got[1] != "a2" {
+		t.Fatalf("sent = %v, want [a1 a2]", got)
+	}
+	if len(res) != 3 {
+		t.Fatalf("results = %+v, want 3 entries", res)
+	}
+	if res[1].ID != 2 || res[1].Err == nil {
+		t.Fatalf("expected failure for id=2, got %+v", res[1])
+	}
+	if want := now.Add(4 * time.Second); !res[1].NextAttempt.Equal(want) {
+		t.Errorf("next attempt = %v, want %v", res[1].NextAttempt, want)
+	}
+}
+
+func TestBackoffCapped(t *testing.T) {
+	r := NewRelay(nil, nil, Options{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := r.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
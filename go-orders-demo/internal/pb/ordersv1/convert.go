@@ -0,0 +1,124 @@
+package ordersv1
+
+import (
+	"go-orders-demo/internal/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromModel конвертирует models.Order в protobuf сообщение
+func FromModel(o models.Order) *Order {
+	p := &Order{
+		OrderUid:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmID),
+		OofShard:          o.OofShard,
+		Status:            o.Status,
+		Delivery: &Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+	}
+	if !o.DateCreated.IsZero() {
+		p.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	for _, it := range o.Items {
+		p.Items = append(p.Items, &Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return p
+}
+
+// ToModel конвертирует protobuf сообщение в models.Order
+func (p *Order) ToModel() models.Order {
+	o := models.Order{
+		OrderUID:          p.GetOrderUid(),
+		TrackNumber:       p.GetTrackNumber(),
+		Entry:             p.GetEntry(),
+		Locale:            p.GetLocale(),
+		InternalSignature: p.GetInternalSignature(),
+		CustomerID:        p.GetCustomerId(),
+		DeliveryService:   p.GetDeliveryService(),
+		ShardKey:          p.GetShardkey(),
+		SmID:              int(p.GetSmId()),
+		OofShard:          p.GetOofShard(),
+		Status:            p.GetStatus(),
+	}
+	if d := p.GetDelivery(); d != nil {
+		o.Delivery = models.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+	if pm := p.GetPayment(); pm != nil {
+		o.Payment = models.Payment{
+			Transaction:  pm.GetTransaction(),
+			RequestID:    pm.GetRequestId(),
+			Currency:     pm.GetCurrency(),
+			Provider:     pm.GetProvider(),
+			Amount:       int(pm.GetAmount()),
+			PaymentDT:    pm.GetPaymentDt(),
+			Bank:         pm.GetBank(),
+			DeliveryCost: int(pm.GetDeliveryCost()),
+			GoodsTotal:   int(pm.GetGoodsTotal()),
+			CustomFee:    int(pm.GetCustomFee()),
+		}
+	}
+	if ts := p.GetDateCreated(); ts != nil {
+		o.DateCreated = ts.AsTime()
+	}
+	for _, it := range p.GetItems() {
+		o.Items = append(o.Items, models.Item{
+			ChrtID:      int(it.GetChrtId()),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			Rid:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        int(it.GetNmId()),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	return o
+}
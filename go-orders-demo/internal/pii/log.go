@@ -0,0 +1,31 @@
+package pii
+
+import (
+	"io"
+	"regexp"
+)
+
+var (
+	emailRe = regexp.MustCompile(`[\p{L}0-9._%+\-]+@[\p{L}0-9.\-]+\.\p{L}{2,}`)
+	phoneRe = regexp.MustCompile(`\+\d[\d\- ()]{8,16}\d`)
+)
+
+// Redact убирает из текста e-mail и телефоны в международном формате.
+// Имена и адреса по тексту не распознать — поэтому заказы целиком в лог не пишутся.
+func Redact(s string) string {
+	s = emailRe.ReplaceAllStringFunc(s, func(m string) string { return Value(m, MaskEmail) })
+	return phoneRe.ReplaceAllStringFunc(s, func(m string) string { return Value(m, MaskPhone) })
+}
+
+// LogWriter — io.Writer для log.SetOutput, который пропускает строки через Redact
+func LogWriter(w io.Writer) io.Writer { return logWriter{w} }
+
+type logWriter struct{ w io.Writer }
+
+// Write: log пишет запись одним вызовом, поэтому строка не разрывается между вызовами
+func (l logWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(l.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
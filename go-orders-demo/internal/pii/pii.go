@@ -0,0 +1,196 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go-orders-demo/internal/auth"
+)
+
+// Способы маскирования
+const (
+	MaskPhone   = "phone"   // +79991234567 -> +7***4567
+	MaskEmail   = "email"   // anna@mail.ru -> a***@mail.ru
+	MaskName    = "name"    // Иван Петров -> И*** П***
+	MaskPartial = "partial" // Москва -> М***а
+	MaskRedact  = "redact"  // -> ***
+)
+
+const stars = "***"
+
+// DefaultRules — персональные данные получателя в models.Delivery
+var DefaultRules = []string{
+	"delivery.name=name",
+	"delivery.phone=phone",
+	"delivery.email=email",
+	"delivery.address=redact",
+}
+
+// Rule — поле документа заказа (путь через точку, массивы проходятся поэлементно) и способ маскирования
+type Rule struct {
+	Path []string
+	Mask string
+}
+
+// ParseRules разбирает список "path=mask", например "delivery.phone=phone"
+func ParseRules(list []string) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range list {
+		path, mask, ok := strings.Cut(item, "=")
+		path, mask = strings.TrimSpace(path), strings.TrimSpace(mask)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("%q: want path=mask", item)
+		}
+		switch mask {
+		case MaskPhone, MaskEmail, MaskName, MaskPartial, MaskRedact:
+		default:
+			return nil, fmt.Errorf("%q: unknown mask %q (want phone, email, name, partial or redact)", item, mask)
+		}
+		rules = append(rules, Rule{Path: strings.Split(path, "."), Mask: mask})
+	}
+	return rules, nil
+}
+
+// Masker маскирует персональные данные в JSON заказах для тех, у кого нет права их видеть
+type Masker struct {
+	rules  []Rule
+	reveal []string
+}
+
+// New: reveal — роли, которым данные показываются целиком
+func New(rules []Rule, reveal []string) *Masker {
+	return &Masker{rules: rules, reveal: reveal}
+}
+
+// Reveals сообщает, видит ли клиент запроса данные без маски.
+// Без аутентификации маскируется всё; nil Masker ничего не маскирует.
+func (m *Masker) Reveals(ctx context.Context) bool {
+	if m == nil {
+		return true
+	}
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, r := range m.reveal {
+		if p.Has(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// View — преобразование заказа для клиента; nil — отдавать как есть
+type View func(raw json.RawMessage) (json.RawMessage, error)
+
+// For возвращает View для клиента запроса
+func (m *Masker) For(ctx context.Context) View {
+	if m.Reveals(ctx) {
+		return nil
+	}
+	return m.Mask
+}
+
+// Mask возвращает заказ с замаскированными полями; поля, которых нет, пропускаются
+func (m *Masker) Mask(raw json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("mask: %w", err)
+	}
+	for _, r := range m.rules {
+		apply(doc, r.Path, r.Mask)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("mask: %w", err)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func apply(v any, path []string, mask string) {
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t {
+			apply(e, path, mask)
+		}
+	case map[string]any:
+		val, ok := t[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) > 1 {
+			apply(val, path[1:], mask)
+			return
+		}
+		switch s := val.(type) {
+		case nil:
+		case string:
+			t[path[0]] = Value(s, mask)
+		default:
+			t[path[0]] = stars
+		}
+	}
+}
+
+// Value маскирует одно значение
+func Value(s, mask string) string {
+	if s == "" {
+		return s
+	}
+	switch mask {
+	case MaskPhone:
+		return phone(s)
+	case MaskEmail:
+		local, domain, ok := strings.Cut(s, "@")
+		if !ok || local == "" {
+			return partial(s)
+		}
+		r, _ := utf8.DecodeRuneInString(local)
+		return string(r) + stars + "@" + domain
+	case MaskName:
+		words := strings.Fields(s)
+		for i, w := range words {
+			r, _ := utf8.DecodeRuneInString(w)
+			words[i] = string(r) + stars
+		}
+		return strings.Join(words, " ")
+	case MaskPartial:
+		return partial(s)
+	}
+	return stars
+}
+
+// phone оставляет код страны и последние 4 цифры
+func phone(s string) string {
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if len(digits) < 7 {
+		return stars
+	}
+	prefix := ""
+	if strings.HasPrefix(s, "+") {
+		prefix = "+" + string(digits[0])
+	}
+	return prefix + stars + string(digits[len(digits)-4:])
+}
+
+func partial(s string) string {
+	if utf8.RuneCountInString(s) <= 2 {
+		return stars
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	last, _ := utf8.DecodeLastRuneInString(s)
+	return string(first) + stars + string(last)
+}
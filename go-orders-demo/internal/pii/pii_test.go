@@ -0,0 +1,71 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"go-orders-demo/internal/auth"
+)
+
+func TestValue(t *testing.T) {
+	cases := []struct{ in, mask, want string }{
+		{"+79991231234", MaskPhone, "+7***1234"},
+		{"8 (999) 123-12-34", MaskPhone, "***1234"},
+		{"12345", MaskPhone, "***"},
+		{"anna@mail.ru", MaskEmail, "a***@mail.ru"},
+		{"Анна@почта.рф", MaskEmail, "А***@почта.рф"},
+		{"Иван Петров", MaskName, "И*** П***"},
+		{"Москва", MaskPartial, "М***а"},
+		{"Kiryat Mozkin 15", MaskRedact, "***"},
+		{"", MaskRedact, ""},
+	}
+	for _, c := range cases {
+		if got := Value(c.in, c.mask); got != c.want {
+			t.Errorf("Value(%q, %s) = %q, want %q", c.in, c.mask, got, c.want)
+		}
+	}
+}
+
+func TestMasker(t *testing.T) {
+	rules, err := ParseRules(append(DefaultRules, "items.rid=partial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(rules, []string{auth.RoleAdmin})
+	raw := json.RawMessage(`{"order_uid":"x","sm_id":99,"delivery":{"name":"Test Testov","phone":"+9720000000","email":"test@gmail.com","address":"Ploshad Mira 15","city":"Kiryat Mozkin"},"items":[{"rid":"ab4219087a764ae0btest","price":453}]}`)
+
+	got, err := m.Mask(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"delivery":{"address":"***","city":"Kiryat Mozkin","email":"t***@gmail.com","name":"T*** T***","phone":"+9***0000"},"items":[{"price":453,"rid":"a***t"}],"order_uid":"x","sm_id":99}`
+	if string(got) != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	reader := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "r", Roles: []string{auth.RoleReader}})
+	admin := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "a", Roles: []string{auth.RoleAdmin}})
+	if m.For(reader) == nil || m.For(context.Background()) == nil {
+		t.Fatal("reader and anonymous clients must get masked orders")
+	}
+	if m.For(admin) != nil || (*Masker)(nil).For(reader) != nil {
+		t.Fatal("admin and disabled masker must see orders as is")
+	}
+
+	if _, err := ParseRules([]string{"delivery.phone=hash"}); err == nil {
+		t.Fatal("expected error for unknown mask")
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(LogWriter(&buf), "", 0)
+	l.Printf("db save: duplicate key (email)=(test@gmail.com), phone +7 999 123-45-67, order b563feb7b2b84b6test")
+	want := "db save: duplicate key (email)=(t***@gmail.com), phone +7***4567, order b563feb7b2b84b6test\n"
+	if buf.String() != want {
+		t.Fatalf("got  %q\nwant %q", buf.String(), want)
+	}
+}
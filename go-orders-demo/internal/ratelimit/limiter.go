@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit — token bucket: Rate запросов в секунду в среднем, всплеск до Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited — нулевой Limit не ограничивает
+func (l Limit) Unlimited() bool { return l.Rate <= 0 }
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "0"
+	}
+	return strconv.FormatFloat(l.Rate, 'f', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// ParseLimit разбирает "rate:burst" (например "10:20" или "0.5:1"); "rate" — burst равен rate,
+// "0" или пусто — без ограничения
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	rs, bs, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(rs, 64)
+	if err != nil || rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return Limit{}, fmt.Errorf("bad rate %q", rs)
+	}
+	burst := int(math.Ceil(rate))
+	if hasBurst {
+		if burst, err = strconv.Atoi(bs); err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("bad burst %q", bs)
+		}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// ParseRoutes разбирает список "path=rate:burst" в лимиты по маршрутам
+func ParseRoutes(list []string) (map[string]Limit, error) {
+	res := map[string]Limit{}
+	for _, item := range list {
+		path, spec, ok := strings.Cut(item, "=")
+		path = strings.TrimSpace(path)
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("%q: want path=rate:burst", item)
+		}
+		l, err := ParseLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", item, err)
+		}
+		res[path] = l
+	}
+	return res, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter — token bucket на каждого клиента одного маршрута
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewLimiter(l Limit) *Limiter {
+	return &Limiter{limit: l, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow забирает токен клиента key; если токена нет — сколько ждать до следующего
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit.Unlimited() {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep раз в минуту удаляет корзины, которые уже успели наполниться:
+// новая корзина для такого клиента ничем от них не отличается
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Limits — лимиты по маршрутам; маршруты без своего лимита получают Default.
+// PerIP — общий лимит на IP адрес по всем маршрутам, он проверяется ещё до
+// аутентификации и не даёт перебирать ключи.
+type Limits struct {
+	Default Limit
+	PerIP   Limit
+	mu      sync.Mutex
+	routes  map[string]Limit
+	limiter map[string]*Limiter
+	ip      *Limiter
+}
+
+func NewLimits(def Limit, routes map[string]Limit) *Limits {
+	return &Limits{Default: def, routes: routes, limiter: map[string]*Limiter{}}
+}
+
+// For возвращает limiter маршрута path
+func (ls *Limits) For(path string) *Limiter {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, ok := ls.limiter[path]; ok {
+		return l
+	}
+	lim, ok := ls.routes[path]
+	if !ok {
+		lim = ls.Default
+	}
+	l := NewLimiter(lim)
+	ls.limiter[path] = l
+	return l
+}
+
+// IP возвращает общий limiter по IP адресам
+func (ls *Limits) IP() *Limiter {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.ip == nil {
+		ls.ip = NewLimiter(ls.PerIP)
+	}
+	return ls.ip
+}
+
+type clientKey struct{}
+
+// WithClient запоминает идентификатор клиента (имя API ключа, sub токена или IP) для квот
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func ClientFrom(ctx context.Context) (string, bool) {
+	c, ok := ctx.Value(clientKey{}).(string)
+	return c, ok && c != ""
+}
@@ -0,0 +1,26 @@
+package ratelimit
+
+import "expvar"
+
+// Метрики публикуются через expvar (GET /debug/vars)
+var (
+	allowed       = expvar.NewMap("ratelimit_allowed")     // по маршруту
+	limited       = expvar.NewMap("ratelimit_limited")     // по маршруту: ответы 429
+	quotaUsed     = expvar.NewMap("ingest_quota_used")     // по клиенту: расход за текущий день
+	quotaRejected = expvar.NewMap("ingest_quota_rejected") // по клиенту: отклонённые заказы
+)
+
+// Observe учитывает решение limiter'а маршрута route
+func Observe(route string, ok bool) {
+	if ok {
+		allowed.Add(route, 1)
+	} else {
+		limited.Add(route, 1)
+	}
+}
+
+func intVar(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
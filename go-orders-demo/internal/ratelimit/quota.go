@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("daily ingest quota exceeded")
+
+// QuotaStore — учёт расхода квот (реализуется db.SQLStore)
+type QuotaStore interface {
+	AddIngestUsage(ctx context.Context, client string, day time.Time, n, limit int64) (used int64, ok bool, err error)
+	ReleaseIngestUsage(ctx context.Context, client string, day time.Time, n int64) error
+}
+
+// Reservation — заказы, списанные Reserve; нулевая — ничего не списано
+type Reservation struct {
+	client string
+	day    time.Time
+	n      int64
+}
+
+// Quotas — дневные квоты приёма заказов на клиента; расход хранится в БД,
+// поэтому общий для всех экземпляров сервера. День — по UTC.
+type Quotas struct {
+	store     QuotaStore
+	def       int64
+	overrides map[string]int64
+	now       func() time.Time
+}
+
+// NewQuotas: def — квота по умолчанию (0 — без ограничения), overrides — по клиентам
+func NewQuotas(store QuotaStore, def int64, overrides map[string]int64) *Quotas {
+	return &Quotas{store: store, def: def, overrides: overrides, now: time.Now}
+}
+
+// ParseQuotas разбирает список "client=n"
+func ParseQuotas(list []string) (map[string]int64, error) {
+	res := map[string]int64{}
+	for _, item := range list {
+		client, n, ok := strings.Cut(item, "=")
+		client = strings.TrimSpace(client)
+		v, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if !ok || client == "" || err != nil || v < 0 {
+			return nil, fmt.Errorf("%q: want client=n", item)
+		}
+		res[client] = v
+	}
+	return res, nil
+}
+
+func (q *Quotas) limit(client string) int64 {
+	if v, ok := q.overrides[client]; ok {
+		return v
+	}
+	return q.def
+}
+
+// Reserve списывает n заказов из квоты клиента; ErrQuotaExceeded — если их не хватает.
+// Заказы, которые не удалось принять, возвращаются через Release.
+func (q *Quotas) Reserve(ctx context.Context, client string, n int) (Reservation, error) {
+	limit := q.limit(client)
+	if limit <= 0 || n <= 0 {
+		return Reservation{}, nil
+	}
+	day := q.now().UTC()
+	used, ok, err := q.store.AddIngestUsage(ctx, client, day, int64(n), limit)
+	if err != nil {
+		return Reservation{}, fmt.Errorf("ingest quota: %w", err)
+	}
+	quotaUsed.Set(client, intVar(used))
+	if !ok {
+		quotaRejected.Add(client, int64(n))
+		return Reservation{}, fmt.Errorf("%w: %d of %d used", ErrQuotaExceeded, used, limit)
+	}
+	return Reservation{client: client, day: day, n: int64(n)}, nil
+}
+
+// Release возвращает в квоту n заказов из r (не больше списанного) — в тот день, когда они списаны
+func (q *Quotas) Release(ctx context.Context, r Reservation, n int) error {
+	if int64(n) > r.n {
+		n = int(r.n)
+	}
+	if n <= 0 {
+		return nil
+	}
+	if err := q.store.ReleaseIngestUsage(ctx, r.client, r.day, int64(n)); err != nil {
+		return fmt.Errorf("ingest quota: %w", err)
+	}
+	quotaUsed.Add(r.client, -int64(n))
+	return nil
+}
+
+// RetryAfter — время до сброса квот (полночь UTC)
+func (q *Quotas) RetryAfter() time.Duration {
+	now := q.now().UTC()
+	return now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := NewLimiter(Limit{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d within burst was limited", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected limit with 500ms wait, got %v %v", ok, wait)
+	}
+	// у другого клиента своя корзина
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("client b limited")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("token was not refilled")
+	}
+
+	// наполнившиеся корзины удаляются
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+	if len(l.buckets) != 1 {
+		t.Fatalf("expected idle buckets to be swept, have %d", len(l.buckets))
+	}
+
+	if ok, _ := NewLimiter(Limit{}).Allow("a"); !ok {
+		t.Fatal("zero limit must not restrict")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Limit{"10:20": {10, 20}, "5": {5, 5}, "0.5:1": {0.5, 1}, "0.2": {0.2, 1}, "": {}, "0": {}} {
+		got, err := ParseLimit(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"x", "-1", "1:0", "1:x"} {
+		if _, err := ParseLimit(in); err == nil {
+			t.Errorf("ParseLimit(%q): expected error", in)
+		}
+	}
+	routes, err := ParseRoutes([]string{"/ingest=1:2", "/export=0"})
+	if err != nil || routes["/ingest"] != (Limit{1, 2}) || !routes["/export"].Unlimited() {
+		t.Fatalf("ParseRoutes: %v %v", routes, err)
+	}
+	if _, err := ParseRoutes([]string{"ingest=1"}); err == nil {
+		t.Fatal("expected error for route without leading slash")
+	}
+	if q, err := ParseQuotas([]string{"a=10"}); err != nil || q["a"] != 10 {
+		t.Fatalf("ParseQuotas: %v %v", q, err)
+	}
+}
+
+type fakeUsage map[string]int64
+
+func (f fakeUsage) AddIngestUsage(ctx context.Context, client string, day time.Time, n, limit int64) (int64, bool, error) {
+	key := client + day.Format(time.DateOnly)
+	if f[key]+n > limit {
+		return f[key], false, nil
+	}
+	f[key] += n
+	return f[key], true, nil
+}
+
+func (f fakeUsage) ReleaseIngestUsage(ctx context.Context, client string, day time.Time, n int64) error {
+	f[client+day.Format(time.DateOnly)] -= n
+	return nil
+}
+
+func TestQuotas(t *testing.T) {
+	now := time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)
+	q := NewQuotas(fakeUsage{}, 3, map[string]int64{"vip": 0})
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := q.Reserve(ctx, "a", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Reserve(ctx, "a", 2); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := q.Reserve(ctx, "a", 1); err != nil {
+		t.Fatalf("remaining quota: %v", err)
+	}
+	if _, err := q.Reserve(ctx, "vip", 100); err != nil {
+		t.Fatalf("unlimited override: %v", err)
+	}
+	if q.RetryAfter() != time.Hour {
+		t.Fatalf("retry after %v", q.RetryAfter())
+	}
+	// новые сутки — новая квота
+	now = now.Add(2 * time.Hour)
+	if _, err := q.Reserve(ctx, "a", 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// непринятые заказы возвращаются в квоту того дня, когда списаны
+func TestQuotaRelease(t *testing.T) {
+	now := time.Date(2024, 1, 2, 23, 59, 0, 0, time.UTC)
+	usage := fakeUsage{}
+	q := NewQuotas(usage, 3, nil)
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	r, err := q.Reserve(ctx, "a", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Reserve(ctx, "a", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := q.Release(ctx, r, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Reserve(ctx, "a", 2); err != nil {
+		t.Fatalf("released quota: %v", err)
+	}
+
+	// сбой после полуночи возвращает вчерашний расход, а не сегодняшний
+	r, _ = q.Reserve(ctx, "b", 3)
+	now = now.Add(2 * time.Minute)
+	if err := q.Release(ctx, r, 10); err != nil {
+		t.Fatal(err)
+	}
+	if usage["b2024-01-02"] != 0 || usage["b2024-01-03"] != 0 {
+		t.Fatalf("usage after release: %v", usage)
+	}
+	if err := q.Release(ctx, Reservation{}, 1); err != nil {
+		t.Fatalf("empty reservation: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package stream
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Event — сохранённый consumer'ом заказ в живой ленте
+type Event struct {
+	ID       uint64
+	OrderUID string
+	Data     json.RawMessage
+	meta     meta
+}
+
+// meta — поля заказа, по которым работают фильтры
+type meta struct {
+	OrderUID        string `json:"order_uid"`
+	CustomerID      string `json:"customer_id"`
+	DeliveryService string `json:"delivery_service"`
+	Entry           string `json:"entry"`
+	Locale          string `json:"locale"`
+	Status          string `json:"status"`
+}
+
+// Filter — условия подписки; пустой список означает «любое значение»
+type Filter struct {
+	OrderUIDs        []string
+	CustomerIDs      []string
+	DeliveryServices []string
+	Entries          []string
+	Locales          []string
+	Statuses         []string
+}
+
+// Match сообщает, проходит ли событие фильтр
+func (f Filter) Match(e Event) bool {
+	return matchAny(f.OrderUIDs, e.meta.OrderUID) &&
+		matchAny(f.CustomerIDs, e.meta.CustomerID) &&
+		matchAny(f.DeliveryServices, e.meta.DeliveryService) &&
+		matchAny(f.Entries, e.meta.Entry) &&
+		matchAny(f.Locales, e.meta.Locale) &&
+		matchAny(f.Statuses, e.meta.Status)
+}
+
+func matchAny(want []string, v string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, w := range want {
+		if strings.EqualFold(w, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub раздаёт события подписчикам и хранит последние события в кольцевом буфере,
+// чтобы переподключившийся клиент мог продолжить с Last-Event-ID.
+// Медленный подписчик, у которого переполнился буфер, отключается.
+type Hub struct {
+	mu        sync.Mutex
+	seq       uint64
+	ring      []Event
+	next      int // позиция для следующей записи в ring
+	size      int // число событий в ring
+	subs      map[*Subscription]struct{}
+	clientBuf int
+}
+
+func NewHub(bufferSize, clientBuffer int) *Hub {
+	return &Hub{
+		ring:      make([]Event, bufferSize),
+		subs:      make(map[*Subscription]struct{}),
+		clientBuf: clientBuffer,
+	}
+}
+
+// Subscription — подписка одного клиента
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	ch     chan Event
+}
+
+// Events возвращает канал событий; он закрывается при отписке или переполнении
+func (s *Subscription) Events() <-chan Event { return s.ch }
+
+// Close отписывает клиента
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Publish — обработчик для kafka.Handler
+func (h *Hub) Publish(id string, raw json.RawMessage) {
+	e := Event{OrderUID: id, Data: raw}
+	if err := json.Unmarshal(raw, &e.meta); err != nil {
+		log.Printf("stream: decode %s: %v", id, err)
+	}
+	e.meta.OrderUID = id
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e.ID = h.seq
+	if len(h.ring) > 0 {
+		h.ring[h.next] = e
+		h.next = (h.next + 1) % len(h.ring)
+		if h.size < len(h.ring) {
+			h.size++
+		}
+	}
+	for sub := range h.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// клиент не успевает — отключаем, он переподключится с Last-Event-ID
+			log.Printf("stream: drop slow subscriber at event %d", e.ID)
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe регистрирует подписчика и возвращает пропущенные события после lastID
+// (если resume == true), доступные в кольцевом буфере
+func (h *Hub) Subscribe(f Filter, lastID uint64, resume bool) ([]Event, *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []Event
+	if resume {
+		for i := 0; i < h.size; i++ {
+			e := h.ring[(h.next-h.size+i+len(h.ring))%len(h.ring)]
+			if e.Data != nil && e.ID > lastID && f.Match(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	sub := &Subscription{hub: h, filter: f, ch: make(chan Event, h.clientBuf)}
+	h.subs[sub] = struct{}{}
+	return backlog, sub
+}
+
+// Forget стирает из кольцевого буфера события заказов ids (например, после удаления
+// данных покупателя), чтобы они не ушли переподключившимся клиентам. Номера событий
+// остаются, так что Last-Event-ID продолжает работать.
+func (h *Hub) Forget(ids ...string) {
+	forget := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		forget[id] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.ring {
+		if e := &h.ring[i]; forget[e.OrderUID] {
+			*e = Event{ID: e.ID}
+		}
+	}
+}
+
+// remove вызывается под h.mu
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers — текущее число подписчиков
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
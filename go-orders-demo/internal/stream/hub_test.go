@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSubscribeResumeAndFilter(t *testing.T) {
+	h := NewHub(3, 4)
+	for _, o := range []string{
+		`{"order_uid":"a","customer_id":"c1"}`,
+		`{"order_uid":"b","customer_id":"c2"}`,
+		`{"order_uid":"c","customer_id":"c1"}`,
+		`{"order_uid":"d","customer_id":"c1"}`,
+	} {
+		var m meta
+		json.Unmarshal([]byte(o), &m)
+		h.Publish(m.OrderUID, json.RawMessage(o))
+	}
+
+	// буфер на 3 события: "a" (id=1) уже вытеснен
+	backlog, sub := h.Subscribe(Filter{CustomerIDs: []string{"c1"}}, 0, true)
+	defer sub.Close()
+	if len(backlog) != 2 || backlog[0].OrderUID != "c" || backlog[1].OrderUID != "d" {
+		t.Fatalf("backlog = %+v", backlog)
+	}
+
+	backlog, sub2 := h.Subscribe(Filter{}, 3, true)
+	defer sub2.Close()
+	if len(backlog) != 1 || backlog[0].ID != 4 {
+		t.Fatalf("resume after 3: %+v", backlog)
+	}
+
+	h.Publish("e", json.RawMessage(`{"order_uid":"e","customer_id":"c2"}`))
+	select {
+	case e := <-sub.Events():
+		t.Fatalf("filtered subscriber got %s", e.OrderUID)
+	default:
+	}
+	if e := <-sub2.Events(); e.OrderUID != "e" || e.ID != 5 {
+		t.Fatalf("got %+v", e)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	h := NewHub(10, 1)
+	_, sub := h.Subscribe(Filter{}, 0, false)
+	h.Publish("a", json.RawMessage(`{}`))
+	h.Publish("b", json.RawMessage(`{}`))
+
+	if h.Subscribers() != 0 {
+		t.Fatalf("slow subscriber still registered")
+	}
+	if e, ok := <-sub.Events(); !ok || e.OrderUID != "a" {
+		t.Fatalf("first event = %+v, %v", e, ok)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("channel must be closed after overflow")
+	}
+	sub.Close() // повторное закрытие безопасно
+}
+
+func TestForgetErasedOrders(t *testing.T) {
+	h := NewHub(10, 10)
+	h.Publish("a", json.RawMessage(`{"customer_id":"c1","delivery":{"phone":"+7999"}}`))
+	h.Publish("b", json.RawMessage(`{"customer_id":"c2"}`))
+	h.Publish("a", json.RawMessage(`{"customer_id":"c1"}`))
+	h.Forget("a")
+
+	backlog, sub := h.Subscribe(Filter{}, 0, true)
+	defer sub.Close()
+	if len(backlog) != 1 || backlog[0].OrderUID != "b" || backlog[0].ID != 2 {
+		t.Fatalf("backlog after forget: %+v", backlog)
+	}
+	h.Publish("c", json.RawMessage(`{}`))
+	if e := <-sub.Events(); e.ID != 4 {
+		t.Fatalf("numbering changed: %+v", e)
+	}
+}
@@ -0,0 +1,299 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+)
+
+// Виды расхождений между orders.payload и нормализованными таблицами
+const (
+	ProblemPayloadMissing = "payload_missing"
+	ProblemPayloadInvalid = "payload_invalid"
+	ProblemTablesMissing  = "tables_missing" // заказ сохранён только как payload (SaveRaw)
+	ProblemDuplicateRows  = "duplicate_rows" // несколько доставок или оплат у заказа
+	ProblemMismatch       = "mismatch"
+)
+
+// Что восстанавливать: RepairTables — таблицы по payload, RepairPayload — payload по таблицам
+const (
+	RepairTables  = "tables"
+	RepairPayload = "payload"
+)
+
+// Diff — поле, значение которого отличается; nil — значения нет
+type Diff struct {
+	Field   string `json:"field"`
+	Payload any    `json:"payload"`
+	Tables  any    `json:"tables"`
+}
+
+// Report — результат проверки одного заказа с расхождениями
+type Report struct {
+	OrderUID string `json:"order_uid"`
+	Problem  string `json:"problem"`
+	Diffs    []Diff `json:"diffs,omitempty"`
+	Repaired string `json:"repaired,omitempty"` // какая сторона перезаписана
+	Error    string `json:"error,omitempty"`    // ошибка восстановления
+}
+
+// Compare сверяет два представления заказа; nil — расхождений нет
+func Compare(so db.StoredOrder) *Report {
+	r := &Report{OrderUID: so.OrderUID}
+	if len(so.Payload) == 0 || string(so.Payload) == "null" {
+		if so.Tables == nil {
+			return nil
+		}
+		r.Problem = ProblemPayloadMissing
+		return r
+	}
+	var p models.Order
+	if err := json.Unmarshal(so.Payload, &p); err != nil {
+		r.Problem = ProblemPayloadInvalid
+		r.Diffs = []Diff{{Field: "payload", Payload: err.Error()}}
+		return r
+	}
+	if so.Tables == nil {
+		r.Problem = ProblemTablesMissing
+		return r
+	}
+	r.Diffs = diffOrders(p, *so.Tables)
+	switch {
+	case so.Deliveries > 1 || so.Payments > 1:
+		r.Problem = ProblemDuplicateRows
+		r.Diffs = append(r.Diffs, Diff{Field: "rows", Payload: map[string]int{"deliveries": 1, "payments": 1},
+			Tables: map[string]int{"deliveries": so.Deliveries, "payments": so.Payments}})
+	case len(r.Diffs) > 0:
+		r.Problem = ProblemMismatch
+	default:
+		return nil
+	}
+	return r
+}
+
+// diffOrders сравнивает поля, которые есть в обоих представлениях.
+// status хранится только в payload, позиции сопоставляются по chrt_id.
+func diffOrders(p, t models.Order) []Diff {
+	p.Status, t.Status = "", ""
+	p.DateCreated, t.DateCreated = p.DateCreated.UTC(), t.DateCreated.UTC()
+	pi, ti := p.Items, t.Items
+	p.Items, t.Items = nil, nil
+
+	var diffs []Diff
+	diffValues("", toDoc(p), toDoc(t), &diffs)
+
+	pm, tm := itemsByID(pi), itemsByID(ti)
+	ids := make([]int, 0, len(pm)+len(tm))
+	for id := range pm {
+		ids = append(ids, id)
+	}
+	for id := range tm {
+		if _, ok := pm[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		field := fmt.Sprintf("items[chrt_id=%d]", id)
+		pv, pok := pm[id]
+		tv, tok := tm[id]
+		if !pok || !tok {
+			d := Diff{Field: field}
+			if pok {
+				d.Payload = pv
+			}
+			if tok {
+				d.Tables = tv
+			}
+			diffs = append(diffs, d)
+			continue
+		}
+		diffValues(field, pv, tv, &diffs)
+	}
+	return diffs
+}
+
+func itemsByID(items []models.Item) map[int]any {
+	m := make(map[int]any, len(items))
+	for _, it := range items {
+		m[it.ChrtID] = toDoc(it)
+	}
+	return m
+}
+
+// toDoc — значение в том виде, в каком оно лежит в JSON
+func toDoc(v any) any {
+	b, _ := json.Marshal(v)
+	var doc any
+	json.Unmarshal(b, &doc)
+	return doc
+}
+
+func diffValues(path string, p, t any, diffs *[]Diff) {
+	pm, pok := p.(map[string]any)
+	tm, tok := t.(map[string]any)
+	if !pok || !tok {
+		if !reflect.DeepEqual(p, t) {
+			*diffs = append(*diffs, Diff{Field: path, Payload: p, Tables: t})
+		}
+		return
+	}
+	keys := make([]string, 0, len(pm))
+	for k := range pm {
+		keys = append(keys, k)
+	}
+	for k := range tm {
+		if _, ok := pm[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field := k
+		if path != "" {
+			field = path + "." + k
+		}
+		diffValues(field, pm[k], tm[k], diffs)
+	}
+}
+
+// Store — хранилище, которое умеет отдавать оба представления и перезаписывать каждое (реализуется db.SQLStore)
+type Store interface {
+	ScanStored(ctx context.Context, after string, limit int) ([]db.StoredOrder, error)
+	LoadStored(ctx context.Context, id string) (db.StoredOrder, error)
+	RewriteTables(ctx context.Context, o models.Order) error
+	ReplacePayload(ctx context.Context, id string, payload json.RawMessage) error
+}
+
+// Options — параметры проверки
+type Options struct {
+	Repair    string // "", RepairTables или RepairPayload
+	BatchSize int
+	OrderUID  string // проверить только этот заказ
+	// Missing — сообщать о заказах без нормализованных данных (и заполнять их при RepairTables).
+	// Заказы из Kafka сохраняются только как payload, поэтому по умолчанию они лишь считаются.
+	Missing bool
+}
+
+// Stats — итог проверки
+type Stats struct {
+	Checked       int `json:"checked"`
+	Mismatched    int `json:"mismatched"`
+	TablesMissing int `json:"tables_missing"`
+	Repaired      int `json:"repaired"`
+	Failed        int `json:"failed"` // восстановление не удалось
+}
+
+// Run проходит по заказам пачками по order_uid, вызывает report для каждого расхождения
+// и, если задан opts.Repair, перезаписывает выбранную сторону
+func Run(ctx context.Context, store Store, opts Options, report func(Report)) (Stats, error) {
+	var st Stats
+	switch opts.Repair {
+	case "", RepairTables, RepairPayload:
+	default:
+		return st, fmt.Errorf("unknown repair mode %q (want %s or %s)", opts.Repair, RepairTables, RepairPayload)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	check := func(so db.StoredOrder) {
+		st.Checked++
+		r := Compare(so)
+		if r == nil {
+			return
+		}
+		if r.Problem == ProblemTablesMissing {
+			st.TablesMissing++
+			if !opts.Missing {
+				return
+			}
+		} else {
+			st.Mismatched++
+		}
+		if opts.Repair != "" {
+			if err := repair(ctx, store, so, opts.Repair); err != nil {
+				st.Failed++
+				r.Error = err.Error()
+			} else {
+				st.Repaired++
+				r.Repaired = opts.Repair
+			}
+		}
+		report(*r)
+	}
+
+	if opts.OrderUID != "" {
+		so, err := store.LoadStored(ctx, opts.OrderUID)
+		if err != nil {
+			return st, err
+		}
+		check(so)
+		return st, nil
+	}
+	after := ""
+	for {
+		batch, err := store.ScanStored(ctx, after, opts.BatchSize)
+		if err != nil {
+			return st, err
+		}
+		for _, so := range batch {
+			check(so)
+		}
+		if len(batch) < opts.BatchSize {
+			return st, nil
+		}
+		after = batch[len(batch)-1].OrderUID
+		if err := ctx.Err(); err != nil {
+			return st, err
+		}
+	}
+}
+
+func repair(ctx context.Context, store Store, so db.StoredOrder, mode string) error {
+	if mode == RepairTables {
+		var o models.Order
+		if err := json.Unmarshal(so.Payload, &o); err != nil || len(so.Payload) == 0 || string(so.Payload) == "null" {
+			return errors.New("payload is missing or invalid, repair it from tables")
+		}
+		o.OrderUID = so.OrderUID
+		return store.RewriteTables(ctx, o)
+	}
+	if so.Tables == nil {
+		return errors.New("no normalized rows, repair tables from payload")
+	}
+	payload, err := payloadFromTables(so)
+	if err != nil {
+		return err
+	}
+	return store.ReplacePayload(ctx, so.OrderUID, payload)
+}
+
+// payloadFromTables накладывает поля из таблиц на payload: поля, которых в таблицах
+// нет (status, поля не из models.Order), сохраняются
+func payloadFromTables(so db.StoredOrder) (json.RawMessage, error) {
+	doc := map[string]any{}
+	if len(so.Payload) > 0 {
+		// невалидный или не-объектный payload заменяется целиком
+		if err := json.Unmarshal(so.Payload, &doc); err != nil || doc == nil {
+			doc = map[string]any{}
+		}
+	}
+	t := *so.Tables
+	t.DateCreated = t.DateCreated.UTC()
+	if t.Items == nil {
+		t.Items = []models.Item{}
+	}
+	for k, v := range toDoc(t).(map[string]any) {
+		if k == "status" {
+			continue
+		}
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
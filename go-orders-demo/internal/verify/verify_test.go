@@ -0,0 +1,151 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/models"
+)
+
+// fakeStore — заказы по order_uid в порядке возрастания
+type fakeStore struct {
+	orders    []db.StoredOrder
+	rewritten []models.Order
+	payloads  map[string]json.RawMessage
+}
+
+func (f *fakeStore) ScanStored(ctx context.Context, after string, limit int) ([]db.StoredOrder, error) {
+	var res []db.StoredOrder
+	for _, so := range f.orders {
+		if so.OrderUID > after && len(res) < limit {
+			res = append(res, so)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeStore) LoadStored(ctx context.Context, id string) (db.StoredOrder, error) {
+	for _, so := range f.orders {
+		if so.OrderUID == id {
+			return so, nil
+		}
+	}
+	return db.StoredOrder{}, db.ErrNotFound
+}
+
+func (f *fakeStore) RewriteTables(ctx context.Context, o models.Order) error {
+	f.rewritten = append(f.rewritten, o)
+	return nil
+}
+
+func (f *fakeStore) ReplacePayload(ctx context.Context, id string, payload json.RawMessage) error {
+	if f.payloads == nil {
+		f.payloads = map[string]json.RawMessage{}
+	}
+	f.payloads[id] = payload
+	return nil
+}
+
+func tablesOrder(uid string) *models.Order {
+	return &models.Order{
+		OrderUID:    uid,
+		TrackNumber: "T",
+		DateCreated: time.Date(2024, 1, 2, 6, 4, 5, 0, time.FixedZone("MSK", 3*3600)),
+		Delivery:    models.Delivery{Name: "N", City: "Moscow"},
+		Payment:     models.Payment{Transaction: uid, Amount: 100},
+		Items:       []models.Item{{ChrtID: 2, Price: 40}, {ChrtID: 1, Price: 60}},
+	}
+}
+
+func payload(uid string, extra string) json.RawMessage {
+	return json.RawMessage(`{"order_uid":"` + uid + `","track_number":"T","date_created":"2024-01-02T03:04:05Z",
+		"delivery":{"name":"N","city":"Moscow"},"payment":{"transaction":"` + uid + `","amount":100},
+		"items":[{"chrt_id":1,"price":60},{"chrt_id":2,"price":40}],"status":"cancelled"` + extra + `}`)
+}
+
+func TestCompare(t *testing.T) {
+	// одинаковые данные: порядок позиций, часовой пояс и status не считаются расхождением
+	if r := Compare(db.StoredOrder{OrderUID: "a", Payload: payload("a", ""), Tables: tablesOrder("a"), Deliveries: 1, Payments: 1}); r != nil {
+		t.Fatalf("expected no diffs, got %+v", r)
+	}
+
+	tab := tablesOrder("a")
+	tab.Delivery.City = "Kazan"
+	tab.Items = tab.Items[:1]
+	tab.Items[0].Price = 41
+	r := Compare(db.StoredOrder{OrderUID: "a", Payload: payload("a", ""), Tables: tab, Deliveries: 1, Payments: 1})
+	if r == nil || r.Problem != ProblemMismatch {
+		t.Fatalf("expected mismatch, got %+v", r)
+	}
+	var fields []string
+	for _, d := range r.Diffs {
+		fields = append(fields, d.Field)
+	}
+	if got := strings.Join(fields, ","); got != "delivery.city,items[chrt_id=1],items[chrt_id=2].price" {
+		t.Fatalf("unexpected diffs %s: %+v", got, r.Diffs)
+	}
+	if r.Diffs[1].Tables != nil || r.Diffs[2].Payload != float64(40) || r.Diffs[2].Tables != float64(41) {
+		t.Fatalf("unexpected values %+v", r.Diffs)
+	}
+
+	cases := map[string]db.StoredOrder{
+		ProblemTablesMissing:  {OrderUID: "b", Payload: payload("b", "")},
+		ProblemPayloadMissing: {OrderUID: "c", Tables: tablesOrder("c"), Deliveries: 1, Payments: 1},
+		ProblemPayloadInvalid: {OrderUID: "d", Payload: json.RawMessage(`{"sm_id":"x"}`), Tables: tablesOrder("d")},
+		ProblemDuplicateRows:  {OrderUID: "e", Payload: payload("e", ""), Tables: tablesOrder("e"), Deliveries: 2, Payments: 1},
+	}
+	for want, so := range cases {
+		if r := Compare(so); r == nil || r.Problem != want {
+			t.Errorf("%s: got %+v", want, r)
+		}
+	}
+}
+
+func TestRunRepair(t *testing.T) {
+	bad := tablesOrder("b")
+	bad.Payment.Amount = 1
+	store := &fakeStore{orders: []db.StoredOrder{
+		{OrderUID: "a", Payload: payload("a", ""), Tables: tablesOrder("a"), Deliveries: 1, Payments: 1},
+		{OrderUID: "b", Payload: payload("b", `,"comment":"keep"`), Tables: bad, Deliveries: 1, Payments: 1},
+		{OrderUID: "c", Payload: payload("c", "")},
+	}}
+
+	var reports []Report
+	st, err := Run(context.Background(), store, Options{BatchSize: 2}, func(r Report) { reports = append(reports, r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Checked != 3 || st.Mismatched != 1 || st.TablesMissing != 1 || len(reports) != 1 || reports[0].OrderUID != "b" {
+		t.Fatalf("stats=%+v reports=%+v", st, reports)
+	}
+
+	// payload по таблицам: сумма из payments, status и неизвестные поля сохраняются
+	st, err = Run(context.Background(), store, Options{Repair: RepairPayload}, func(Report) {})
+	if err != nil || st.Repaired != 1 {
+		t.Fatalf("stats=%+v err=%v", st, err)
+	}
+	var doc map[string]any
+	json.Unmarshal(store.payloads["b"], &doc)
+	if doc["payment"].(map[string]any)["amount"] != float64(1) || doc["status"] != "cancelled" || doc["comment"] != "keep" ||
+		doc["date_created"] != "2024-01-02T03:04:05Z" {
+		t.Fatalf("unexpected repaired payload %s", store.payloads["b"])
+	}
+
+	// таблицы по payload, включая заказы без нормализованных строк
+	reports = nil
+	st, err = Run(context.Background(), store, Options{Repair: RepairTables, Missing: true}, func(r Report) { reports = append(reports, r) })
+	if err != nil || st.Repaired != 2 || len(store.rewritten) != 2 {
+		t.Fatalf("stats=%+v err=%v", st, err)
+	}
+	if store.rewritten[0].Payment.Amount != 100 || store.rewritten[1].OrderUID != "c" || reports[1].Repaired != RepairTables {
+		t.Fatalf("unexpected rewrite %+v %+v", store.rewritten, reports)
+	}
+
+	if _, err := Run(context.Background(), store, Options{Repair: "both"}, func(Report) {}); err == nil {
+		t.Fatal("expected error for unknown repair mode")
+	}
+}
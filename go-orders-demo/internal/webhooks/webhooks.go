@@ -0,0 +1,310 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/events"
+)
+
+// Заголовки исходящих запросов
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// ErrInvalid — некорректные параметры подписки
+var ErrInvalid = errors.New("invalid webhook")
+
+// ErrPrivateAddress — получатель резолвится в loopback, link-local или частную сеть
+var ErrPrivateAddress = errors.New("webhook target address is not public")
+
+// Store — хранилище подписок и журнала доставок (реализуется db.SQLStore)
+type Store interface {
+	CreateWebhook(ctx context.Context, w db.Webhook) (db.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]db.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (db.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]db.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d db.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]db.WebhookDelivery, error)
+}
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Timeout — таймаут одного HTTP запроса к получателю
+	Timeout     time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// AllowPrivate — разрешить доставку на loopback и частные адреса (только для локальной разработки)
+	AllowPrivate bool
+}
+
+// Service управляет подписками и доставляет события на зарегистрированные URL
+type Service struct {
+	store  Store
+	client *http.Client
+	opts   Options
+	now    func() time.Time
+}
+
+func NewService(store Store, opts Options) *Service {
+	return &Service{
+		store:  store,
+		client: newClient(opts),
+		opts:   opts,
+		now:    time.Now,
+	}
+}
+
+// newClient — HTTP клиент доставки. Адрес проверяется при каждом соединении уже после
+// резолва, поэтому ни DNS rebinding, ни редирект не уведут запрос во внутреннюю сеть.
+func newClient(opts Options) *http.Client {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = publicOnly
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	// через прокси соединение ушло бы к прокси, и проверка адреса потеряла бы смысл
+	tr.Proxy = nil
+	tr.DialContext = dialer.DialContext
+	return &http.Client{Timeout: opts.Timeout, Transport: tr}
+}
+
+// publicOnly — net.Dialer.Control, отклоняющий непубличные адреса
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if ip := ap.Addr().Unmap(); !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// sharedSpace — 100.64.0.0/10 (RFC 6598, carrier-grade NAT)
+var sharedSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublic(ip netip.Addr) bool {
+	return ip.IsValid() && ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedSpace.Contains(ip)
+}
+
+// Register создаёт подписку. Пустой events — все события, пустой secret — генерируется.
+func (s *Service) Register(ctx context.Context, rawURL string, evs []string, secret string) (db.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return db.Webhook{}, fmt.Errorf("%w: url must be absolute http(s) URL", ErrInvalid)
+	}
+	for _, e := range evs {
+		if !events.Known(e) {
+			return db.Webhook{}, fmt.Errorf("%w: unknown event %q", ErrInvalid, e)
+		}
+	}
+	if secret == "" {
+		secret = newSecret()
+	}
+	if evs == nil {
+		evs = []string{}
+	}
+	return s.store.CreateWebhook(ctx, db.Webhook{URL: u.String(), Events: evs, Secret: secret})
+}
+
+func (s *Service) List(ctx context.Context) ([]db.Webhook, error) { return s.store.ListWebhooks(ctx) }
+
+func (s *Service) Get(ctx context.Context, id int64) (db.Webhook, error) {
+	return s.store.GetWebhook(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id int64) error { return s.store.DeleteWebhook(ctx, id) }
+
+func (s *Service) Deliveries(ctx context.Context, id int64, limit int) ([]db.WebhookDelivery, error) {
+	if _, err := s.store.GetWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.store.ListWebhookDeliveries(ctx, id, limit)
+}
+
+// OnSave — хук для kafka.Consumer.OnSave: ставит события заказа в очередь доставки
+func (s *Service) OnSave(ctx context.Context, id string, prev, cur json.RawMessage) {
+	envs, err := events.FromSave(prev, cur, s.now())
+	if err != nil {
+		log.Printf("webhooks: %s: %v", id, err)
+		return
+	}
+	for _, e := range envs {
+		if err := s.Enqueue(ctx, e); err != nil {
+			log.Printf("webhooks: enqueue %s %s: %v", e.Type, id, err)
+		}
+	}
+}
+
+// Enqueue ставит событие в очередь доставки подписчикам его типа
+func (s *Service) Enqueue(ctx context.Context, e events.Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", e.Type, err)
+	}
+	_, err = s.store.EnqueueWebhookDeliveries(ctx, e.ID, string(e.Type), data)
+	return err
+}
+
+// Run доставляет накопленные события до отмены ctx
+func (s *Service) Run(ctx context.Context) error {
+	tick := time.NewTicker(s.opts.PollInterval)
+	defer tick.Stop()
+	for {
+		for {
+			n, err := s.deliverDue(ctx)
+			if err != nil {
+				log.Printf("webhooks: %v", err)
+			}
+			if err != nil || n < s.opts.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+func (s *Service) deliverDue(ctx context.Context) (int, error) {
+	// lease с запасом на все запросы пачки, чтобы другой экземпляр не взял её повторно
+	lease := s.opts.Timeout*time.Duration(s.opts.BatchSize) + time.Minute
+	ds, err := s.store.ClaimWebhookDeliveries(ctx, s.opts.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+	hooks := make(map[int64]*db.Webhook)
+	for _, d := range ds {
+		w, ok := hooks[d.WebhookID]
+		if !ok {
+			hw, err := s.store.GetWebhook(ctx, d.WebhookID)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return 0, err
+			}
+			if err == nil {
+				w = &hw
+			}
+			hooks[d.WebhookID] = w
+		}
+		s.attempt(ctx, w, &d)
+		if err := s.store.UpdateWebhookDelivery(ctx, d); err != nil {
+			return 0, fmt.Errorf("update delivery %d: %w", d.ID, err)
+		}
+	}
+	return len(ds), nil
+}
+
+// attempt выполняет одну попытку доставки и обновляет состояние d
+func (s *Service) attempt(ctx context.Context, w *db.Webhook, d *db.WebhookDelivery) {
+	d.Attempts++
+	d.LastStatusCode = 0
+	d.LastError = ""
+	d.NextAttemptAt = nil
+	if w == nil || !w.Active {
+		d.Status = db.DeliveryFailed
+		d.LastError = "webhook disabled"
+		return
+	}
+
+	code, err := s.send(ctx, *w, *d)
+	d.LastStatusCode = code
+	if err == nil {
+		now := s.now()
+		d.Status = db.DeliveryDelivered
+		d.DeliveredAt = &now
+		return
+	}
+	d.LastError = err.Error()
+	if d.Attempts >= s.opts.MaxAttempts {
+		d.Status = db.DeliveryFailed
+		log.Printf("webhooks: delivery %d to %s failed after %d attempts: %v", d.ID, w.URL, d.Attempts, err)
+		return
+	}
+	d.Status = db.DeliveryPending
+	next := s.now().Add(s.backoff(d.Attempts - 1))
+	d.NextAttemptAt = &next
+}
+
+func (s *Service) send(ctx context.Context, w db.Webhook, d db.WebhookDelivery) (int, error) {
+	ts := s.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-orders-demo-webhooks/1")
+	req.Header.Set(HeaderID, strconv.FormatInt(w.ID, 10))
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, d.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff — экспоненциальная задержка перед повтором
+func (s *Service) backoff(attempts int) time.Duration {
+	d := s.opts.MinBackoff
+	for i := 0; i < attempts && d < s.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.opts.MaxBackoff {
+		d = s.opts.MaxBackoff
+	}
+	return d
+}
+
+// Sign считает подпись запроса: "sha256=" + hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+// Получатель должен пересчитать её и сравнить через hmac.Equal.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSecret() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
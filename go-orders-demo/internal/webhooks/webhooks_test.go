@@ -0,0 +1,239 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/db"
+)
+
+// memStore — хранилище в памяти для тестов
+type memStore struct {
+	mu         sync.Mutex
+	hooks      []db.Webhook
+	deliveries []db.WebhookDelivery
+}
+
+func (m *memStore) CreateWebhook(ctx context.Context, w db.Webhook) (db.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.ID = int64(len(m.hooks) + 1)
+	w.Active = true
+	m.hooks = append(m.hooks, w)
+	return w, nil
+}
+
+func (m *memStore) ListWebhooks(ctx context.Context) ([]db.Webhook, error) { return m.hooks, nil }
+
+func (m *memStore) GetWebhook(ctx context.Context, id int64) (db.Webhook, error) {
+	for _, w := range m.hooks {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return db.Webhook{}, db.ErrNotFound
+}
+
+func (m *memStore) DeleteWebhook(ctx context.Context, id int64) error { return nil }
+
+func (m *memStore) EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error) {
+	var n int64
+	for _, w := range m.hooks {
+		match := len(w.Events) == 0
+		for _, e := range w.Events {
+			match = match || e == eventType
+		}
+		if !match {
+			continue
+		}
+		m.deliveries = append(m.deliveries, db.WebhookDelivery{
+			ID: int64(len(m.deliveries) + 1), WebhookID: w.ID, EventID: eventID,
+			EventType: eventType, Payload: payload, Status: db.DeliveryPending,
+		})
+		n++
+	}
+	return n, nil
+}
+
+func (m *memStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]db.WebhookDelivery, error) {
+	var res []db.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == db.DeliveryPending && len(res) < limit {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStore) UpdateWebhookDelivery(ctx context.Context, d db.WebhookDelivery) error {
+	m.deliveries[d.ID-1] = d
+	return nil
+}
+
+func (m *memStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]db.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		calls    int
+		lastBody []byte
+		lastHdr  http.Header
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		lastBody, _ = io.ReadAll(r.Body)
+		lastHdr = r.Header.Clone()
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := &memStore{}
+	svc := NewService(store, Options{BatchSize: 10, Timeout: time.Second, MaxAttempts: 3, MinBackoff: time.Second, MaxBackoff: time.Minute, AllowPrivate: true})
+	ctx := context.Background()
+
+	if _, err := svc.Register(ctx, "ftp://nope", nil, ""); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for bad url, got %v", err)
+	}
+	if _, err := svc.Register(ctx, receiver.URL, []string{"OrderShipped"}, ""); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for unknown event, got %v", err)
+	}
+	hook, err := svc.Register(ctx, receiver.URL, []string{"OrderCreated"}, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.OnSave(ctx, "o1", nil, json.RawMessage(`{"order_uid":"o1"}`))
+	svc.OnSave(ctx, "o1", json.RawMessage(`{"order_uid":"o1"}`), json.RawMessage(`{"order_uid":"o1"}`))
+	if len(store.deliveries) != 1 {
+		t.Fatalf("want only OrderCreated enqueued, got %d deliveries", len(store.deliveries))
+	}
+
+	if _, err := svc.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d := store.deliveries[0]
+	if d.Status != db.DeliveryPending || d.Attempts != 1 || d.LastStatusCode != http.StatusServiceUnavailable || d.NextAttemptAt == nil {
+		t.Fatalf("after first attempt: %+v", d)
+	}
+
+	if _, err := svc.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d = store.deliveries[0]
+	if d.Status != db.DeliveryDelivered || d.Attempts != 2 || d.DeliveredAt == nil || d.NextAttemptAt != nil {
+		t.Fatalf("after second attempt: %+v", d)
+	}
+
+	ts, err := strconv.ParseInt(lastHdr.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	want := Sign(hook.Secret, ts, lastBody)
+	if !hmac.Equal([]byte(lastHdr.Get(HeaderSignature)), []byte(want)) {
+		t.Fatalf("bad signature %q, want %q", lastHdr.Get(HeaderSignature), want)
+	}
+	if lastHdr.Get(HeaderEvent) != "OrderCreated" || lastHdr.Get(HeaderDelivery) != d.EventID {
+		t.Fatalf("unexpected headers: %v", lastHdr)
+	}
+}
+
+func TestDeliveryGivesUp(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := &memStore{}
+	svc := NewService(store, Options{BatchSize: 10, Timeout: time.Second, MaxAttempts: 2, MinBackoff: time.Second, MaxBackoff: time.Minute, AllowPrivate: true})
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, receiver.URL, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	svc.OnSave(ctx, "o1", nil, json.RawMessage(`{"order_uid":"o1"}`))
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.deliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := store.deliveries[0]; d.Status != db.DeliveryFailed || d.Attempts != 2 || d.NextAttemptAt != nil {
+		t.Fatalf("want failed after 2 attempts, got %+v", d)
+	}
+}
+
+func TestDeliveryRefusesPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	for _, allow := range []bool{false, true} {
+		store := &memStore{}
+		svc := NewService(store, Options{BatchSize: 10, Timeout: time.Second, MaxAttempts: 1, MinBackoff: time.Second, MaxBackoff: time.Minute, AllowPrivate: allow})
+		ctx := context.Background()
+		if _, err := svc.Register(ctx, receiver.URL, nil, ""); err != nil {
+			t.Fatal(err)
+		}
+		svc.OnSave(ctx, "o1", nil, json.RawMessage(`{"order_uid":"o1"}`))
+		if _, err := svc.deliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		d := store.deliveries[0]
+		if allow && d.Status != db.DeliveryDelivered {
+			t.Fatalf("allow_private: want delivered, got %+v", d)
+		}
+		if !allow && (d.Status != db.DeliveryFailed || !strings.Contains(d.LastError, ErrPrivateAddress.Error())) {
+			t.Fatalf("want loopback refused, got %+v", d)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("receiver got %d requests, want 1", n)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2a00:1450::1":    true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"224.0.0.1":       false,
+	} {
+		if got := isPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if err := publicOnly("tcp", "[::ffff:169.254.169.254]:80", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("ipv4-mapped metadata address: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type Options struct {
+	// MaxSubscriptions — лимит order_uid на одно соединение
+	MaxSubscriptions int
+	// SendBuffer — очередь исходящих сообщений; при переполнении соединение закрывается
+	SendBuffer     int
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+	// AllowedOrigins — разрешённые Origin; пусто — только тот же хост
+	AllowedOrigins []string
+}
+
+// Lookup возвращает текущую версию заказа (например, из кеша) для снимка при подписке
+type Lookup func(id string) (json.RawMessage, bool)
+
+// Hub держит индекс order_uid -> соединения, поэтому публикация
+// затрагивает только подписчиков конкретного заказа
+type Hub struct {
+	opts     Options
+	lookup   Lookup
+	upgrader websocket.Upgrader
+
+	mu    sync.RWMutex
+	subs  map[string]map[*client]struct{}
+	conns int
+}
+
+func NewHub(opts Options, lookup Lookup) *Hub {
+	h := &Hub{opts: opts, lookup: lookup, subs: make(map[string]map[*client]struct{})}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+// Входящие сообщения клиента
+type request struct {
+	Action    string   `json:"action"` // subscribe | unsubscribe
+	OrderUID  string   `json:"order_uid,omitempty"`
+	OrderUIDs []string `json:"order_uids,omitempty"`
+}
+
+// Исходящие сообщения
+type message struct {
+	Type     string          `json:"type"` // subscribed | unsubscribed | order | error
+	OrderUID string          `json:"order_uid,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// View — преобразование заказа перед отправкой клиенту (например, маска персональных данных)
+type View func(raw json.RawMessage) (json.RawMessage, error)
+
+type client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	view View
+	// subs и closed защищены hub.mu
+	subs   map[string]struct{}
+	closed bool
+	once   sync.Once
+	done   chan struct{}
+}
+
+// Publish — обработчик для kafka.Handler
+func (h *Hub) Publish(id string, raw json.RawMessage) {
+	h.mu.RLock()
+	set := h.subs[id]
+	if len(set) == 0 {
+		h.mu.RUnlock()
+		return
+	}
+	clients := make([]*client, 0, len(set))
+	for c := range set {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	var data []byte // общее сообщение для клиентов без View
+	for _, c := range clients {
+		if c.view != nil {
+			if m, ok := c.order(id, raw); ok {
+				c.enqueue(m)
+			}
+			continue
+		}
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(message{Type: "order", OrderUID: id, Data: raw}); err != nil {
+				log.Printf("ws: encode %s: %v", id, err)
+				return
+			}
+		}
+		c.enqueue(data)
+	}
+}
+
+// order кодирует заказ для клиента с учётом его View
+func (c *client) order(id string, raw json.RawMessage) ([]byte, bool) {
+	if c.view != nil {
+		var err error
+		if raw, err = c.view(raw); err != nil {
+			log.Printf("ws: view %s: %v", id, err)
+			return nil, false
+		}
+	}
+	data, err := json.Marshal(message{Type: "order", OrderUID: id, Data: raw})
+	if err != nil {
+		log.Printf("ws: encode %s: %v", id, err)
+		return nil, false
+	}
+	return data, true
+}
+
+// Serve поднимает WebSocket соединение и обслуживает его до закрытия
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request) {
+	h.ServeView(w, r, nil)
+}
+
+// ServeView — Serve, в котором заказы перед отправкой проходят через view
+func (h *Hub) ServeView(w http.ResponseWriter, r *http.Request, view View) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже ответил клиенту
+		return
+	}
+	c := &client{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, h.opts.SendBuffer),
+		view: view,
+		subs: make(map[string]struct{}),
+		done: make(chan struct{}),
+	}
+	h.mu.Lock()
+	h.conns++
+	h.mu.Unlock()
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+// Connections — число открытых соединений
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.conns
+}
+
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(h.opts.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, o := range h.opts.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *client) readLoop() {
+	defer c.close()
+	c.conn.SetReadLimit(c.hub.opts.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.opts.PongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.hub.opts.PongTimeout))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.reply(message{Type: "error", Error: "invalid JSON"})
+			continue
+		}
+		ids := req.OrderUIDs
+		if req.OrderUID != "" {
+			ids = append(ids, req.OrderUID)
+		}
+		if len(ids) == 0 {
+			c.reply(message{Type: "error", Error: "order_uid required"})
+			continue
+		}
+		switch req.Action {
+		case "subscribe":
+			for _, id := range ids {
+				c.subscribe(id)
+			}
+		case "unsubscribe":
+			for _, id := range ids {
+				c.unsubscribe(id)
+			}
+		default:
+			c.reply(message{Type: "error", Error: "unknown action " + req.Action})
+		}
+	}
+}
+
+func (c *client) subscribe(id string) {
+	h := c.hub
+	h.mu.Lock()
+	if c.closed {
+		h.mu.Unlock()
+		return
+	}
+	if _, ok := c.subs[id]; !ok {
+		if len(c.subs) >= h.opts.MaxSubscriptions {
+			h.mu.Unlock()
+			c.reply(message{Type: "error", OrderUID: id, Error: "subscription limit reached"})
+			return
+		}
+		c.subs[id] = struct{}{}
+		set := h.subs[id]
+		if set == nil {
+			set = make(map[*client]struct{})
+			h.subs[id] = set
+		}
+		set[c] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	c.reply(message{Type: "subscribed", OrderUID: id})
+	if h.lookup != nil {
+		if raw, ok := h.lookup(id); ok {
+			if data, ok := c.order(id, raw); ok {
+				c.enqueue(data)
+			}
+		}
+	}
+}
+
+func (c *client) unsubscribe(id string) {
+	h := c.hub
+	h.mu.Lock()
+	h.unindex(c, id)
+	h.mu.Unlock()
+	c.reply(message{Type: "unsubscribed", OrderUID: id})
+}
+
+// unindex вызывается под h.mu
+func (h *Hub) unindex(c *client, id string) {
+	delete(c.subs, id)
+	if set := h.subs[id]; set != nil {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(h.subs, id)
+		}
+	}
+}
+
+func (c *client) reply(m message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	c.enqueue(data)
+}
+
+// enqueue не блокирует: клиент, не успевающий читать, отключается
+func (c *client) enqueue(data []byte) {
+	select {
+	case <-c.done:
+	case c.send <- data:
+	default:
+		log.Printf("ws: drop slow client %s", c.conn.RemoteAddr())
+		c.close()
+	}
+}
+
+func (c *client) writeLoop() {
+	ping := time.NewTicker(c.hub.opts.PingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+	for {
+		select {
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteTimeout))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// close снимает все подписки клиента; безопасен для повторного вызова
+func (c *client) close() {
+	c.once.Do(func() {
+		h := c.hub
+		h.mu.Lock()
+		for id := range c.subs {
+			h.unindex(c, id)
+		}
+		c.closed = true
+		h.conns--
+		h.mu.Unlock()
+		close(c.done)
+	})
+}
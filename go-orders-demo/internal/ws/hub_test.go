@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dial(t *testing.T, h *Hub) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(h.Serve))
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m message
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSubscribePublish(t *testing.T) {
+	lookup := func(id string) (json.RawMessage, bool) {
+		return json.RawMessage(`{"order_uid":"o1","status":"new"}`), id == "o1"
+	}
+	h := NewHub(Options{
+		MaxSubscriptions: 2, SendBuffer: 8, PingInterval: time.Second,
+		PongTimeout: 2 * time.Second, WriteTimeout: time.Second, MaxMessageSize: 1024,
+	}, lookup)
+	conn := dial(t, h)
+
+	conn.WriteJSON(request{Action: "subscribe", OrderUIDs: []string{"o1", "o2", "o3"}})
+	if m := read(t, conn); m.Type != "subscribed" || m.OrderUID != "o1" {
+		t.Fatalf("got %+v", m)
+	}
+	if m := read(t, conn); m.Type != "order" || !strings.Contains(string(m.Data), `"new"`) {
+		t.Fatalf("expected snapshot, got %+v", m)
+	}
+	if m := read(t, conn); m.Type != "subscribed" || m.OrderUID != "o2" {
+		t.Fatalf("got %+v", m)
+	}
+	if m := read(t, conn); m.Type != "error" || m.OrderUID != "o3" {
+		t.Fatalf("expected limit error, got %+v", m)
+	}
+
+	h.Publish("o3", json.RawMessage(`{"order_uid":"o3"}`))
+	h.Publish("o2", json.RawMessage(`{"order_uid":"o2","status":"shipped"}`))
+	if m := read(t, conn); m.Type != "order" || m.OrderUID != "o2" {
+		t.Fatalf("got %+v", m)
+	}
+
+	conn.WriteJSON(request{Action: "unsubscribe", OrderUID: "o2"})
+	if m := read(t, conn); m.Type != "unsubscribed" {
+		t.Fatalf("got %+v", m)
+	}
+	h.mu.RLock()
+	_, still := h.subs["o2"]
+	h.mu.RUnlock()
+	if still {
+		t.Fatal("o2 must be removed from index")
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Connections() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := h.Connections(); n != 0 {
+		t.Fatalf("connections = %d after close", n)
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.subs) != 0 {
+		t.Fatalf("index not cleaned: %v", h.subs)
+	}
+}
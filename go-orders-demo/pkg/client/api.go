@@ -0,0 +1,286 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// IngestOrder: POST /ingest — сохраняет заказ; повтор безопасен, заказ записывается по order_uid
+func (c *Client) IngestOrder(ctx context.Context, o *Order) error {
+	body, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(ctx, request{method: http.MethodPost, path: "/ingest", body: body, contentType: "application/json", idempotent: true})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// IngestBulk: POST /ingest/bulk — принимает заказы пачкой (NDJSON) и возвращает построчный отчёт.
+// Ошибки отдельных заказов — в BulkReport, а не в error.
+func (c *Client) IngestBulk(ctx context.Context, orders []*Order) (*BulkReport, error) {
+	var body []byte
+	for _, o := range orders {
+		line, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		body = append(append(body, line...), '\n')
+	}
+	res, err := c.do(ctx, request{method: http.MethodPost, path: "/ingest/bulk", body: body, contentType: "application/x-ndjson", idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	rep := &BulkReport{}
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		var line struct {
+			BulkResult
+			Summary *BulkSummary `json:"summary"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("bulk report: %w", err)
+		}
+		switch {
+		case line.Summary != nil:
+			rep.Summary = *line.Summary
+		case line.Status == "":
+			rep.Error = line.Error
+		default:
+			rep.Results = append(rep.Results, line.BulkResult)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("bulk report: %w", err)
+	}
+	return rep, nil
+}
+
+// GetOrder: GET /order/{order_uid}; если заказа нет — ошибка, для которой IsNotFound(err) == true
+func (c *Client) GetOrder(ctx context.Context, id string) (*Order, error) {
+	var o Order
+	if err := c.getJSON(ctx, "/order/"+url.PathEscape(id), nil, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ExportOrders: GET /export — выгрузка идёт потоком, вызывающий закрывает её.
+// Выгрузку не повторяем: оборванный ответ заметен только при чтении.
+func (c *Client) ExportOrders(ctx context.Context, p ExportParams) (io.ReadCloser, error) {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("format", p.Format)
+	set("customer_id", p.CustomerID)
+	set("delivery_service", p.DeliveryService)
+	set("phone", p.Phone)
+	set("email", p.Email)
+	if !p.CreatedFrom.IsZero() {
+		q.Set("created_from", p.CreatedFrom.Format(time.RFC3339))
+	}
+	if !p.CreatedTo.IsZero() {
+		q.Set("created_to", p.CreatedTo.Format(time.RFC3339))
+	}
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/export", query: q})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// ExportCustomer: GET /admin/customers/{customer_id}/export — все данные покупателя
+func (c *Client) ExportCustomer(ctx context.Context, customerID string) (*CustomerExport, error) {
+	var e CustomerExport
+	if err := c.getJSON(ctx, "/admin/customers/"+url.PathEscape(customerID)+"/export", nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// EraseCustomer: POST /admin/customers/{customer_id}/erase; mode — EraseAnonymize или EraseDelete
+func (c *Client) EraseCustomer(ctx context.Context, customerID, mode string) (*EraseResult, error) {
+	q := url.Values{}
+	if mode != "" {
+		q.Set("mode", mode)
+	}
+	var e EraseResult
+	r := request{method: http.MethodPost, path: "/admin/customers/" + url.PathEscape(customerID) + "/erase", query: q, idempotent: true}
+	if err := c.doJSON(ctx, r, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CacheStats: GET /admin/cache
+func (c *Client) CacheStats(ctx context.Context) (*CacheStats, error) {
+	var st CacheStats
+	if err := c.getJSON(ctx, "/admin/cache", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// WarmCache: POST /admin/cache/warm — догружает кеш из БД; повтор безопасен
+func (c *Client) WarmCache(ctx context.Context) (*CacheWarmResult, error) {
+	var res CacheWarmResult
+	if err := c.doJSON(ctx, request{method: http.MethodPost, path: "/admin/cache/warm", idempotent: true}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// EvictCache: DELETE /admin/cache/{order_uid}
+func (c *Client) EvictCache(ctx context.Context, id string) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: "/admin/cache/" + url.PathEscape(id), idempotent: true}, nil)
+}
+
+// KafkaOffsets: GET /admin/kafka/offsets — смещения группы и лаг по партициям
+func (c *Client) KafkaOffsets(ctx context.Context) ([]PartitionState, error) {
+	var v struct {
+		Partitions []PartitionState `json:"partitions"`
+	}
+	if err := c.getJSON(ctx, "/admin/kafka/offsets", nil, &v); err != nil {
+		return nil, err
+	}
+	return v.Partitions, nil
+}
+
+// RewindKafka: POST /admin/kafka/rewind — возвращает выставленные смещения.
+// Не повторяется: между попытками консьюмер мог уйти вперёд.
+func (c *Client) RewindKafka(ctx context.Context, req RewindRequest) ([]PartitionOffset, error) {
+	var v struct {
+		Offsets []PartitionOffset `json:"offsets"`
+	}
+	if err := c.postJSON(ctx, "/admin/kafka/rewind", req, false, &v); err != nil {
+		return nil, err
+	}
+	return v.Offsets, nil
+}
+
+// KafkaReplay: GET /admin/kafka/replay — состояние последнего replay
+func (c *Client) KafkaReplay(ctx context.Context) (*ReplayState, error) {
+	var st ReplayState
+	if err := c.getJSON(ctx, "/admin/kafka/replay", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// StartKafkaReplay: POST /admin/kafka/replay — запускает replay; если он уже идёт — *Error с кодом 409
+func (c *Client) StartKafkaReplay(ctx context.Context, req ReplayRequest) (*ReplayState, error) {
+	var st ReplayState
+	if err := c.postJSON(ctx, "/admin/kafka/replay", req, false, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// CancelKafkaReplay: DELETE /admin/kafka/replay
+func (c *Client) CancelKafkaReplay(ctx context.Context) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: "/admin/kafka/replay", idempotent: true}, nil)
+}
+
+// ListWebhooks: GET /webhooks
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var v []Webhook
+	if err := c.getJSON(ctx, "/webhooks", nil, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// CreateWebhook: POST /webhooks — подписка; секрет подписи возвращается только здесь
+func (c *Client) CreateWebhook(ctx context.Context, req WebhookRequest) (*WebhookCreated, error) {
+	var v WebhookCreated
+	if err := c.postJSON(ctx, "/webhooks", req, false, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetWebhook: GET /webhooks/{id}
+func (c *Client) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	var v Webhook
+	if err := c.getJSON(ctx, webhookPath(id), nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DeleteWebhook: DELETE /webhooks/{id}
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: webhookPath(id), idempotent: true}, nil)
+}
+
+// WebhookDeliveries: GET /webhooks/{id}/deliveries — последние доставки; limit 0 — по умолчанию сервера
+func (c *Client) WebhookDeliveries(ctx context.Context, id int64, limit int) ([]WebhookDelivery, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var v []WebhookDelivery
+	if err := c.getJSON(ctx, webhookPath(id)+"/deliveries", q, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Vars: GET /debug/vars — метрики expvar
+func (c *Client) Vars(ctx context.Context) (map[string]json.RawMessage, error) {
+	var v map[string]json.RawMessage
+	if err := c.getJSON(ctx, "/debug/vars", nil, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func webhookPath(id int64) string {
+	return "/webhooks/" + strconv.FormatInt(id, 10)
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, q url.Values, out any) error {
+	return c.doJSON(ctx, request{method: http.MethodGet, path: path, query: q, idempotent: true}, out)
+}
+
+func (c *Client) postJSON(ctx context.Context, path string, in any, idempotent bool, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(ctx, request{method: http.MethodPost, path: path, body: body, contentType: "application/json", idempotent: idempotent}, out)
+}
+
+// doJSON выполняет запрос и разбирает JSON ответа в out; out == nil — тело не нужно
+func (c *Client) doJSON(ctx context.Context, r request, out any) error {
+	if out != nil {
+		r.accept = "application/json"
+	}
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", r.method, r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package client — типизированный клиент HTTP API go-orders-demo.
+// Модели (models.gen.go) генерируются oapi-codegen из спецификации /openapi.json
+// (internal/api/openapi.json). Методы и повторы написаны вручную: клиентский код
+// oapi-codegen, читающий OpenAPI 3.1, требует Go 1.24. Контрактные тесты гоняют
+// клиент против настоящего api.Server и сверяют ответы со спецификацией.
+package client
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.8.0 -config oapi-codegen.yaml ../../internal/api/openapi.json
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry — повторы запросов: при сетевой ошибке, 429, 502, 503 и 504.
+// Повторяются только идемпотентные операции (чтение, приём заказов, удаление данных покупателя).
+type Retry struct {
+	// MaxAttempts — всего попыток, включая первую; 1 — без повторов
+	MaxAttempts int
+	MinBackoff  time.Duration
+	// MaxBackoff — предел паузы; если Retry-After больше, клиент не ждёт и сразу возвращает *Error
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry — повторы по умолчанию
+var DefaultRetry = Retry{MaxAttempts: 4, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+type Client struct {
+	base   *url.URL
+	http   *http.Client
+	header http.Header // учётные данные
+	retry  Retry
+}
+
+// Option настраивает клиент
+type Option func(*Client)
+
+// WithHTTPClient задаёт http.Client (таймауты, TLS, mTLS)
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithAPIKey передаёт API ключ в X-API-Key
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.header.Set("X-API-Key", key) }
+}
+
+// WithBearerToken передаёт JWT в Authorization
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.header.Set("Authorization", "Bearer "+token) }
+}
+
+// WithRetry задаёт повторы запросов
+func WithRetry(r Retry) Option {
+	return func(c *Client) { c.retry = r }
+}
+
+// New создаёт клиент API по адресу baseURL (например, http://localhost:8081)
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("client: base url must be absolute http(s) URL, got %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{base: u, http: http.DefaultClient, header: http.Header{}, retry: DefaultRetry}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// Error — ответ API с кодом не 2xx
+type Error struct {
+	StatusCode int
+	// Message — текст ответа сервера
+	Message string
+	// RetryAfter — из заголовка Retry-After (429)
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound сообщает, что API ответил 404
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// request — один вызов API
+type request struct {
+	method      string
+	path        string // уже экранированный
+	query       url.Values
+	body        []byte
+	contentType string
+	accept      string
+	idempotent  bool
+}
+
+// do выполняет запрос с повторами; при 2xx вызывающий закрывает тело ответа
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	target := c.base.String() + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	for attempt := 1; ; attempt++ {
+		var body io.Reader
+		if r.body != nil {
+			body = bytes.NewReader(r.body)
+		}
+		req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.header {
+			req.Header[k] = v
+		}
+		if r.contentType != "" {
+			req.Header.Set("Content-Type", r.contentType)
+		}
+		if r.accept != "" {
+			req.Header.Set("Accept", r.accept)
+		}
+
+		res, err := c.http.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !retryableError(err) {
+				return nil, err
+			}
+		case res.StatusCode < 300:
+			return res, nil
+		default:
+			apiErr := readError(res)
+			if !retryableStatus(res.StatusCode) {
+				return nil, apiErr
+			}
+			if apiErr.RetryAfter > c.retry.MaxBackoff {
+				return nil, apiErr
+			}
+			err, wait = apiErr, apiErr.RetryAfter
+		}
+		if !r.idempotent || attempt >= c.retry.MaxAttempts {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// backoff — экспоненциальная пауза перед попыткой attempt+1 со случайной добавкой до половины
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retry.MinBackoff << (attempt - 1)
+	if d <= 0 || d > c.retry.MaxBackoff {
+		d = c.retry.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError — сбой соединения; ошибки URL и TLS сертификата повторять бессмысленно
+func retryableError(err error) bool {
+	// *url.Error сам реализует net.Error — смотрим на причину
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func readError(res *http.Response) *Error {
+	defer res.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	e := &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	if v := res.Header.Get("Retry-After"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil {
+			e.RetryAfter = time.Duration(sec) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			e.RetryAfter = time.Until(t)
+		}
+	}
+	return e
+}
@@ -0,0 +1,750 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-orders-demo/internal/api"
+	"go-orders-demo/internal/auth"
+	"go-orders-demo/internal/cache"
+	"go-orders-demo/internal/db"
+	"go-orders-demo/internal/events"
+	kaf "go-orders-demo/internal/kafka"
+	"go-orders-demo/internal/models"
+	"go-orders-demo/internal/webhooks"
+)
+
+// Моки хранилищ: контрактные тесты гоняют настоящий api.Server без Postgres и Kafka
+
+type mockRepo struct{}
+
+func (mockRepo) SaveRaw(ctx context.Context, id string, raw json.RawMessage) (time.Time, error) {
+	return time.Time{}, nil
+}
+func (mockRepo) GetRaw(ctx context.Context, id string) (json.RawMessage, error) {
+	return nil, db.ErrNotFound
+}
+func (mockRepo) GetRawModified(ctx context.Context, id string) (json.RawMessage, time.Time, error) {
+	return nil, time.Time{}, db.ErrNotFound
+}
+func (mockRepo) LoadAllRaw(ctx context.Context, limit int) (map[string]db.RawOrder, error) {
+	return nil, nil
+}
+func (mockRepo) SaveOrder(ctx context.Context, o models.Order) error { return nil }
+
+type mockProducer struct{}
+
+func (mockProducer) Produce(ctx context.Context, payload []byte) error { return nil }
+func (mockProducer) ProduceBatch(ctx context.Context, batch []kaf.Payload) []error {
+	return make([]error, len(batch))
+}
+
+type mockKeys map[string][]string // ключ -> роли
+
+func (m mockKeys) LookupAPIKey(ctx context.Context, hash []byte) (db.APIKey, error) {
+	for k, roles := range m {
+		if string(auth.HashKey(k)) == string(hash) {
+			return db.APIKey{Name: k, Roles: roles}, nil
+		}
+	}
+	return db.APIKey{}, db.ErrNotFound
+}
+
+type mockWebhooks struct {
+	mu         sync.Mutex
+	hooks      []db.Webhook
+	deliveries []db.WebhookDelivery
+}
+
+func (m *mockWebhooks) CreateWebhook(ctx context.Context, w db.Webhook) (db.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.ID, w.Active, w.CreatedAt = int64(len(m.hooks)+1), true, time.Now().UTC()
+	m.hooks = append(m.hooks, w)
+	return w, nil
+}
+func (m *mockWebhooks) ListWebhooks(ctx context.Context) ([]db.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]db.Webhook{}, m.hooks...), nil
+}
+func (m *mockWebhooks) GetWebhook(ctx context.Context, id int64) (db.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.hooks {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return db.Webhook{}, db.ErrNotFound
+}
+func (m *mockWebhooks) DeleteWebhook(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, w := range m.hooks {
+		if w.ID == id {
+			m.hooks = append(m.hooks[:i], m.hooks[i+1:]...)
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+func (m *mockWebhooks) EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload json.RawMessage) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for _, w := range m.hooks {
+		m.deliveries = append(m.deliveries, db.WebhookDelivery{
+			ID: int64(len(m.deliveries) + 1), WebhookID: w.ID, EventID: eventID, EventType: eventType,
+			Payload: payload, Status: db.DeliveryPending, NextAttemptAt: &now, CreatedAt: now,
+		})
+	}
+	return int64(len(m.hooks)), nil
+}
+func (m *mockWebhooks) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]db.WebhookDelivery, error) {
+	return nil, nil
+}
+func (m *mockWebhooks) UpdateWebhookDelivery(ctx context.Context, d db.WebhookDelivery) error {
+	return nil
+}
+func (m *mockWebhooks) ListWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]db.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []db.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+type mockCustomers struct{}
+
+func (mockCustomers) CustomerBundle(ctx context.Context, customerID string) (db.CustomerBundle, error) {
+	return db.CustomerBundle{
+		CustomerID: customerID,
+		Orders:     []json.RawMessage{json.RawMessage(`{"order_uid":"` + customerID + `-1","customer_id":"` + customerID + `"}`)},
+		Deliveries: []db.CustomerDelivery{{OrderUID: customerID + "-1", Delivery: models.Delivery{Name: "N", Address: "A"}}},
+	}, nil
+}
+
+func (mockCustomers) EraseCustomer(ctx context.Context, customerID, mode string, beforeCommit func([]string) error) ([]string, error) {
+	ids := []string{customerID + "-1"}
+	if err := beforeCommit(ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+type mockKafkaAdmin struct{}
+
+func (mockKafkaAdmin) Offsets(ctx context.Context) ([]kaf.PartitionState, error) {
+	return []kaf.PartitionState{{Partition: 0, First: 0, Last: 10, Committed: 4, Lag: 6}}, nil
+}
+func (mockKafkaAdmin) OffsetsAt(ctx context.Context, t time.Time) ([]kaf.PartitionOffset, error) {
+	return []kaf.PartitionOffset{{Partition: 0, Offset: 3}}, nil
+}
+func (mockKafkaAdmin) Rewind(ctx context.Context, offsets []kaf.PartitionOffset) error { return nil }
+
+// Replay идёт, пока его не отменят
+func (mockKafkaAdmin) Replay(ctx context.Context, from, to time.Time, progress func([]kaf.ReplayProgress)) error {
+	progress([]kaf.ReplayProgress{{Partition: 0, Start: 0, End: 10, Offset: 5, Saved: 4, Skipped: 1}})
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type mockExporter struct{}
+
+func (mockExporter) BeginExport(ctx context.Context) (*db.ExportTx, error) {
+	return nil, errors.New("database is not available")
+}
+
+// exchange — один ответ сервера, записанный для сверки со спецификацией
+type exchange struct {
+	method, path string
+	status       int
+	header       http.Header
+	body         []byte
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+type recorder struct {
+	next http.Handler
+	mu   sync.Mutex
+	got  []exchange
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cw := &captureWriter{ResponseWriter: w}
+	rec.next.ServeHTTP(cw, r)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	h := w.Header().Clone()
+	if h.Get("Content-Type") == "" && cw.body.Len() > 0 {
+		// так же поступит net/http при отправке ответа
+		h.Set("Content-Type", http.DetectContentType(cw.body.Bytes()))
+	}
+	rec.got = append(rec.got, exchange{r.Method, r.URL.Path, cw.code, h, cw.body.Bytes()})
+}
+
+// spec — минимальный валидатор OpenAPI 3.1 для того подмножества JSON Schema, что есть в openapi.json
+type spec struct {
+	doc map[string]any
+	ops []specOp
+}
+
+type specOp struct {
+	id, method string
+	path       *regexp.Regexp
+	op         map[string]any
+}
+
+var pathParam = regexp.MustCompile(`\\\{[^}]+\\\}`)
+
+func loadSpec(t *testing.T, baseURL string) *spec {
+	t.Helper()
+	res, err := http.Get(baseURL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	s := &spec{}
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&s.doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	if s.doc["openapi"] != "3.1.0" {
+		t.Fatalf("openapi version %v", s.doc["openapi"])
+	}
+	for p, item := range s.doc["paths"].(map[string]any) {
+		re := regexp.MustCompile("^" + pathParam.ReplaceAllString(regexp.QuoteMeta(p), "[^/]+") + "$")
+		for method, op := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			op := op.(map[string]any)
+			s.ops = append(s.ops, specOp{id: op["operationId"].(string), method: strings.ToUpper(method), path: re, op: op})
+		}
+	}
+	return s
+}
+
+// resolve идёт по цепочке $ref внутри документа
+func (s *spec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur any = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			cur = cur.(map[string]any)[part]
+		}
+		node = cur.(map[string]any)
+	}
+}
+
+// check сверяет ответ с операцией спецификации и возвращает её operationId
+func (s *spec) check(ex exchange) (string, error) {
+	var op *specOp
+	for i := range s.ops {
+		if s.ops[i].method == ex.method && s.ops[i].path.MatchString(ex.path) {
+			op = &s.ops[i]
+			break
+		}
+	}
+	if op == nil {
+		return "", fmt.Errorf("%s %s: no operation in spec", ex.method, ex.path)
+	}
+	prefix := fmt.Sprintf("%s %s (%s) %d", ex.method, ex.path, op.id, ex.status)
+	resp, ok := op.op["responses"].(map[string]any)[fmt.Sprint(ex.status)].(map[string]any)
+	if !ok {
+		return op.id, fmt.Errorf("%s: status is not documented", prefix)
+	}
+	resp = s.resolve(resp)
+	content, _ := resp["content"].(map[string]any)
+	if len(content) == 0 {
+		if len(ex.body) > 0 {
+			return op.id, fmt.Errorf("%s: documented without body, got %q", prefix, ex.body)
+		}
+		return op.id, nil
+	}
+	media, _, _ := strings.Cut(ex.header.Get("Content-Type"), ";")
+	mt, ok := content[strings.TrimSpace(media)].(map[string]any)
+	if !ok {
+		return op.id, fmt.Errorf("%s: content type %q is not documented", prefix, media)
+	}
+	schema := s.resolve(mt["schema"].(map[string]any))
+
+	switch media {
+	case "text/plain":
+		return op.id, s.validate(schema, strings.TrimSpace(string(ex.body)), prefix)
+	case "application/json":
+		v, err := decodeJSON(ex.body)
+		if err != nil {
+			return op.id, fmt.Errorf("%s: %w", prefix, err)
+		}
+		return op.id, s.validate(schema, v, prefix)
+	case "application/x-ndjson":
+		sc := bufio.NewScanner(bytes.NewReader(ex.body))
+		for n := 1; sc.Scan(); n++ {
+			v, err := decodeJSON(sc.Bytes())
+			if err != nil {
+				return op.id, fmt.Errorf("%s line %d: %w", prefix, n, err)
+			}
+			if err := s.validate(schema, v, fmt.Sprintf("%s line %d", prefix, n)); err != nil {
+				return op.id, err
+			}
+		}
+	}
+	return op.id, nil
+}
+
+func decodeJSON(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// validate поддерживает $ref, type, const, enum, required, properties, additionalProperties,
+// items, oneOf, anyOf и format: date-time
+func (s *spec) validate(schema map[string]any, v any, at string) error {
+	schema = s.resolve(schema)
+	if types, ok := schema["type"]; ok {
+		var list []any
+		if t, ok := types.(string); ok {
+			list = []any{t}
+		} else {
+			list = types.([]any)
+		}
+		matched := false
+		for _, t := range list {
+			if hasType(t.(string), v) {
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %v is not %v", at, v, types)
+		}
+	}
+	if c, ok := schema["const"]; ok && fmt.Sprint(c) != fmt.Sprint(v) {
+		return fmt.Errorf("%s: %v != const %v", at, v, c)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v not in %v", at, v, enum)
+		}
+	}
+	if schema["format"] == "date-time" {
+		if str, ok := v.(string); ok {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		}
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		alts, ok := schema[key].([]any)
+		if !ok {
+			continue
+		}
+		n := 0
+		var errs []error
+		for _, alt := range alts {
+			if err := s.validate(alt.(map[string]any), v, at); err != nil {
+				errs = append(errs, err)
+			} else {
+				n++
+			}
+		}
+		if n == 0 || (key == "oneOf" && n > 1) {
+			return fmt.Errorf("%s: %d of %s matched: %w", at, n, key, errors.Join(errs...))
+		}
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, r := range asSlice(schema["required"]) {
+			if _, ok := v[r.(string)]; !ok {
+				return fmt.Errorf("%s: missing required %q", at, r)
+			}
+		}
+		for k, fv := range v {
+			if ps, ok := props[k].(map[string]any); ok {
+				if err := s.validate(ps, fv, at+"."+k); err != nil {
+					return err
+				}
+			} else if schema["additionalProperties"] == false {
+				return fmt.Errorf("%s: unexpected property %q", at, k)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, iv := range v {
+				if err := s.validate(items, iv, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+func hasType(t string, v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		_, err := v.Int64()
+		return t == "number" || (t == "integer" && err == nil)
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+// testOrder — заказ, который проходит models.Order.Validate
+func testOrder(id string) *Order {
+	return &Order{
+		OrderUID:    id,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery:    Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Email: "test@gmail.com"},
+		Payment:     Payment{Transaction: id, Currency: "USD", Provider: "wbpay", Amount: 1817},
+		Items:       []Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Name: "Mascaras", TotalPrice: 317}},
+		Locale:      "en",
+		CustomerID:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+const (
+	adminKey  = "ok_admin"
+	readerKey = "ok_reader"
+)
+
+func fastRetry() Option {
+	return WithRetry(Retry{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+}
+
+// TestContract гоняет клиент против настоящего api.Server и сверяет каждый ответ со спецификацией.
+// Операция спецификации без единого вызова — тоже ошибка: клиент и тест отстали от API.
+func TestContract(t *testing.T) {
+	c := cache.New(10)
+	raw, _ := json.Marshal(testOrder("b563feb7b2b84b6test"))
+	c.Set("b563feb7b2b84b6test", raw)
+
+	hooks := &mockWebhooks{}
+	publish := func(ctx context.Context, e events.Envelope) error {
+		if strings.HasPrefix(e.OrderUID, "kafka-down") {
+			return errors.New("kafka down")
+		}
+		return nil
+	}
+	srv := api.New(":0", c, mockRepo{}, mockProducer{},
+		api.WithAuth(auth.New(mockKeys{adminKey: {auth.RoleAdmin}, readerKey: {auth.RoleReader}}, nil, auth.Options{}), nil),
+		api.WithBulkIngest(2, 1<<20),
+		api.WithExport(mockExporter{}),
+		api.WithGDPR(mockCustomers{}, publish),
+		api.WithKafkaAdmin(mockKafkaAdmin{}),
+		api.WithWebhooks(webhooks.NewService(hooks, webhooks.Options{})),
+	)
+	rec := &recorder{next: srv.Handler()}
+	ts := httptest.NewServer(rec)
+	defer ts.Close()
+	s := loadSpec(t, ts.URL)
+
+	newClient := func(opts ...Option) *Client {
+		cl, err := New(ts.URL, append([]Option{fastRetry()}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cl
+	}
+	admin, reader, anon := newClient(WithAPIKey(adminKey)), newClient(WithAPIKey(readerKey)), newClient()
+	ctx := context.Background()
+
+	wantStatus := func(err error, code int) {
+		t.Helper()
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != code {
+			t.Fatalf("want %d, got %v", code, err)
+		}
+	}
+
+	// заказы
+	if err := admin.IngestOrder(ctx, testOrder("new-order")); err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(admin.IngestOrder(ctx, &Order{OrderUID: "bad"}), http.StatusBadRequest)
+	wantStatus(reader.IngestOrder(ctx, testOrder("x")), http.StatusForbidden)
+	rep, err := admin.IngestBulk(ctx, []*Order{testOrder("b1"), {OrderUID: "b2"}, testOrder("b3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Summary != (BulkSummary{Total: 3, Accepted: 2, Rejected: 1}) || len(rep.Results) != 3 || rep.Results[1].Status != BulkRejected {
+		t.Fatalf("bulk report %+v", rep)
+	}
+	o, err := reader.GetOrder(ctx, "b563feb7b2b84b6test")
+	if err != nil || o.Delivery.City != "Kiryat Mozkin" || len(o.Items) != 1 {
+		t.Fatalf("get order: %+v %v", o, err)
+	}
+	if _, err := reader.GetOrder(ctx, "missing"); !IsNotFound(err) {
+		t.Fatalf("missing order: %v", err)
+	}
+	_, err = anon.GetOrder(ctx, "b563feb7b2b84b6test")
+	wantStatus(err, http.StatusUnauthorized)
+	_, err = reader.ExportOrders(ctx, ExportParams{Format: "xlsx"})
+	wantStatus(err, http.StatusBadRequest)
+	_, err = reader.ExportOrders(ctx, ExportParams{Format: FormatCSV, CreatedFrom: time.Now().Add(-time.Hour)})
+	wantStatus(err, http.StatusInternalServerError)
+
+	// данные покупателя
+	exp, err := admin.ExportCustomer(ctx, "test")
+	if err != nil || len(exp.Orders) != 1 || exp.Deliveries[0].OrderUID != "test-1" {
+		t.Fatalf("customer export: %+v %v", exp, err)
+	}
+	_, err = reader.ExportCustomer(ctx, "test")
+	wantStatus(err, http.StatusForbidden)
+	er, err := admin.EraseCustomer(ctx, "test", EraseDelete)
+	if err != nil || er.Mode != EraseDelete || len(er.Orders) != 1 {
+		t.Fatalf("erase: %+v %v", er, err)
+	}
+	_, err = admin.EraseCustomer(ctx, "test", "shred")
+	wantStatus(err, http.StatusBadRequest)
+	_, err = admin.EraseCustomer(ctx, "kafka-down", "")
+	wantStatus(err, http.StatusBadGateway)
+
+	// Kafka
+	parts, err := admin.KafkaOffsets(ctx)
+	if err != nil || len(parts) != 1 || parts[0].Lag != 6 {
+		t.Fatalf("offsets: %+v %v", parts, err)
+	}
+	at := time.Now().Add(-time.Hour)
+	offs, err := admin.RewindKafka(ctx, RewindRequest{Timestamp: &at})
+	if err != nil || len(offs) != 1 || offs[0].Offset != 3 {
+		t.Fatalf("rewind: %+v %v", offs, err)
+	}
+	_, err = admin.RewindKafka(ctx, RewindRequest{})
+	wantStatus(err, http.StatusBadRequest)
+	st, err := admin.KafkaReplay(ctx)
+	if err != nil || st.Status != ReplayIdle {
+		t.Fatalf("replay idle: %+v %v", st, err)
+	}
+	wantStatus(admin.CancelKafkaReplay(ctx), http.StatusConflict)
+	if st, err = admin.StartKafkaReplay(ctx, ReplayRequest{From: &at}); err != nil || st.Status != ReplayRunning {
+		t.Fatalf("replay start: %+v %v", st, err)
+	}
+	_, err = admin.StartKafkaReplay(ctx, ReplayRequest{})
+	wantStatus(err, http.StatusConflict)
+	if err := admin.CancelKafkaReplay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if st, err = admin.KafkaReplay(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if st.Status == ReplayCanceled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replay after cancel: %+v", st)
+		}
+	}
+
+	// webhooks
+	created, err := admin.CreateWebhook(ctx, WebhookRequest{URL: "https://example.com/hook", Events: []EventType{EventType(events.OrderCreated)}})
+	if err != nil || created.Secret == "" || !created.Active {
+		t.Fatalf("create webhook: %+v %v", created, err)
+	}
+	_, err = admin.CreateWebhook(ctx, WebhookRequest{URL: "ftp://example.com"})
+	wantStatus(err, http.StatusBadRequest)
+	hooks.EnqueueWebhookDeliveries(ctx, "evt-1", string(events.OrderCreated), json.RawMessage(`{}`))
+	list, err := admin.ListWebhooks(ctx)
+	if err != nil || len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("list webhooks: %+v %v", list, err)
+	}
+	if w, err := admin.GetWebhook(ctx, created.ID); err != nil || w.URL != "https://example.com/hook" {
+		t.Fatalf("get webhook: %+v %v", w, err)
+	}
+	ds, err := admin.WebhookDeliveries(ctx, created.ID, 10)
+	if err != nil || len(ds) != 1 || ds[0].EventID != "evt-1" {
+		t.Fatalf("deliveries: %+v %v", ds, err)
+	}
+	_, err = admin.WebhookDeliveries(ctx, created.ID, 5000)
+	wantStatus(err, http.StatusBadRequest)
+	if err := admin.DeleteWebhook(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.GetWebhook(ctx, created.ID); !IsNotFound(err) {
+		t.Fatalf("deleted webhook: %v", err)
+	}
+	wantStatus(admin.DeleteWebhook(ctx, created.ID), http.StatusNotFound)
+
+	// кеш
+	if err := admin.EvictCache(ctx, "b563feb7b2b84b6test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.GetOrder(ctx, "b563feb7b2b84b6test"); !IsNotFound(err) {
+		t.Fatalf("evicted order: %v", err)
+	}
+	if res, err := admin.WarmCache(ctx); err != nil || res.Loaded != 0 {
+		t.Fatalf("warm: %+v %v", res, err)
+	}
+	if st, err := admin.CacheStats(ctx); err != nil || st.Limit != 10 || st.Hits == 0 || st.Misses == 0 {
+		t.Fatalf("cache stats: %+v %v", st, err)
+	}
+
+	if vars, err := admin.Vars(ctx); err != nil || vars["memstats"] == nil {
+		t.Fatalf("vars: %v", err)
+	}
+
+	// сверка всех записанных ответов и покрытие спецификации
+	seen := map[string]bool{}
+	for _, ex := range rec.got {
+		id, err := s.check(ex)
+		if err != nil {
+			t.Error(err)
+		}
+		seen[id] = true
+	}
+	// потоковые операции (SSE, WebSocket) клиент не поддерживает
+	skip := map[string]bool{"streamOrders": true, "watchOrders": true}
+	var missing []string
+	for _, op := range s.ops {
+		if !seen[op.id] && !skip[op.id] {
+			missing = append(missing, op.id)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("operations not exercised: %v", missing)
+	}
+}
+
+// TestEventTypesInSpec: перечисление EventType в спецификации совпадает с events.Types
+func TestEventTypesInSpec(t *testing.T) {
+	ts := httptest.NewServer(api.New(":0", cache.New(1), mockRepo{}, nil).Handler())
+	defer ts.Close()
+	s := loadSpec(t, ts.URL)
+	var got []string
+	for _, e := range asSlice(s.resolve(map[string]any{"$ref": "#/components/schemas/EventType"})["enum"]) {
+		got = append(got, e.(string))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(events.Types) {
+		t.Fatalf("EventType %v, events.Types %v", got, events.Types)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	var lastKey atomic.Value
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		lastKey.Store(r.Header.Get("X-API-Key"))
+		switch {
+		case r.URL.Path == "/admin/kafka/offsets" && n <= 2:
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+		case r.URL.Path == "/admin/kafka/offsets":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"partitions":[{"partition":0,"first_offset":0,"last_offset":1,"committed_offset":1,"lag":0}]}`)
+		case r.URL.Path == "/order/slow":
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "broker unavailable", http.StatusBadGateway)
+		}
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	cl, err := New(ts.URL+"/", WithAPIKey("k"), WithRetry(Retry{MaxAttempts: 4, MinBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 503 дважды, затем успех
+	if parts, err := cl.KafkaOffsets(ctx); err != nil || len(parts) != 1 || calls.Load() != 3 {
+		t.Fatalf("retried get: %v %v calls=%d", parts, err, calls.Load())
+	}
+	if lastKey.Load() != "k" {
+		t.Fatalf("api key %v", lastKey.Load())
+	}
+
+	// Retry-After больше MaxBackoff: ошибка сразу, с подсказкой, когда повторить
+	calls.Store(0)
+	_, err = cl.GetOrder(ctx, "slow")
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests || e.RetryAfter != time.Minute || calls.Load() != 1 {
+		t.Fatalf("429: %v calls=%d", err, calls.Load())
+	}
+
+	// неидемпотентный POST не повторяется
+	calls.Store(0)
+	if _, err := cl.StartKafkaReplay(ctx, ReplayRequest{}); err == nil || calls.Load() != 1 {
+		t.Fatalf("post retried: %v calls=%d", err, calls.Load())
+	}
+	// идемпотентный — до MaxAttempts
+	calls.Store(0)
+	if err := cl.IngestOrder(ctx, testOrder("x")); err == nil || calls.Load() != 4 {
+		t.Fatalf("ingest: %v calls=%d", err, calls.Load())
+	}
+
+	if _, err := New("localhost:8081"); err == nil {
+		t.Fatal("relative base url accepted")
+	}
+}
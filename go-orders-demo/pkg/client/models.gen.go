@@ -0,0 +1,564 @@
+// Package client provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.8.0 DO NOT EDIT.
+package client
+
+import (
+	"time"
+)
+
+// Defines values for BulkResultStatus.
+const (
+	BulkResultStatusAccepted BulkResultStatus = "accepted"
+	BulkResultStatusFailed   BulkResultStatus = "failed"
+	BulkResultStatusRejected BulkResultStatus = "rejected"
+)
+
+// Valid indicates whether the value is a known member of the BulkResultStatus enum.
+func (e BulkResultStatus) Valid() bool {
+	switch e {
+	case BulkResultStatusAccepted:
+		return true
+	case BulkResultStatusFailed:
+		return true
+	case BulkResultStatusRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for EraseResultMode.
+const (
+	EraseResultModeAnonymize EraseResultMode = "anonymize"
+	EraseResultModeDelete    EraseResultMode = "delete"
+)
+
+// Valid indicates whether the value is a known member of the EraseResultMode enum.
+func (e EraseResultMode) Valid() bool {
+	switch e {
+	case EraseResultModeAnonymize:
+		return true
+	case EraseResultModeDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for EventType.
+const (
+	OrderCancelled     EventType = "OrderCancelled"
+	OrderCreated       EventType = "OrderCreated"
+	OrderErased        EventType = "OrderErased"
+	OrderStatusChanged EventType = "OrderStatusChanged"
+	OrderUpdated       EventType = "OrderUpdated"
+)
+
+// Valid indicates whether the value is a known member of the EventType enum.
+func (e EventType) Valid() bool {
+	switch e {
+	case OrderCancelled:
+		return true
+	case OrderCreated:
+		return true
+	case OrderErased:
+		return true
+	case OrderStatusChanged:
+		return true
+	case OrderUpdated:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for ReplayStateStatus.
+const (
+	ReplayStateStatusCanceled ReplayStateStatus = "canceled"
+	ReplayStateStatusDone     ReplayStateStatus = "done"
+	ReplayStateStatusFailed   ReplayStateStatus = "failed"
+	ReplayStateStatusIdle     ReplayStateStatus = "idle"
+	ReplayStateStatusRunning  ReplayStateStatus = "running"
+)
+
+// Valid indicates whether the value is a known member of the ReplayStateStatus enum.
+func (e ReplayStateStatus) Valid() bool {
+	switch e {
+	case ReplayStateStatusCanceled:
+		return true
+	case ReplayStateStatusDone:
+		return true
+	case ReplayStateStatusFailed:
+		return true
+	case ReplayStateStatusIdle:
+		return true
+	case ReplayStateStatusRunning:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for WebhookDeliveryStatus.
+const (
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+)
+
+// Valid indicates whether the value is a known member of the WebhookDeliveryStatus enum.
+func (e WebhookDeliveryStatus) Valid() bool {
+	switch e {
+	case WebhookDeliveryStatusDelivered:
+		return true
+	case WebhookDeliveryStatusFailed:
+		return true
+	case WebhookDeliveryStatusPending:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for ContentEncoding.
+const (
+	ContentEncodingGzip     ContentEncoding = "gzip"
+	ContentEncodingIdentity ContentEncoding = "identity"
+	ContentEncodingZstd     ContentEncoding = "zstd"
+)
+
+// Valid indicates whether the value is a known member of the ContentEncoding enum.
+func (e ContentEncoding) Valid() bool {
+	switch e {
+	case ContentEncodingGzip:
+		return true
+	case ContentEncodingIdentity:
+		return true
+	case ContentEncodingZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for EraseCustomerParamsMode.
+const (
+	EraseCustomerParamsModeAnonymize EraseCustomerParamsMode = "anonymize"
+	EraseCustomerParamsModeDelete    EraseCustomerParamsMode = "delete"
+)
+
+// Valid indicates whether the value is a known member of the EraseCustomerParamsMode enum.
+func (e EraseCustomerParamsMode) Valid() bool {
+	switch e {
+	case EraseCustomerParamsModeAnonymize:
+		return true
+	case EraseCustomerParamsModeDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for ExportOrdersParamsFormat.
+const (
+	Csv     ExportOrdersParamsFormat = "csv"
+	Ndjson  ExportOrdersParamsFormat = "ndjson"
+	Parquet ExportOrdersParamsFormat = "parquet"
+)
+
+// Valid indicates whether the value is a known member of the ExportOrdersParamsFormat enum.
+func (e ExportOrdersParamsFormat) Valid() bool {
+	switch e {
+	case Csv:
+		return true
+	case Ndjson:
+		return true
+	case Parquet:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for IngestOrderParamsContentEncoding.
+const (
+	IngestOrderParamsContentEncodingGzip     IngestOrderParamsContentEncoding = "gzip"
+	IngestOrderParamsContentEncodingIdentity IngestOrderParamsContentEncoding = "identity"
+	IngestOrderParamsContentEncodingZstd     IngestOrderParamsContentEncoding = "zstd"
+)
+
+// Valid indicates whether the value is a known member of the IngestOrderParamsContentEncoding enum.
+func (e IngestOrderParamsContentEncoding) Valid() bool {
+	switch e {
+	case IngestOrderParamsContentEncodingGzip:
+		return true
+	case IngestOrderParamsContentEncodingIdentity:
+		return true
+	case IngestOrderParamsContentEncodingZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// Defines values for IngestBulkParamsContentEncoding.
+const (
+	IngestBulkParamsContentEncodingGzip     IngestBulkParamsContentEncoding = "gzip"
+	IngestBulkParamsContentEncodingIdentity IngestBulkParamsContentEncoding = "identity"
+	IngestBulkParamsContentEncodingZstd     IngestBulkParamsContentEncoding = "zstd"
+)
+
+// Valid indicates whether the value is a known member of the IngestBulkParamsContentEncoding enum.
+func (e IngestBulkParamsContentEncoding) Valid() bool {
+	switch e {
+	case IngestBulkParamsContentEncodingGzip:
+		return true
+	case IngestBulkParamsContentEncodingIdentity:
+		return true
+	case IngestBulkParamsContentEncodingZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// BulkResult defines model for BulkResult.
+type BulkResult struct {
+	Error string `json:"error,omitempty"`
+
+	// Line Номер строки NDJSON или элемента массива
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+
+	// Status rejected — заказ не разобран или не прошёл проверку, failed — не записан, можно повторить
+	Status BulkResultStatus `json:"status"`
+}
+
+// BulkResultStatus rejected — заказ не разобран или не прошёл проверку, failed — не записан, можно повторить
+type BulkResultStatus string
+
+// BulkSummary defines model for BulkSummary.
+type BulkSummary struct {
+	Accepted int `json:"accepted"`
+	Failed   int `json:"failed"`
+	Rejected int `json:"rejected"`
+	Total    int `json:"total"`
+}
+
+// CacheStats defines model for CacheStats.
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+
+	// Limit cache.limit
+	Limit  int   `json:"limit"`
+	Misses int64 `json:"misses"`
+}
+
+// CacheWarmResult defines model for CacheWarmResult.
+type CacheWarmResult struct {
+	// Entries Заказов в кеше после прогрева
+	Entries int `json:"entries"`
+
+	// Loaded Заказов прочитано из БД
+	Loaded int `json:"loaded"`
+}
+
+// CustomerDelivery defines model for CustomerDelivery.
+type CustomerDelivery struct {
+	Address  string `json:"address,omitempty"`
+	City     string `json:"city,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Name     string `json:"name,omitempty"`
+	OrderUID string `json:"order_uid"`
+	Phone    string `json:"phone,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Zip      string `json:"zip,omitempty"`
+}
+
+// CustomerExport defines model for CustomerExport.
+type CustomerExport struct {
+	CustomerID string             `json:"customer_id"`
+	Deliveries []CustomerDelivery `json:"deliveries"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Orders     []Order            `json:"orders"`
+}
+
+// Delivery defines model for Delivery.
+type Delivery struct {
+	Address string `json:"address,omitempty"`
+	City    string `json:"city,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Zip     string `json:"zip,omitempty"`
+}
+
+// EraseResult defines model for EraseResult.
+type EraseResult struct {
+	CustomerID string          `json:"customer_id"`
+	Mode       EraseResultMode `json:"mode"`
+	Orders     []string        `json:"orders"`
+}
+
+// EraseResultMode defines model for EraseResult.Mode.
+type EraseResultMode string
+
+// EventType defines model for EventType.
+type EventType string
+
+// Item defines model for Item.
+type Item struct {
+	Brand       string `json:"brand,omitempty"`
+	ChrtID      int    `json:"chrt_id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	NmID        int    `json:"nm_id,omitempty"`
+	Price       int    `json:"price,omitempty"`
+	Rid         string `json:"rid,omitempty"`
+	Sale        int    `json:"sale,omitempty"`
+	Size        string `json:"size,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	TotalPrice  int    `json:"total_price,omitempty"`
+	TrackNumber string `json:"track_number,omitempty"`
+}
+
+// KafkaOffsets defines model for KafkaOffsets.
+type KafkaOffsets struct {
+	Partitions []PartitionState `json:"partitions"`
+}
+
+// Order Заказ. Обязательны order_uid, track_number, payment.transaction, delivery.name и delivery.address; при ingest.disallow_unknown_fields другие поля запрещены.
+type Order struct {
+	CustomerID        string    `json:"customer_id,omitempty"`
+	DateCreated       time.Time `json:"date_created,omitempty"`
+	Delivery          Delivery  `json:"delivery,omitempty"`
+	DeliveryService   string    `json:"delivery_service,omitempty"`
+	Entry             string    `json:"entry,omitempty"`
+	InternalSignature string    `json:"internal_signature,omitempty"`
+	Items             []Item    `json:"items,omitempty"`
+	Locale            string    `json:"locale,omitempty"`
+	OofShard          string    `json:"oof_shard,omitempty"`
+	OrderUID          string    `json:"order_uid"`
+	Payment           Payment   `json:"payment,omitempty"`
+	Shardkey          string    `json:"shardkey,omitempty"`
+	SmID              int       `json:"sm_id,omitempty"`
+
+	// Status Статус заказа целиком, например cancelled
+	Status      string `json:"status,omitempty"`
+	TrackNumber string `json:"track_number,omitempty"`
+}
+
+// PartitionOffset defines model for PartitionOffset.
+type PartitionOffset struct {
+	Offset    int64 `json:"offset"`
+	Partition int   `json:"partition"`
+}
+
+// PartitionState defines model for PartitionState.
+type PartitionState struct {
+	CommittedOffset int64 `json:"committed_offset"`
+	FirstOffset     int64 `json:"first_offset"`
+	Lag             int64 `json:"lag"`
+
+	// LastOffset Следующий offset для записи
+	LastOffset int64 `json:"last_offset"`
+	Partition  int   `json:"partition"`
+}
+
+// Payment defines model for Payment.
+type Payment struct {
+	Amount       int    `json:"amount,omitempty"`
+	Bank         string `json:"bank,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	CustomFee    int    `json:"custom_fee,omitempty"`
+	DeliveryCost int    `json:"delivery_cost,omitempty"`
+	GoodsTotal   int    `json:"goods_total,omitempty"`
+	PaymentDt    int64  `json:"payment_dt,omitempty"`
+	Provider     string `json:"provider,omitempty"`
+	RequestID    string `json:"request_id,omitempty"`
+	Transaction  string `json:"transaction,omitempty"`
+}
+
+// ReplayProgress defines model for ReplayProgress.
+type ReplayProgress struct {
+	Done      bool  `json:"done"`
+	EndOffset int64 `json:"end_offset"`
+
+	// Offset Следующий к чтению
+	Offset      int64 `json:"offset"`
+	Partition   int   `json:"partition"`
+	Saved       int   `json:"saved"`
+	Skipped     int   `json:"skipped"`
+	StartOffset int64 `json:"start_offset"`
+}
+
+// ReplayRequest Окно по времени сообщений; без from — с начала топика, без to — до текущего конца
+type ReplayRequest struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// ReplayState defines model for ReplayState.
+type ReplayState struct {
+	Error      string            `json:"error,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	From       *time.Time        `json:"from,omitempty"`
+	Partitions []ReplayProgress  `json:"partitions,omitempty"`
+	Saved      int               `json:"saved"`
+	Skipped    int               `json:"skipped"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	Status     ReplayStateStatus `json:"status"`
+	To         *time.Time        `json:"to,omitempty"`
+}
+
+// ReplayStateStatus defines model for ReplayState.Status.
+type ReplayStateStatus string
+
+// RewindRequest Ровно одно из полей
+type RewindRequest struct {
+	Offsets   []PartitionOffset `json:"offsets,omitempty"`
+	Timestamp *time.Time        `json:"timestamp,omitempty"`
+}
+
+// RewindResult defines model for RewindResult.
+type RewindResult struct {
+	Offsets []PartitionOffset `json:"offsets"`
+}
+
+// Webhook defines model for Webhook.
+type Webhook struct {
+	Active    bool        `json:"active"`
+	CreatedAt time.Time   `json:"created_at"`
+	Events    []EventType `json:"events"`
+	ID        int64       `json:"id"`
+	URL       string      `json:"url"`
+}
+
+// WebhookCreated defines model for WebhookCreated.
+type WebhookCreated struct {
+	Active    bool        `json:"active"`
+	CreatedAt time.Time   `json:"created_at"`
+	Events    []EventType `json:"events"`
+	ID        int64       `json:"id"`
+
+	// Secret Ключ HMAC подписи; больше не показывается
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+// WebhookDelivery defines model for WebhookDelivery.
+type WebhookDelivery struct {
+	Attempts       int        `json:"attempts"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	EventID        string     `json:"event_id"`
+	EventType      EventType  `json:"event_type"`
+	ID             int64      `json:"id"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+
+	// NextAttemptAt Следующая попытка; только у доставок в статусе pending
+	NextAttemptAt *time.Time            `json:"next_attempt_at,omitempty"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	WebhookID     int64                 `json:"webhook_id"`
+}
+
+// WebhookDeliveryStatus defines model for WebhookDelivery.Status.
+type WebhookDeliveryStatus string
+
+// WebhookRequest defines model for WebhookRequest.
+type WebhookRequest struct {
+	// Events Пусто — все события
+	Events []EventType `json:"events,omitempty"`
+
+	// Secret Пусто — генерируется
+	Secret string `json:"secret,omitempty"`
+
+	// URL Абсолютный http(s) URL
+	URL string `json:"url"`
+}
+
+// ContentEncoding defines model for ContentEncoding.
+type ContentEncoding string
+
+// CustomerID defines model for CustomerID.
+type CustomerID = string
+
+// WebhookID defines model for WebhookID.
+type WebhookID = int64
+
+// EraseCustomerParams defines parameters for EraseCustomer.
+type EraseCustomerParams struct {
+	Mode EraseCustomerParamsMode `form:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// EraseCustomerParamsMode defines parameters for EraseCustomer.
+type EraseCustomerParamsMode string
+
+// ExportOrdersParams defines parameters for ExportOrders.
+type ExportOrdersParams struct {
+	Format          ExportOrdersParamsFormat `form:"format,omitempty" json:"format,omitempty"`
+	CustomerID      string                   `form:"customer_id,omitempty" json:"customer_id,omitempty"`
+	DeliveryService string                   `form:"delivery_service,omitempty" json:"delivery_service,omitempty"`
+	Phone           string                   `form:"phone,omitempty" json:"phone,omitempty"`
+	Email           string                   `form:"email,omitempty" json:"email,omitempty"`
+
+	// CreatedFrom RFC3339 или YYYY-MM-DD, включительно
+	CreatedFrom string `form:"created_from,omitempty" json:"created_from,omitempty"`
+
+	// CreatedTo RFC3339 или YYYY-MM-DD, не включая
+	CreatedTo string `form:"created_to,omitempty" json:"created_to,omitempty"`
+}
+
+// ExportOrdersParamsFormat defines parameters for ExportOrders.
+type ExportOrdersParamsFormat string
+
+// IngestOrderParams defines parameters for IngestOrder.
+type IngestOrderParams struct {
+	// ContentEncoding Сжатие тела запроса
+	ContentEncoding IngestOrderParamsContentEncoding `json:"Content-Encoding,omitempty"`
+}
+
+// IngestOrderParamsContentEncoding defines parameters for IngestOrder.
+type IngestOrderParamsContentEncoding string
+
+// IngestBulkJSONBody defines parameters for IngestBulk.
+type IngestBulkJSONBody = []Order
+
+// IngestBulkParams defines parameters for IngestBulk.
+type IngestBulkParams struct {
+	// ContentEncoding Сжатие тела запроса
+	ContentEncoding IngestBulkParamsContentEncoding `json:"Content-Encoding,omitempty"`
+}
+
+// IngestBulkParamsContentEncoding defines parameters for IngestBulk.
+type IngestBulkParamsContentEncoding string
+
+// GetOrderParams defines parameters for GetOrder.
+type GetOrderParams struct {
+	// Fields Оставить только перечисленные поля (через запятую, вложенные — через точку)
+	Fields          string `form:"fields,omitempty" json:"fields,omitempty"`
+	IfModifiedSince string `json:"If-Modified-Since,omitempty"`
+}
+
+// ListWebhookDeliveriesParams defines parameters for ListWebhookDeliveries.
+type ListWebhookDeliveriesParams struct {
+	Limit int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// StartKafkaReplayJSONRequestBody defines body for StartKafkaReplay for application/json ContentType.
+type StartKafkaReplayJSONRequestBody = ReplayRequest
+
+// RewindKafkaJSONRequestBody defines body for RewindKafka for application/json ContentType.
+type RewindKafkaJSONRequestBody = RewindRequest
+
+// IngestOrderJSONRequestBody defines body for IngestOrder for application/json ContentType.
+type IngestOrderJSONRequestBody = Order
+
+// IngestBulkJSONRequestBody defines body for IngestBulk for application/json ContentType.
+type IngestBulkJSONRequestBody = IngestBulkJSONBody
+
+// CreateWebhookJSONRequestBody defines body for CreateWebhook for application/json ContentType.
+type CreateWebhookJSONRequestBody = WebhookRequest
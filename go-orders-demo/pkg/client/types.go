@@ -0,0 +1,55 @@
+package client
+
+import "time"
+
+// Модели схем спецификации — в models.gen.go; здесь то, чего в схемах нет:
+// параметры методов, разобранный отчёт IngestBulk и константы значений.
+
+// Статусы строк отчёта IngestBulk
+const (
+	BulkAccepted = "accepted"
+	BulkRejected = "rejected" // заказ не разобран или не прошёл проверку
+	BulkFailed   = "failed"   // не записан, можно отправить повторно
+)
+
+// BulkReport — разобранный NDJSON ответ /ingest/bulk
+type BulkReport struct {
+	Results []BulkResult
+	Summary BulkSummary
+	// Error — тело дальше не разобрано сервером
+	Error string
+}
+
+// Форматы ExportOrders
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// ExportParams — фильтры выгрузки; пустые поля не фильтруют
+type ExportParams struct {
+	Format          string
+	CustomerID      string
+	DeliveryService string
+	Phone           string
+	Email           string
+	// CreatedFrom, CreatedTo — интервал date_created [from, to)
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// Режимы EraseCustomer
+const (
+	EraseAnonymize = "anonymize"
+	EraseDelete    = "delete"
+)
+
+// Состояния replay
+const (
+	ReplayIdle     = "idle"
+	ReplayRunning  = "running"
+	ReplayDone     = "done"
+	ReplayFailed   = "failed"
+	ReplayCanceled = "canceled"
+)
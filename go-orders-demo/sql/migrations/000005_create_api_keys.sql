@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    name         TEXT NOT NULL,
+    key_hash     BYTEA NOT NULL UNIQUE,
+    roles        TEXT[] NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    principal   TEXT NOT NULL,
+    auth_method TEXT NOT NULL,
+    roles       TEXT[] NOT NULL DEFAULT '{}',
+    action      TEXT NOT NULL,
+    status      INT NOT NULL,
+    remote_addr TEXT
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_principal_idx ON audit_log (principal, at DESC);
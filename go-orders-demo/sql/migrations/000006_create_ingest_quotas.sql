@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS ingest_quota_usage (
+    client     TEXT NOT NULL,
+    day        DATE NOT NULL,
+    used       BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (client, day)
+);
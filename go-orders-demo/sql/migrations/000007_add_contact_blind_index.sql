@@ -0,0 +1,6 @@
+-- blind index (HMAC) телефона и e-mail получателя для поиска по зашифрованным данным
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS phone_bidx BYTEA;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS email_bidx BYTEA;
+
+CREATE INDEX IF NOT EXISTS orders_phone_bidx_idx ON orders (phone_bidx) WHERE phone_bidx IS NOT NULL;
+CREATE INDEX IF NOT EXISTS orders_email_bidx_idx ON orders (email_bidx) WHERE email_bidx IS NOT NULL;
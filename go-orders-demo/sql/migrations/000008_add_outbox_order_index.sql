@@ -0,0 +1,2 @@
+-- релей outbox ищет более ранние недоставленные записи того же заказа
+CREATE INDEX IF NOT EXISTS outbox_order_pending_idx ON outbox (order_uid, id) WHERE delivered_at IS NULL;
@@ -0,0 +1,3 @@
+-- следующая попытка есть только у доставок в статусе pending
+ALTER TABLE webhook_deliveries ALTER COLUMN next_attempt_at DROP NOT NULL;
+UPDATE webhook_deliveries SET next_attempt_at = NULL WHERE status <> 'pending';
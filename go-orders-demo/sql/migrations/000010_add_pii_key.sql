@@ -0,0 +1,29 @@
+-- ключ, которым зашифрованы персональные данные строки; NULL — строка записана открытой.
+-- Расшифровываются только строки с pii_key, поэтому значение клиента вида enc:v1:… остаётся данными.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pii_key TEXT;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS pii_key TEXT;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS pii_key TEXT;
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS pii_key TEXT;
+
+-- строки, зашифрованные до появления колонки: ключ берётся из первого зашифрованного поля.
+-- webhook_deliveries до этой миграции не шифровались
+UPDATE orders SET pii_key = COALESCE(
+    substring(payload->'delivery'->>'name' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'phone' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'email' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'address' FROM '^enc:v1:([^:]+):'))
+WHERE pii_key IS NULL AND payload->'delivery' IS NOT NULL;
+
+UPDATE outbox SET pii_key = COALESCE(
+    substring(payload->'delivery'->>'name' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'phone' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'email' FROM '^enc:v1:([^:]+):'),
+    substring(payload->'delivery'->>'address' FROM '^enc:v1:([^:]+):'))
+WHERE pii_key IS NULL AND payload->'delivery' IS NOT NULL;
+
+UPDATE deliveries SET pii_key = COALESCE(
+    substring(name FROM '^enc:v1:([^:]+):'),
+    substring(phone FROM '^enc:v1:([^:]+):'),
+    substring(email FROM '^enc:v1:([^:]+):'),
+    substring(address FROM '^enc:v1:([^:]+):'))
+WHERE pii_key IS NULL;
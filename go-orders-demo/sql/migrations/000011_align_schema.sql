@@ -0,0 +1,27 @@
+-- SaveOrder пишет доставку через ON CONFLICT (order_uid), а уникального ключа у deliveries не было.
+-- Заодно доводим базы, созданные прежним sql/init.sql: там у orders нет payload и updated_at,
+-- а у payments и items — уникальных transaction и chrt_id.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS payload JSONB;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+DELETE FROM deliveries d USING deliveries newer
+WHERE d.order_uid = newer.order_uid AND d.id < newer.id;
+CREATE UNIQUE INDEX IF NOT EXISTS deliveries_order_uid_key ON deliveries (order_uid);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_index i
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+        WHERE i.indrelid = 'payments'::regclass AND i.indisunique AND i.indnatts = 1 AND a.attname = 'transaction'
+    ) THEN
+        CREATE UNIQUE INDEX payments_transaction_key ON payments (transaction);
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_index i
+        JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+        WHERE i.indrelid = 'items'::regclass AND i.indisunique AND i.indnatts = 1 AND a.attname = 'chrt_id'
+    ) THEN
+        CREATE UNIQUE INDEX items_chrt_id_key ON items (chrt_id);
+    END IF;
+END $$;
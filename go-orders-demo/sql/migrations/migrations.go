@@ -0,0 +1,9 @@
+// Package migrations встраивает SQL миграции в бинарники (старт app, ordersctl migrate)
+package migrations
+
+import "embed"
+
+// FS — файлы NNNNNN_name.sql; применяются по порядку имён
+//
+//go:embed *.sql
+var FS embed.FS